@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/ttakahashi/youtube-summary/internal/llm"
+	"github.com/ttakahashi/youtube-summary/internal/logging"
+	"github.com/ttakahashi/youtube-summary/internal/store"
+	"github.com/ttakahashi/youtube-summary/internal/transcript"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	maxQuestionLength = 500 // characters
+
+	// Transcripts are split into chunks of about this many seconds, and the
+	// chunks most related to the question are given to the model.
+	askChunkSeconds  = 60
+	askContextChunks = 12
+)
+
+// Citation is a transcript chunk an answer is based on.
+type Citation struct {
+	Start     float64 `json:"start"`
+	End       float64 `json:"end"`
+	Timestamp string  `json:"timestamp"`
+	URL       string  `json:"url"`
+	Text      string  `json:"text"`
+}
+
+// Answer is the reply to a question about a video.
+type Answer struct {
+	Question  string     `json:"question"`
+	Answer    string     `json:"answer"`
+	Citations []Citation `json:"citations"`
+	Cached    bool       `json:"cached"`
+	CreatedAt string     `json:"createdAt"`
+}
+
+// questionKey identifies a question regardless of spacing and letter case.
+func questionKey(question string) string {
+	q := strings.ToLower(strings.Join(strings.Fields(norm.NFKC.String(question)), " "))
+	sum := sha256.Sum256([]byte(q))
+	return hex.EncodeToString(sum[:])
+}
+
+// bigrams returns the character bigrams of s, which work for Japanese text
+// without word boundaries as well as for English.
+func bigrams(s string) map[string]bool {
+	runes := []rune(strings.ToLower(norm.NFKC.String(s)))
+	set := map[string]bool{}
+	for i := 0; i+1 < len(runes); i++ {
+		if runes[i] == ' ' || runes[i+1] == ' ' {
+			continue
+		}
+		set[string(runes[i:i+2])] = true
+	}
+	return set
+}
+
+// relevantChunks returns the indexes of up to n chunks that share the most
+// bigrams with question, in transcript order.
+func relevantChunks(chunks []transcript.Chunk, question string, n int) []int {
+	if len(chunks) <= n {
+		all := make([]int, len(chunks))
+		for i := range chunks {
+			all[i] = i
+		}
+		return all
+	}
+
+	q := bigrams(question)
+	scores := make([]int, len(chunks))
+	for i, c := range chunks {
+		for b := range bigrams(c.Text) {
+			if q[b] {
+				scores[i]++
+			}
+		}
+	}
+	order := make([]int, len(chunks))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return scores[order[a]] > scores[order[b]] })
+	picked := order[:n]
+	sort.Ints(picked)
+	return picked
+}
+
+// askModel answers question from the given chunks and returns the answer
+// with the chunks it cites.
+func askModel(ctx context.Context, title, question string, chunks []transcript.Chunk, picked []int) (string, []int, *llm.Response, error) {
+	var b strings.Builder
+	for _, i := range picked {
+		c := chunks[i]
+		fmt.Fprintf(&b, "<chunk id=\"%d\" time=\"%s-%s\">%s</chunk>\n", i, transcript.FormatTimestamp(c.Start), transcript.FormatTimestamp(c.End), c.Text)
+	}
+
+	prompt := fmt.Sprintf(`以下はYouTube動画「%s」の字幕の一部です。字幕の内容だけを根拠に、質問に答えてください。字幕から答えが分からない場合は、分からないと答えてください。
+
+%s
+質問: %s
+
+answer には質問と同じ言語で回答を書き、citations には回答の根拠にした chunk の id を挙げてください。
+出力形式（必ずこのJSONフォーマットのみを出力してください）:
+{
+  "answer": "...",
+  "citations": [0]
+}`, title, b.String(), question)
+
+	resp, err := llmClient.Invoke(ctx, prompt, 2048)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	responseText := llm.StripCodeFence(resp.Text)
+	var out struct {
+		Answer    string `json:"answer"`
+		Citations []int  `json:"citations"`
+	}
+	if err := json.Unmarshal([]byte(responseText), &out); err != nil {
+		return "", nil, nil, fmt.Errorf("failed to parse answer json: %w. Response: %s", err, responseText)
+	}
+	return out.Answer, out.Citations, resp, nil
+}
+
+// getCachedAnswer returns the stored answer to the question with key, if any.
+func getCachedAnswer(ctx context.Context, videoID, key string) (*Answer, error) {
+	resp, err := dynamoClient.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]types.AttributeValue{
+			"hashtag":     &types.AttributeValueMemberS{Value: "ask#" + videoID},
+			"processedAt": &types.AttributeValueMemberS{Value: key},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cached answer: %w", err)
+	}
+	v, ok := resp.Item["answer"].(*types.AttributeValueMemberS)
+	if !ok {
+		return nil, nil
+	}
+	var answer Answer
+	if err := json.Unmarshal([]byte(v.Value), &answer); err != nil {
+		return nil, fmt.Errorf("invalid cached answer: %w", err)
+	}
+	return &answer, nil
+}
+
+func saveAnswer(ctx context.Context, videoID, key string, answer *Answer) error {
+	data, err := json.Marshal(answer)
+	if err != nil {
+		return err
+	}
+	_, err = dynamoClient.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(tableName),
+		Item: map[string]types.AttributeValue{
+			"hashtag":     &types.AttributeValueMemberS{Value: "ask#" + videoID},
+			"processedAt": &types.AttributeValueMemberS{Value: key},
+			"answer":      &types.AttributeValueMemberS{Value: string(data)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to cache answer: %w", err)
+	}
+	return nil
+}
+
+// askVideo answers a question about videoID from its transcript. Answers
+// are cached per video and question.
+func askVideo(ctx context.Context, request events.APIGatewayV2HTTPRequest, videoID string) (events.APIGatewayV2HTTPResponse, error) {
+	ctx = logging.With(ctx, logging.KeyVideoID, videoID)
+	var input struct {
+		Question string `json:"question"`
+	}
+	if err := decodeBody(request, &input); err != nil {
+		return createResponse(400, map[string]string{"error": "body must be JSON with question"})
+	}
+	question := strings.TrimSpace(input.Question)
+	if question == "" || utf8.RuneCountInString(question) > maxQuestionLength {
+		return createResponse(400, map[string]string{"error": fmt.Sprintf("question must be 1 to %d characters", maxQuestionLength)})
+	}
+
+	key := questionKey(question)
+	cached, err := getCachedAnswer(ctx, videoID, key)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error reading cached answer", "error", err)
+	}
+	if cached != nil {
+		cached.Cached = true
+		return createResponse(200, cached)
+	}
+
+	item, err := store.GetVideoItem(ctx, dynamoClient, tableName, videoID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error getting video", "error", err)
+		return createResponse(500, map[string]string{"error": "Internal server error"})
+	}
+	if item == nil {
+		return createResponse(404, map[string]string{"error": "Video not found"})
+	}
+	segments, err := store.TranscriptSegments(ctx, blobStore, item)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error reading transcript", "error", err)
+		return createResponse(500, map[string]string{"error": "Internal server error"})
+	}
+	if len(segments) == 0 {
+		return createResponse(404, map[string]string{"error": "Transcript not found"})
+	}
+	if !transcript.HasTiming(segments) {
+		// Without timing the whole text is one citable chunk
+		segments = []transcript.Segment{{Text: transcript.PlainText(segments)}}
+	}
+
+	if llmBudgetReached(ctx) {
+		return createResponse(503, map[string]string{"error": "Monthly LLM budget reached"})
+	}
+
+	title := ""
+	if v, ok := item["title"].(*types.AttributeValueMemberS); ok {
+		title = v.Value
+	}
+	chunks := transcript.Chunks(segments, askChunkSeconds)
+	picked := relevantChunks(chunks, question, askContextChunks)
+	text, cited, resp, err := askModel(ctx, title, question, chunks, picked)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error answering question", "error", err)
+		return createResponse(502, map[string]string{"error": "Failed to answer the question"})
+	}
+
+	channelID := ""
+	if v, ok := item["hashtag"].(*types.AttributeValueMemberS); ok {
+		channelID = v.Value
+	}
+	if err := llm.NewLedger(dynamoClient, tableName).Record(ctx, channelID, resp.Usage, resp.CostUSD); err != nil {
+		slog.ErrorContext(ctx, "Error recording LLM usage", "error", err)
+	}
+
+	answer := &Answer{
+		Question:  question,
+		Answer:    text,
+		Citations: []Citation{},
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+	for _, i := range cited {
+		// Only chunks that were actually given to the model can be cited
+		if !slices.Contains(picked, i) {
+			continue
+		}
+		c := chunks[i]
+		answer.Citations = append(answer.Citations, Citation{
+			Start:     c.Start,
+			End:       c.End,
+			Timestamp: transcript.FormatTimestamp(c.Start),
+			URL:       transcript.WatchURL(videoID, c.Start),
+			Text:      c.Text,
+		})
+	}
+
+	if err := saveAnswer(ctx, videoID, key, answer); err != nil {
+		slog.ErrorContext(ctx, "Error saving answer", "error", err)
+	}
+	return createResponse(200, answer)
+}
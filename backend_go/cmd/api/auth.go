@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/ttakahashi/youtube-summary/internal/auth"
+)
+
+// routeScope returns the scope a request needs, or "" for routes that check
+// a signature or token of their own.
+func routeScope(method, path string) string {
+	switch {
+	case path == "/api/websub/callback", path == "/api/unsubscribe":
+		return ""
+	case strings.HasPrefix(path, "/api/admin/"), path == "/api/subscriptions":
+		return auth.ScopeAdmin
+	case path == "/api/jobs", strings.HasPrefix(path, "/api/jobs/"):
+		return auth.ScopeJobs
+	case method == "POST" && strings.HasPrefix(path, "/api/summaries/") && strings.HasSuffix(path, "/ask"):
+		return auth.ScopeAsk
+	}
+	return auth.ScopeRead
+}
+
+// anonymousScopes are granted to requests without credentials, so that the
+// frontend can read summaries without a key. AUTH_ANONYMOUS_SCOPES changes
+// them; set it to "none" to require credentials everywhere.
+func anonymousScopes() []string {
+	return cfg.Scopes()
+}
+
+// getJWTSecret returns JWT_SECRET, or the secret named by JWT_SECRET_NAME.
+func getJWTSecret(ctx context.Context) (string, error) {
+	name := cfg.JWTSecretName
+	if name == "" {
+		return cfg.JWTSecret, nil
+	}
+	secret, err := secretStore.Get(ctx, name)
+	if err != nil {
+		return "", fmt.Errorf("failed to get JWT secret: %w", err)
+	}
+	return secret, nil
+}
+
+// authorize authenticates request and checks it may use the route. It
+// returns the client, or the 401 or 403 response to send instead.
+func authorize(ctx context.Context, request events.APIGatewayV2HTTPRequest, path string) (*auth.Principal, *events.APIGatewayV2HTTPResponse) {
+	scope := routeScope(request.RequestContext.HTTP.Method, path)
+
+	authenticator := auth.NewAuthenticator(auth.NewDynamoKeyStore(dynamoClient, tableName), getJWTSecret, cfg.JWTIssuer)
+	principal, err := authenticator.Authenticate(ctx, request.Headers["authorization"], request.Headers["x-api-key"])
+	switch {
+	case errors.Is(err, auth.ErrNoCredentials):
+		principal = &auth.Principal{Kind: "anonymous", Scopes: anonymousScopes()}
+	case errors.Is(err, auth.ErrInvalidCredentials):
+		return nil, unauthorized("Invalid API key or token")
+	case err != nil:
+		slog.ErrorContext(ctx, "Error authenticating request", "error", err)
+		resp, _ := createResponse(500, map[string]string{"error": "Internal server error"})
+		return nil, &resp
+	}
+
+	if scope == "" || principal.Has(scope) {
+		return principal, nil
+	}
+	if principal.Kind == "anonymous" {
+		return nil, unauthorized("Authentication required")
+	}
+	resp, _ := createResponse(403, map[string]string{"error": "Missing scope " + scope})
+	return nil, &resp
+}
+
+func unauthorized(message string) *events.APIGatewayV2HTTPResponse {
+	resp, _ := createResponse(401, map[string]string{"error": message})
+	resp.Headers["WWW-Authenticate"] = `Bearer realm="youtube-summary"`
+	return &resp
+}
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/ttakahashi/youtube-summary/internal/i18n"
+	"github.com/ttakahashi/youtube-summary/internal/logging"
+	"github.com/ttakahashi/youtube-summary/internal/quota"
+	"github.com/ttakahashi/youtube-summary/internal/store"
+	"github.com/ttakahashi/youtube-summary/internal/websub"
+	"google.golang.org/api/option"
+	"google.golang.org/api/youtube/v3"
+)
+
+// Shorts modes the batch accepts; see cmd/batch/shorts.go
+var shortsModes = []string{"summarize", "skip", "digest", "brief"}
+
+// Jobs an admin can start for a single channel; see BatchEvent in cmd/batch
+var channelJobs = []string{"", "refresh-stats", "reconcile", "refresh-comments", "daily-digest", "weekly-digest", "renew-websub"}
+
+var (
+	youtubeMu      sync.Mutex
+	youtubeService *youtube.Service
+)
+
+// getYouTubeService returns a YouTube client with the key in the secret
+// named by YOUTUBE_API_SECRET, created on first use. A failure is not kept,
+// so the next request tries again.
+func getYouTubeService(ctx context.Context) (*youtube.Service, error) {
+	youtubeMu.Lock()
+	defer youtubeMu.Unlock()
+	if youtubeService != nil {
+		return youtubeService, nil
+	}
+	key, err := secretStore.Get(ctx, cfg.YouTubeAPISecret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get YouTube API key: %w", err)
+	}
+	service, err := youtube.NewService(ctx, option.WithAPIKey(key))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create YouTube service: %w", err)
+	}
+	youtubeService = service
+	return youtubeService, nil
+}
+
+// requestChannel returns the channel named by the request's channelId
+// parameter, or CHANNEL_ID when there is none. A named channel must be
+// CHANNEL_ID or one configured through the admin API; anything else gets
+// the returned error response.
+func requestChannel(ctx context.Context, request events.APIGatewayV2HTTPRequest) (string, *events.APIGatewayV2HTTPResponse) {
+	channelID := request.QueryStringParameters["channelId"]
+	if channelID == "" || channelID == cfg.ChannelID {
+		return cfg.ChannelID, nil
+	}
+	channel, err := store.GetChannel(ctx, dynamoClient, tableName, channelID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error getting channel", "error", err)
+		resp, _ := createResponse(500, map[string]string{"error": "Internal server error"})
+		return "", &resp
+	}
+	if channel == nil {
+		resp, _ := createResponse(404, map[string]string{"error": "Channel not found"})
+		return "", &resp
+	}
+	return channel.ID, nil
+}
+
+// parseChannelRef splits a channel given as an ID, @handle or channel URL
+// into an ID or a handle.
+func parseChannelRef(s string) (id, handle string) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "UC") && !strings.Contains(s, "/") {
+		return s, ""
+	}
+	if strings.HasPrefix(s, "@") {
+		return "", s
+	}
+	if !strings.Contains(s, "://") {
+		s = "https://" + s
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return "", ""
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	switch {
+	case len(parts) >= 1 && strings.HasPrefix(parts[0], "@"):
+		return "", parts[0]
+	case len(parts) >= 2 && parts[0] == "channel":
+		return parts[1], ""
+	}
+	return "", ""
+}
+
+var errChannelNotFound = errors.New("channel not found")
+
+// resolveChannel looks up a channel given as an ID, @handle or channel URL.
+func resolveChannel(ctx context.Context, ref string) (*youtube.Channel, error) {
+	id, handle := parseChannelRef(ref)
+	if id == "" && handle == "" {
+		return nil, errChannelNotFound
+	}
+	yt, err := getYouTubeService(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	meter, err := quota.NewMeter(ctx, dynamoClient, tableName, cfg.YouTubeQuota)
+	if err != nil {
+		return nil, err
+	}
+	if err := meter.Charge(ctx, "channels.list"); err != nil {
+		return nil, err
+	}
+
+	call := yt.Channels.List([]string{"snippet"})
+	if handle != "" {
+		call = call.ForHandle(handle)
+	} else {
+		call = call.Id(id)
+	}
+	resp, err := call.Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up channel %s: %w", ref, err)
+	}
+	if len(resp.Items) == 0 {
+		return nil, errChannelNotFound
+	}
+	return resp.Items[0], nil
+}
+
+// channelSettings are the fields an admin can set on a channel. Nil fields
+// are left as they are.
+type channelSettings struct {
+	Paused           *bool     `json:"paused"`
+	ShortsMode       *string   `json:"shortsMode"`
+	SummaryLanguages *[]string `json:"summaryLanguages"`
+	CommentSummary   *bool     `json:"commentSummary"`
+}
+
+// apply validates s and sets it on c.
+func (s *channelSettings) apply(c *store.Channel) error {
+	if s.Paused != nil {
+		c.Paused = *s.Paused
+	}
+	if s.ShortsMode != nil {
+		if *s.ShortsMode != "" && !slices.Contains(shortsModes, *s.ShortsMode) {
+			return fmt.Errorf("shortsMode must be one of %s", strings.Join(shortsModes, ", "))
+		}
+		c.ShortsMode = *s.ShortsMode
+	}
+	if s.SummaryLanguages != nil {
+		var langs []string
+		for _, tag := range *s.SummaryLanguages {
+			lang := i18n.Normalize(tag)
+			if lang == "" {
+				return fmt.Errorf("unsupported language %q", tag)
+			}
+			if !slices.Contains(langs, lang) {
+				langs = append(langs, lang)
+			}
+		}
+		c.SummaryLanguages = langs
+	}
+	if s.CommentSummary != nil {
+		enabled := *s.CommentSummary
+		c.CommentSummary = &enabled
+	}
+	return nil
+}
+
+// addChannel configures the channel given as {"channel": "@handle"}, or as
+// an ID or channel URL, together with optional settings. Once any channel
+// is configured the batch processes configured channels only, not
+// CHANNEL_ID.
+func addChannel(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	var input struct {
+		Channel string `json:"channel"`
+		channelSettings
+	}
+	if err := decodeBody(request, &input); err != nil || input.Channel == "" {
+		return createResponse(400, map[string]string{"error": "body must be JSON with channel"})
+	}
+
+	yc, err := resolveChannel(ctx, input.Channel)
+	if errors.Is(err, errChannelNotFound) {
+		return createResponse(404, map[string]string{"error": "Channel not found"})
+	}
+	if errors.Is(err, quota.ErrBudgetExceeded) {
+		return createResponse(503, map[string]string{"error": "YouTube quota exhausted"})
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Error resolving channel", "channel", input.Channel, "error", err)
+		return createResponse(502, map[string]string{"error": "Failed to look up the channel"})
+	}
+
+	channel, err := store.GetChannel(ctx, dynamoClient, tableName, yc.Id)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error getting channel", "error", err)
+		return createResponse(500, map[string]string{"error": "Internal server error"})
+	}
+	status := 200
+	now := time.Now().UTC().Format(time.RFC3339)
+	if channel == nil {
+		status = 201
+		channel = &store.Channel{ID: yc.Id, CreatedAt: now}
+	}
+	channel.Title = yc.Snippet.Title
+	channel.Handle = yc.Snippet.CustomUrl
+	channel.UpdatedAt = now
+	if err := input.channelSettings.apply(channel); err != nil {
+		return createResponse(400, map[string]string{"error": err.Error()})
+	}
+	if err := store.SaveChannel(ctx, dynamoClient, tableName, channel); err != nil {
+		slog.ErrorContext(ctx, "Error saving channel", "error", err)
+		return createResponse(500, map[string]string{"error": "Internal server error"})
+	}
+	return createResponse(status, channel)
+}
+
+// updateChannel changes the settings of a configured channel, such as
+// pausing it. The batch applies them on its next run.
+func updateChannel(ctx context.Context, request events.APIGatewayV2HTTPRequest, id string) (events.APIGatewayV2HTTPResponse, error) {
+	ctx = logging.With(ctx, logging.KeyChannelID, id)
+	var input channelSettings
+	if err := decodeBody(request, &input); err != nil {
+		return createResponse(400, map[string]string{"error": "body must be JSON"})
+	}
+	channel, err := store.GetChannel(ctx, dynamoClient, tableName, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error getting channel", "error", err)
+		return createResponse(500, map[string]string{"error": "Internal server error"})
+	}
+	if channel == nil {
+		return createResponse(404, map[string]string{"error": "Channel not found"})
+	}
+	if err := input.apply(channel); err != nil {
+		return createResponse(400, map[string]string{"error": err.Error()})
+	}
+	channel.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	if err := store.SaveChannel(ctx, dynamoClient, tableName, channel); err != nil {
+		slog.ErrorContext(ctx, "Error saving channel", "error", err)
+		return createResponse(500, map[string]string{"error": "Internal server error"})
+	}
+	return createResponse(200, channel)
+}
+
+// removeChannel stops processing a channel. Its summaries are kept, and
+// pushes for it are ignored from now on.
+func removeChannel(ctx context.Context, id string) (events.APIGatewayV2HTTPResponse, error) {
+	ctx = logging.With(ctx, logging.KeyChannelID, id)
+	channel, err := store.GetChannel(ctx, dynamoClient, tableName, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error getting channel", "error", err)
+		return createResponse(500, map[string]string{"error": "Internal server error"})
+	}
+	if channel == nil {
+		return createResponse(404, map[string]string{"error": "Channel not found"})
+	}
+	if err := store.DeleteChannel(ctx, dynamoClient, tableName, id); err != nil {
+		slog.ErrorContext(ctx, "Error deleting channel", "error", err)
+		return createResponse(500, map[string]string{"error": "Internal server error"})
+	}
+
+	sub, err := store.GetWebSubSubscription(ctx, dynamoClient, tableName, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error getting WebSub subscription", "error", err)
+	} else if sub != nil {
+		// The lease runs out on its own; the callback confirms an
+		// unsubscribe for it from now on
+		sub.Status = store.WebSubUnsubscribed
+		if err := store.SaveWebSubSubscription(ctx, dynamoClient, tableName, sub); err != nil {
+			slog.ErrorContext(ctx, "Error saving WebSub subscription", "error", err)
+		}
+	}
+	return createResponse(200, map[string]interface{}{"removed": true, "channel": channel})
+}
+
+// startChannelRun starts a batch run, or another job, for one channel right away.
+func startChannelRun(ctx context.Context, request events.APIGatewayV2HTTPRequest, id string) (events.APIGatewayV2HTTPResponse, error) {
+	ctx = logging.With(ctx, logging.KeyChannelID, id)
+	var input struct {
+		Job string `json:"job"`
+	}
+	if request.Body != "" {
+		if err := decodeBody(request, &input); err != nil {
+			return createResponse(400, map[string]string{"error": "body must be JSON"})
+		}
+	}
+	if !slices.Contains(channelJobs, input.Job) {
+		return createResponse(400, map[string]string{"error": fmt.Sprintf("unknown job %q", input.Job)})
+	}
+	channel, err := store.GetChannel(ctx, dynamoClient, tableName, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error getting channel", "error", err)
+		return createResponse(500, map[string]string{"error": "Internal server error"})
+	}
+	if channel == nil {
+		return createResponse(404, map[string]string{"error": "Channel not found"})
+	}
+	if cfg.BatchFunctionName == "" {
+		return createResponse(503, map[string]string{"error": "Batch runs cannot be started from the API"})
+	}
+	if err := triggerBatch(ctx, batchEvent{Job: input.Job, ChannelID: id}); err != nil {
+		slog.ErrorContext(ctx, "Error starting batch", "error", err)
+		return createResponse(502, map[string]string{"error": "Failed to start the batch"})
+	}
+	return createResponse(202, map[string]string{"channelId": id, "job": input.Job, "status": "started"})
+}
+
+// handleAdmin routes /api/admin requests, which need the admin scope.
+func handleAdmin(ctx context.Context, request events.APIGatewayV2HTTPRequest, path string) (events.APIGatewayV2HTTPResponse, error) {
+	method := request.RequestContext.HTTP.Method
+
+	if path == "/api/admin/channels" {
+		switch method {
+		case "GET":
+			channels, err := store.ListChannels(ctx, dynamoClient, tableName)
+			if err != nil {
+				slog.ErrorContext(ctx, "Error listing channels", "error", err)
+				return createResponse(500, map[string]string{"error": "Internal server error"})
+			}
+			return createResponse(200, map[string]interface{}{"channels": channels})
+		case "POST":
+			return addChannel(ctx, request)
+		}
+	}
+
+	if params, ok := matchRoute("/api/admin/channels/{id}/run", path); ok && method == "POST" {
+		return startChannelRun(ctx, request, params["id"])
+	}
+
+	if params, ok := matchRoute("/api/admin/channels/{id}", path); ok {
+		switch method {
+		case "GET":
+			channel, err := store.GetChannel(ctx, dynamoClient, tableName, params["id"])
+			if err != nil {
+				slog.ErrorContext(ctx, "Error getting channel", "error", err)
+				return createResponse(500, map[string]string{"error": "Internal server error"})
+			}
+			if channel == nil {
+				return createResponse(404, map[string]string{"error": "Channel not found"})
+			}
+			sub, err := store.GetWebSubSubscription(ctx, dynamoClient, tableName, channel.ID)
+			if err != nil {
+				slog.ErrorContext(ctx, "Error getting WebSub subscription", "error", err)
+			}
+			return createResponse(200, map[string]interface{}{"channel": channel, "websub": sub, "topic": websub.Topic(channel.ID)})
+		case "PATCH":
+			return updateChannel(ctx, request, params["id"])
+		case "DELETE":
+			return removeChannel(ctx, params["id"])
+		}
+	}
+
+	return createResponse(404, map[string]string{"error": "Not Found"})
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func TestChaptersFromAttribute(t *testing.T) {
+	tests := []struct {
+		name string
+		av   types.AttributeValue
+		want []map[string]interface{}
+	}{
+		{
+			name: "stored chapters",
+			av: &types.AttributeValueMemberL{Value: []types.AttributeValue{
+				&types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+					"start":   &types.AttributeValueMemberN{Value: "0"},
+					"title":   &types.AttributeValueMemberS{Value: "Intro"},
+					"summary": &types.AttributeValueMemberS{Value: "概要"},
+				}},
+				&types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+					"start": &types.AttributeValueMemberN{Value: "3723.5"},
+					"title": &types.AttributeValueMemberS{Value: "まとめ"},
+				}},
+				&types.AttributeValueMemberS{Value: "not a chapter"},
+			}},
+			want: []map[string]interface{}{
+				{
+					"start":     0.0,
+					"timestamp": "0:00",
+					"url":       "https://www.youtube.com/watch?v=abcdefghijk&t=0s",
+					"title":     "Intro",
+					"summary":   "概要",
+				},
+				{
+					"start":     3723.5,
+					"timestamp": "1:02:03",
+					"url":       "https://www.youtube.com/watch?v=abcdefghijk&t=3723s",
+					"title":     "まとめ",
+				},
+			},
+		},
+		{
+			name: "missing",
+			av:   nil,
+			want: []map[string]interface{}{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := chaptersFromAttribute("abcdefghijk", tt.av); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("chaptersFromAttribute = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/ttakahashi/youtube-summary/internal/logging"
+	"github.com/ttakahashi/youtube-summary/internal/store"
+)
+
+var videoIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{11}$`)
+
+// parseVideoID extracts the video ID from a YouTube URL in any of its
+// usual forms, or accepts a bare ID. It returns "" if there is none.
+func parseVideoID(s string) string {
+	s = strings.TrimSpace(s)
+	if videoIDPattern.MatchString(s) {
+		return s
+	}
+	if !strings.Contains(s, "://") {
+		s = "https://" + s
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return ""
+	}
+
+	var id string
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	switch host {
+	case "youtu.be":
+		id = strings.Trim(u.Path, "/")
+	case "youtube.com", "m.youtube.com", "music.youtube.com":
+		if u.Path == "/watch" {
+			id = u.Query().Get("v")
+			break
+		}
+		// /shorts/ID, /live/ID, /embed/ID
+		parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+		if len(parts) == 2 && (parts[0] == "shorts" || parts[0] == "live" || parts[0] == "embed") {
+			id = parts[1]
+		}
+	}
+	if !videoIDPattern.MatchString(id) {
+		return ""
+	}
+	return id
+}
+
+// createJob queues the video named in the request body, given as
+// {"url": "..."} or {"videoId": "..."}. A video that already has a job, or
+// a summary, is not queued again.
+func createJob(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	var input struct {
+		URL     string `json:"url"`
+		VideoID string `json:"videoId"`
+	}
+	if err := decodeBody(request, &input); err != nil {
+		return createResponse(400, map[string]string{"error": "body must be JSON with url or videoId"})
+	}
+	source := input.URL
+	if source == "" {
+		source = input.VideoID
+	}
+	videoID := parseVideoID(source)
+	if videoID == "" {
+		return createResponse(400, map[string]string{"error": "not a YouTube video URL or ID"})
+	}
+	ctx = logging.With(ctx, logging.KeyVideoID, videoID)
+
+	// Videos summarized by a scheduled run finish immediately
+	status := store.JobQueued
+	item, err := store.GetVideoItem(ctx, dynamoClient, tableName, videoID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error checking video", "error", err)
+		return createResponse(500, map[string]string{"error": "Internal server error"})
+	}
+	if store.HasSummary(item) {
+		status = store.JobDone
+	}
+
+	job, created, err := store.CreateJob(ctx, dynamoClient, tableName, videoID, "", source, status)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error creating job", "error", err)
+		return createResponse(500, map[string]string{"error": "Internal server error"})
+	}
+	if !created {
+		return createResponse(200, job)
+	}
+	return createResponse(202, job)
+}
+
+// getJob returns a job, with the summary once it is done.
+func getJob(ctx context.Context, id string) (events.APIGatewayV2HTTPResponse, error) {
+	job, err := store.GetJob(ctx, dynamoClient, tableName, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error getting job", "jobId", id, "error", err)
+		return createResponse(500, map[string]string{"error": "Internal server error"})
+	}
+	if job == nil {
+		return createResponse(404, map[string]string{"error": "Job not found"})
+	}
+	ctx = logging.With(ctx, logging.KeyVideoID, job.VideoID)
+	result := map[string]interface{}{"job": job}
+	if job.Status != store.JobDone {
+		return createResponse(200, result)
+	}
+
+	item, err := store.GetVideoItem(ctx, dynamoClient, tableName, job.VideoID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error getting video", "error", err)
+		return createResponse(500, map[string]string{"error": "Internal server error"})
+	}
+	if item != nil {
+		summary := map[string]interface{}{"videoId": job.VideoID}
+		for _, name := range []string{"title", "channelTitle", "publishedAt", "summary"} {
+			if v, ok := item[name].(*types.AttributeValueMemberS); ok {
+				summary[name] = v.Value
+			}
+		}
+		detail, err := store.DetailSummary(ctx, blobStore, item)
+		if err != nil {
+			slog.ErrorContext(ctx, "Error loading detail summary", "error", err)
+		} else if detail != "" {
+			summary["detailSummary"] = detail
+		}
+		result["summary"] = summary
+	}
+	return createResponse(200, result)
+}
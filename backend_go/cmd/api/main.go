@@ -2,32 +2,93 @@ package main
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"log"
+	"log/slog"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"sort"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	lambdasvc "github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/ttakahashi/youtube-summary/internal/blob"
+	"github.com/ttakahashi/youtube-summary/internal/config"
+	"github.com/ttakahashi/youtube-summary/internal/i18n"
+	"github.com/ttakahashi/youtube-summary/internal/llm"
+	"github.com/ttakahashi/youtube-summary/internal/logging"
+	"github.com/ttakahashi/youtube-summary/internal/secrets"
+	"github.com/ttakahashi/youtube-summary/internal/store"
+	"github.com/ttakahashi/youtube-summary/internal/tags"
+	"github.com/ttakahashi/youtube-summary/internal/transcript"
 )
 
 var (
+	cfg          *config.Config
 	dynamoClient *dynamodb.Client
+	blobStore    blob.Store
 	tableName    string
+
+	// Tag filters are normalized the same way the batch normalizes tags
+	tagVocabulary = tags.DefaultVocabulary
+
+	// Translates summaries into languages the batch did not prepare
+	llmClient *llm.Client
+
+	// Starts batch runs for work that should not wait for the schedule
+	lambdaClient *lambdasvc.Client
+
+	// Holds the YouTube API key the admin API resolves channels with
+	secretStore secrets.Provider
 )
 
-func init() {
-	cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion("ap-northeast-1"))
+// setup creates the clients once the configuration is loaded.
+func setup() {
+	awsCfg, err := awsconfig.LoadDefaultConfig(context.TODO(), awsconfig.WithRegion(cfg.Region))
 	if err != nil {
 		log.Fatalf("unable to load SDK config, %v", err)
 	}
-	dynamoClient = dynamodb.NewFromConfig(cfg)
+	dynamoClient = dynamodb.NewFromConfig(awsCfg)
+	lambdaClient = lambdasvc.NewFromConfig(awsCfg)
+
+	secretStore, err = secrets.Open(context.TODO(), cfg.SecretsBackend, cfg.Region, time.Duration(cfg.SecretsCacheTTL)*time.Second)
+	if err != nil {
+		log.Fatalf("unable to open secrets backend, %v", err)
+	}
+
+	// Offloaded transcripts and summaries are loaded from here
+	blobStore, err = blob.Open(context.TODO(), cfg.BlobStore, cfg.Region)
+	if err != nil {
+		log.Fatalf("unable to open blob store, %v", err)
+	}
+
+	// Both were validated with the configuration
+	if cfg.TagVocabulary != "" {
+		tagVocabulary, _ = tags.ParseVocabulary(cfg.TagVocabulary)
+	}
+
+	// Claude models may only be offered in another region
+	bedrockCfg, err := awsconfig.LoadDefaultConfig(context.TODO(), awsconfig.WithRegion(cfg.BedrockRegion))
+	if err != nil {
+		log.Fatalf("unable to load Bedrock SDK config, %v", err)
+	}
+	prices := llm.DefaultPrices
+	if cfg.PriceTable != "" {
+		prices, _ = llm.ParsePriceTable(cfg.PriceTable)
+	}
+	llmClient = llm.NewClient(bedrockruntime.NewFromConfig(bedrockCfg), cfg.ModelID, prices)
 }
 
 func createResponse(statusCode int, body interface{}) (events.APIGatewayV2HTTPResponse, error) {
@@ -38,15 +99,263 @@ func createResponse(statusCode int, body interface{}) (events.APIGatewayV2HTTPRe
 	return events.APIGatewayV2HTTPResponse{
 		StatusCode: statusCode,
 		Headers: map[string]string{
-			"Content-Type": "application/json",
-            "Access-Control-Allow-Origin": "*",
+			"Content-Type":                "application/json",
+			"Access-Control-Allow-Origin": "*",
 		},
 		Body: string(jsonBody),
 	}, nil
 }
 
-func getSummaries(ctx context.Context, channelID string, limit int) ([]map[string]interface{}, error) {
+// chaptersFromAttribute converts the stored chapter list into JSON-friendly
+// maps, with a link that starts playback at each chapter.
+func chaptersFromAttribute(videoID string, av types.AttributeValue) []map[string]interface{} {
+	chapters := []map[string]interface{}{}
+	list, ok := av.(*types.AttributeValueMemberL)
+	if !ok {
+		return chapters
+	}
+	for _, v := range list.Value {
+		m, ok := v.(*types.AttributeValueMemberM)
+		if !ok {
+			continue
+		}
+		var start float64
+		if n, ok := m.Value["start"].(*types.AttributeValueMemberN); ok {
+			start, _ = strconv.ParseFloat(n.Value, 64)
+		}
+		chapter := map[string]interface{}{
+			"start":     start,
+			"timestamp": transcript.FormatTimestamp(start),
+			"url":       transcript.WatchURL(videoID, start),
+		}
+		if t, ok := m.Value["title"].(*types.AttributeValueMemberS); ok {
+			chapter["title"] = t.Value
+		}
+		if t, ok := m.Value["summary"].(*types.AttributeValueMemberS); ok {
+			chapter["summary"] = t.Value
+		}
+		chapters = append(chapters, chapter)
+	}
+	return chapters
+}
+
+// stringsFromAttribute returns the strings of a stored list.
+func stringsFromAttribute(av types.AttributeValue) []string {
+	values := []string{}
+	if list, ok := av.(*types.AttributeValueMemberL); ok {
+		for _, v := range list.Value {
+			if s, ok := v.(*types.AttributeValueMemberS); ok {
+				values = append(values, s.Value)
+			}
+		}
+	}
+	return values
+}
+
+// commentSummaryFromAttribute converts the stored comment summary into a
+// JSON-friendly map.
+func commentSummaryFromAttribute(m *types.AttributeValueMemberM) map[string]interface{} {
+	result := map[string]interface{}{}
+	for _, name := range []string{"summary", "sentiment"} {
+		if v, ok := m.Value[name].(*types.AttributeValueMemberS); ok {
+			result[name] = v.Value
+		}
+	}
+	for _, name := range []string{"themes", "questions"} {
+		result[name] = stringsFromAttribute(m.Value[name])
+	}
+	if v, ok := m.Value["commentCount"].(*types.AttributeValueMemberN); ok {
+		result["commentCount"] = v.Value
+	}
+	return result
+}
+
+// decodeBody parses the JSON body of request into v.
+func decodeBody(request events.APIGatewayV2HTTPRequest, v interface{}) error {
+	body := []byte(request.Body)
+	if request.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(request.Body)
+		if err != nil {
+			return err
+		}
+		body = decoded
+	}
+	return json.Unmarshal(body, v)
+}
+
+// createTextResponse returns a non-JSON body, such as a transcript download.
+func createTextResponse(statusCode int, contentType, filename, body string) (events.APIGatewayV2HTTPResponse, error) {
+	headers := map[string]string{
+		"Content-Type":                contentType,
+		"Access-Control-Allow-Origin": "*",
+	}
+	if filename != "" {
+		headers["Content-Disposition"] = fmt.Sprintf(`attachment; filename="%s"`, filename)
+	}
+	return events.APIGatewayV2HTTPResponse{
+		StatusCode: statusCode,
+		Headers:    headers,
+		Body:       body,
+	}, nil
+}
+
+// matchRoute matches path against a pattern such as
+// "/api/summaries/{videoId}/transcript" and returns the path parameters.
+func matchRoute(pattern, path string) (map[string]string, bool) {
+	patternParts := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathParts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(patternParts) != len(pathParts) {
+		return nil, false
+	}
+
+	params := map[string]string{}
+	for i, p := range patternParts {
+		if strings.HasPrefix(p, "{") && strings.HasSuffix(p, "}") {
+			if pathParts[i] == "" {
+				return nil, false
+			}
+			params[strings.Trim(p, "{}")] = pathParts[i]
+		} else if p != pathParts[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// transcriptFormats maps the ?format= values to their content types.
+var transcriptFormats = map[string]string{
+	"srt": "application/x-subrip; charset=utf-8",
+	"vtt": "text/vtt; charset=utf-8",
+	"txt": "text/plain; charset=utf-8",
+	"md":  "text/markdown; charset=utf-8",
+}
+
+// getTranscript renders the stored transcript of videoID in format.
+func getTranscript(ctx context.Context, videoID, format string) (events.APIGatewayV2HTTPResponse, error) {
+	ctx = logging.With(ctx, logging.KeyVideoID, videoID)
+	contentType, ok := transcriptFormats[format]
+	if !ok {
+		return createResponse(400, map[string]string{"error": "format must be one of srt, vtt, txt, md"})
+	}
+
+	item, err := store.GetVideoItem(ctx, dynamoClient, tableName, videoID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error getting video", "error", err)
+		return createResponse(500, map[string]string{"error": "Internal server error"})
+	}
+	if item == nil {
+		return createResponse(404, map[string]string{"error": "Not Found"})
+	}
+
+	segments, err := store.TranscriptSegments(ctx, blobStore, item)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error reading transcript", "error", err)
+		return createResponse(500, map[string]string{"error": "Internal server error"})
+	}
+	if len(segments) == 0 {
+		return createResponse(404, map[string]string{"error": "Transcript not available"})
+	}
+
+	title := videoID
+	if v, ok := item["title"].(*types.AttributeValueMemberS); ok {
+		title = v.Value
+	}
+
+	var body string
+	switch format {
+	case "srt", "vtt":
+		if !transcript.HasTiming(segments) {
+			return createResponse(422, map[string]string{"error": "Transcript has no timing information"})
+		}
+		if format == "srt" {
+			body = transcript.SRT(segments)
+		} else {
+			body = transcript.VTT(segments)
+		}
+	case "txt":
+		body = transcript.PlainText(segments) + "\n"
+	case "md":
+		body = transcript.Markdown(segments, title, videoID, 60)
+	}
+
+	return createTextResponse(200, contentType, videoID+"."+format, body)
+}
+
+// offloadedSummary is a summary whose detail text lives in the blob store.
+type offloadedSummary struct {
+	summary map[string]interface{}
+	item    map[string]types.AttributeValue
+}
+
+// loadDetailSummaries fills in offloaded detail summaries, a few at a time.
+// A summary that fails to load is returned without its detail text.
+func loadDetailSummaries(ctx context.Context, offloaded []offloadedSummary) {
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, 8)
+	for _, o := range offloaded {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(o offloadedSummary) {
+			defer func() { <-sem; wg.Done() }()
+			detail, err := store.DetailSummary(ctx, blobStore, o.item)
+			if err != nil {
+				slog.ErrorContext(ctx, "Error loading detail summary", logging.KeyVideoID, o.summary["videoId"], "error", err)
+				return
+			}
+			o.summary["detailSummary"] = detail
+		}(o)
+	}
+	wg.Wait()
+}
+
+// summaryFilter narrows the summaries list.
+type summaryFilter struct {
+	Shorts string // "exclude" or "only"; empty includes everything
+
+	// Deleted, private and region-blocked videos are hidden unless set
+	IncludeUnavailable bool
+
+	Tag      string // tag key, see tags.Key
+	Category string
+}
+
+func (f summaryFilter) match(summary map[string]interface{}) bool {
+	if _, ok := summary["availability"]; ok && !f.IncludeUnavailable {
+		return false
+	}
+	if f.Tag != "" {
+		videoTags, _ := summary["tags"].([]string)
+		if !slices.ContainsFunc(videoTags, func(t string) bool { return tags.Key(t) == f.Tag }) {
+			return false
+		}
+	}
+	if f.Category != "" && summary["topicCategory"] != f.Category {
+		return false
+	}
+	isShort, _ := summary["isShort"].(bool)
+	switch f.Shorts {
+	case "exclude":
+		return !isShort
+	case "only":
+		return isShort
+	}
+	return true
+}
+
+// getSummaries returns channelID's summaries, newest first. Unless lang is
+// empty or the source language, it also returns their translations into
+// lang for translateSummaries.
+func getSummaries(ctx context.Context, channelID string, limit int, filter summaryFilter, lang string) ([]map[string]interface{}, []pendingTranslation, error) {
 	summaries := []map[string]interface{}{}
+	var offloaded []offloadedSummary
+	var translations []pendingTranslation
+	if lang == i18n.Source {
+		lang = ""
+	}
+	projection := "videoId, title, summary, detailSummary, detailSummaryRef, processedAt, publishedAt, channelTitle, viewCount, likeCount, thumbnailUrl, thumbnails, chapters, chaptersSource, liveStatus, scheduledStartTime, actualStartTime, actualEndTime, streamDurationSeconds, durationSeconds, isShort, shortsHandling, statsUpdatedAt, viewGrowthPerHour, availability, unavailableSince, commentSummary, commentSummaryAt, tags, entities, topicCategory"
+	if lang != "" {
+		projection += ", " + i18n.AttributeName(lang)
+	}
 	var lastEvaluatedKey map[string]types.AttributeValue
 
 	for {
@@ -59,7 +368,7 @@ func getSummaries(ctx context.Context, channelID string, limit int) ([]map[strin
 			},
 			ExclusiveStartKey: lastEvaluatedKey,
 			// Exclude transcript to save bandwidth and avoid hitting 1MB limit early
-			ProjectionExpression: aws.String("videoId, title, summary, detailSummary, processedAt, publishedAt, channelTitle, viewCount, likeCount, thumbnailUrl, thumbnails"),
+			ProjectionExpression: aws.String(projection),
 		}
 
 		if limit > 0 {
@@ -72,7 +381,7 @@ func getSummaries(ctx context.Context, channelID string, limit int) ([]map[strin
 
 		resp, err := dynamoClient.Query(ctx, input)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		for _, item := range resp.Items {
@@ -104,6 +413,61 @@ func getSummaries(ctx context.Context, channelID string, limit int) ([]map[strin
 			if v, ok := item["likeCount"].(*types.AttributeValueMemberN); ok {
 				summary["likeCount"] = v.Value
 			}
+			if v, ok := item["statsUpdatedAt"].(*types.AttributeValueMemberS); ok {
+				summary["statsUpdatedAt"] = v.Value
+			}
+			if v, ok := item["viewGrowthPerHour"].(*types.AttributeValueMemberN); ok {
+				summary["viewGrowthPerHour"] = v.Value
+			}
+			for _, name := range []string{"liveStatus", "scheduledStartTime", "actualStartTime", "actualEndTime"} {
+				if v, ok := item[name].(*types.AttributeValueMemberS); ok {
+					summary[name] = v.Value
+				}
+			}
+			if v, ok := item["streamDurationSeconds"].(*types.AttributeValueMemberN); ok {
+				summary["streamDurationSeconds"] = v.Value
+			}
+			if v, ok := item["durationSeconds"].(*types.AttributeValueMemberN); ok {
+				summary["durationSeconds"] = v.Value
+			}
+			if v, ok := item["isShort"].(*types.AttributeValueMemberBOOL); ok {
+				summary["isShort"] = v.Value
+			}
+			if v, ok := item["shortsHandling"].(*types.AttributeValueMemberS); ok {
+				summary["shortsHandling"] = v.Value
+			}
+			for _, name := range []string{"availability", "unavailableSince"} {
+				if v, ok := item[name].(*types.AttributeValueMemberS); ok {
+					summary[name] = v.Value
+				}
+			}
+			if v, ok := item["chapters"]; ok {
+				videoID, _ := summary["videoId"].(string)
+				summary["chapters"] = chaptersFromAttribute(videoID, v)
+			}
+			if v, ok := item["chaptersSource"].(*types.AttributeValueMemberS); ok {
+				summary["chaptersSource"] = v.Value
+			}
+			if v, ok := item["tags"]; ok {
+				summary["tags"] = stringsFromAttribute(v)
+			}
+			if v, ok := item["entities"].(*types.AttributeValueMemberM); ok {
+				entities := map[string][]string{}
+				for _, kind := range []string{"people", "organizations", "products"} {
+					entities[kind] = stringsFromAttribute(v.Value[kind])
+				}
+				summary["entities"] = entities
+			}
+			if v, ok := item["topicCategory"].(*types.AttributeValueMemberS); ok {
+				summary["topicCategory"] = v.Value
+			}
+			if v, ok := item["commentSummary"].(*types.AttributeValueMemberM); ok {
+				comments := commentSummaryFromAttribute(v)
+				if at, ok := item["commentSummaryAt"].(*types.AttributeValueMemberS); ok {
+					comments["updatedAt"] = at.Value
+				}
+				summary["commentSummary"] = comments
+			}
 			if v, ok := item["thumbnailUrl"].(*types.AttributeValueMemberS); ok {
 				summary["thumbnails"] = map[string]interface{}{
 					"medium": map[string]string{"url": v.Value},
@@ -114,6 +478,15 @@ func getSummaries(ctx context.Context, channelID string, limit int) ([]map[strin
 				}
 			}
 
+			if !filter.match(summary) {
+				continue
+			}
+			if _, ok := item["detailSummaryRef"]; ok {
+				offloaded = append(offloaded, offloadedSummary{summary: summary, item: item})
+			}
+			if lang != "" {
+				translations = append(translations, pendingTranslation{summary: summary, item: item})
+			}
 			summaries = append(summaries, summary)
 		}
 
@@ -123,6 +496,8 @@ func getSummaries(ctx context.Context, channelID string, limit int) ([]map[strin
 		lastEvaluatedKey = resp.LastEvaluatedKey
 	}
 
+	loadDetailSummaries(ctx, offloaded)
+
 	// Sort summaries by publishedAt descending (newest first)
 	sort.Slice(summaries, func(i, j int) bool {
 		p1, _ := summaries[i]["publishedAt"].(string)
@@ -130,24 +505,223 @@ func getSummaries(ctx context.Context, channelID string, limit int) ([]map[strin
 		return p1 > p2
 	})
 
-	return summaries, nil
+	return summaries, translations, nil
+}
+
+// sortTrending orders summaries by view growth rate, fastest first. Videos
+// whose statistics were never refreshed come last, newest first.
+func sortTrending(summaries []map[string]interface{}) {
+	growth := func(summary map[string]interface{}) float64 {
+		v, _ := summary["viewGrowthPerHour"].(string)
+		g, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return -1
+		}
+		return g
+	}
+	sort.SliceStable(summaries, func(i, j int) bool {
+		return growth(summaries[i]) > growth(summaries[j])
+	})
+}
+
+// tagCount is how many visible videos carry a tag or category.
+type tagCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// getTags counts the tags and topic categories of channelID's videos,
+// most used first. Unavailable videos are not counted.
+func getTags(ctx context.Context, channelID string) (map[string]interface{}, error) {
+	tagCounts := map[string]*tagCount{}
+	categoryCounts := map[string]*tagCount{}
+	var lastEvaluatedKey map[string]types.AttributeValue
+
+	for {
+		resp, err := dynamoClient.Query(ctx, &dynamodb.QueryInput{
+			TableName:              aws.String(tableName),
+			KeyConditionExpression: aws.String("hashtag = :h"),
+			FilterExpression:       aws.String("attribute_not_exists(availability)"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":h": &types.AttributeValueMemberS{Value: channelID},
+			},
+			ProjectionExpression: aws.String("tags, topicCategory"),
+			ExclusiveStartKey:    lastEvaluatedKey,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range resp.Items {
+			for _, t := range stringsFromAttribute(item["tags"]) {
+				key := tags.Key(t)
+				if _, ok := tagCounts[key]; !ok {
+					tagCounts[key] = &tagCount{Name: t}
+				}
+				tagCounts[key].Count++
+			}
+			if v, ok := item["topicCategory"].(*types.AttributeValueMemberS); ok {
+				if _, ok := categoryCounts[v.Value]; !ok {
+					categoryCounts[v.Value] = &tagCount{Name: v.Value}
+				}
+				categoryCounts[v.Value].Count++
+			}
+		}
+
+		if resp.LastEvaluatedKey == nil {
+			break
+		}
+		lastEvaluatedKey = resp.LastEvaluatedKey
+	}
+
+	sorted := func(counts map[string]*tagCount) []tagCount {
+		list := []tagCount{}
+		for _, c := range counts {
+			list = append(list, *c)
+		}
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].Count != list[j].Count {
+				return list[i].Count > list[j].Count
+			}
+			return list[i].Name < list[j].Name
+		})
+		return list
+	}
+	return map[string]interface{}{
+		"channelId":  channelID,
+		"tags":       sorted(tagCounts),
+		"categories": sorted(categoryCounts),
+	}, nil
+}
+
+// getStatsHistory returns the statistics snapshots of videoID, oldest first.
+func getStatsHistory(ctx context.Context, videoID string) ([]map[string]string, error) {
+	snapshots := []map[string]string{}
+	var lastEvaluatedKey map[string]types.AttributeValue
+
+	for {
+		resp, err := dynamoClient.Query(ctx, &dynamodb.QueryInput{
+			TableName:              aws.String(tableName),
+			KeyConditionExpression: aws.String("hashtag = :h"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":h": &types.AttributeValueMemberS{Value: "stats#" + videoID},
+			},
+			ExclusiveStartKey: lastEvaluatedKey,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range resp.Items {
+			snapshot := map[string]string{}
+			if v, ok := item["processedAt"].(*types.AttributeValueMemberS); ok {
+				snapshot["at"] = v.Value
+			}
+			for _, name := range []string{"viewCount", "likeCount", "commentCount"} {
+				if v, ok := item[name].(*types.AttributeValueMemberN); ok {
+					snapshot[name] = v.Value
+				}
+			}
+			snapshots = append(snapshots, snapshot)
+		}
+
+		if resp.LastEvaluatedKey == nil {
+			return snapshots, nil
+		}
+		lastEvaluatedKey = resp.LastEvaluatedKey
+	}
 }
 
-func handler(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
-	tableName = os.Getenv("DYNAMODB_TABLE")
-	if tableName == "" {
-		tableName = "youtube-summary-dev"
+// getCosts aggregates the LLM cost ledger between from and to (inclusive).
+// An empty channelID includes every channel.
+func getCosts(ctx context.Context, from, to, channelID string) (map[string]interface{}, error) {
+	ledger := llm.NewLedger(dynamoClient, tableName)
+	days, err := ledger.Range(ctx, from, to)
+	if err != nil {
+		return nil, err
 	}
 
-	// Get channel ID from environment
-	channelID := os.Getenv("CHANNEL_ID")
-	if channelID == "" {
-		channelID = "UC2kM01yXNnouBsJJ0ghyfMg" // @noiehoie default
+	filtered := []llm.DailyCost{}
+	byChannel := map[string]*llm.DailyCost{}
+	total := llm.DailyCost{}
+	for _, d := range days {
+		if channelID != "" && d.ChannelID != channelID {
+			continue
+		}
+		filtered = append(filtered, d)
+
+		c, ok := byChannel[d.ChannelID]
+		if !ok {
+			c = &llm.DailyCost{ChannelID: d.ChannelID}
+			byChannel[d.ChannelID] = c
+		}
+		for _, acc := range []*llm.DailyCost{c, &total} {
+			acc.InputTokens += d.InputTokens
+			acc.OutputTokens += d.OutputTokens
+			acc.CostUSD += d.CostUSD
+			acc.Calls += d.Calls
+		}
 	}
 
+	monthToDate, err := ledger.MonthToDate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string]interface{}{
+		"from":        from,
+		"to":          to,
+		"days":        filtered,
+		"byChannel":   byChannel,
+		"total":       total,
+		"monthToDate": monthToDate,
+	}
+	if cfg.MonthlyBudgetUSD > 0 {
+		result["monthlyBudgetUsd"] = cfg.MonthlyBudgetUSD
+	}
+	return result, nil
+}
+
+func handler(ctx context.Context, request events.APIGatewayV2HTTPRequest) (resp events.APIGatewayV2HTTPResponse, err error) {
+	tableName = cfg.Table
+
+	// API Gateway's request ID ties our lines to its access log
 	path := request.RawPath
+	ctx = logging.With(ctx, logging.KeyRequestID, request.RequestContext.RequestID, logging.KeyStage, "api")
+	start := time.Now()
+	defer func() {
+		slog.InfoContext(ctx, "Request handled",
+			"method", request.RequestContext.HTTP.Method,
+			"path", path,
+			"status", resp.StatusCode,
+			"durationMs", time.Since(start).Milliseconds(),
+			"error", err)
+	}()
 
+	principal, denied := authorize(ctx, request, path)
+	if denied != nil {
+		return *denied, nil
+	}
+	if principal.Subject != "" {
+		ctx = logging.With(ctx, "client", principal.Kind+":"+principal.Subject)
+	}
+	limit, denied := rateLimit(ctx, request, principal, path)
+	if denied != nil {
+		return *denied, nil
+	}
+
+	resp, err = route(ctx, request, path)
+	setRateLimitHeaders(&resp, limit)
+	return resp, err
+}
+
+// route dispatches an authorized request.
+func route(ctx context.Context, request events.APIGatewayV2HTTPRequest, path string) (events.APIGatewayV2HTTPResponse, error) {
 	if path == "/api/summaries" {
+		channelID, denied := requestChannel(ctx, request)
+		if denied != nil {
+			return *denied, nil
+		}
 		limitStr := request.QueryStringParameters["limit"]
 		limit := 0 // 0 means unlimited
 		if limitStr != "" {
@@ -156,22 +730,220 @@ func handler(ctx context.Context, request events.APIGatewayV2HTTPRequest) (event
 			}
 		}
 
-		summaries, err := getSummaries(ctx, channelID, limit)
+		filter := summaryFilter{
+			Shorts:             request.QueryStringParameters["shorts"],
+			IncludeUnavailable: request.QueryStringParameters["includeUnavailable"] == "true",
+		}
+		if tag := request.QueryStringParameters["tag"]; tag != "" {
+			filter.Tag = tags.Key(tagVocabulary.Normalize(tag))
+		}
+		if category := request.QueryStringParameters["category"]; category != "" {
+			if tags.Category(category) != strings.ToLower(category) {
+				return createResponse(400, map[string]string{"error": "unknown category"})
+			}
+			filter.Category = strings.ToLower(category)
+		}
+		if filter.Shorts != "" && filter.Shorts != "exclude" && filter.Shorts != "only" {
+			return createResponse(400, map[string]string{"error": "shorts must be exclude or only"})
+		}
+
+		// An explicit lang wins over Accept-Language
+		lang := i18n.FromAcceptLanguage(request.Headers["accept-language"])
+		if v := request.QueryStringParameters["lang"]; v != "" {
+			if lang = i18n.Normalize(v); lang == "" {
+				return createResponse(400, map[string]string{"error": "unsupported lang"})
+			}
+		}
+
+		order := request.QueryStringParameters["sort"]
+		if order != "" && order != "newest" && order != "trending" {
+			return createResponse(400, map[string]string{"error": "sort must be newest or trending"})
+		}
+
+		// Trending needs every summary before the limit is applied
+		queryLimit := limit
+		if order == "trending" {
+			queryLimit = 0
+		}
+		summaries, translations, err := getSummaries(ctx, channelID, queryLimit, filter, lang)
 		if err != nil {
-			log.Printf("Error getting summaries: %v", err)
+			slog.ErrorContext(ctx, "Error getting summaries", "error", err)
 			return createResponse(500, map[string]string{"error": "Internal server error"})
 		}
+		if order == "trending" {
+			sortTrending(summaries)
+			if limit > 0 && len(summaries) > limit {
+				summaries = summaries[:limit]
+			}
+		}
+		if lang != "" {
+			// Only the summaries returned are worth translating
+			translateSummaries(ctx, channelID, lang, pendingOf(summaries, translations))
+		}
 
+		if lang == "" {
+			lang = i18n.Source
+		}
 		return createResponse(200, map[string]interface{}{
 			"channelId": channelID,
+			"language":  lang,
 			"count":     len(summaries),
 			"summaries": summaries,
 		})
 	}
 
+	if params, ok := matchRoute("/api/summaries/{videoId}/transcript", path); ok {
+		format := request.QueryStringParameters["format"]
+		if format == "" {
+			format = "txt"
+		}
+		return getTranscript(ctx, params["videoId"], format)
+	}
+
+	if path == "/api/jobs" && request.RequestContext.HTTP.Method == "POST" {
+		return createJob(ctx, request)
+	}
+
+	if params, ok := matchRoute("/api/jobs/{id}", path); ok {
+		return getJob(ctx, params["id"])
+	}
+
+	if strings.HasPrefix(path, "/api/admin/") {
+		return handleAdmin(ctx, request, path)
+	}
+
+	if path == "/api/websub/callback" {
+		if request.RequestContext.HTTP.Method == "POST" {
+			return websubNotify(ctx, request)
+		}
+		return websubVerify(ctx, request)
+	}
+
+	if path == "/api/subscriptions" && request.RequestContext.HTTP.Method == "POST" {
+		return subscribe(ctx, request, cfg.ChannelID)
+	}
+
+	if path == "/api/unsubscribe" {
+		return unsubscribe(ctx, request)
+	}
+
+	if path == "/api/digests" {
+		digestChannel := request.QueryStringParameters["channelId"]
+		if digestChannel == "" {
+			digestChannel = store.DigestAllChannels
+		}
+		limit := 7
+		if l, err := strconv.Atoi(request.QueryStringParameters["limit"]); err == nil && l > 0 {
+			limit = l
+		}
+		result := map[string]interface{}{"channelId": digestChannel}
+		for _, period := range []string{store.DigestDaily, store.DigestWeekly} {
+			digests, err := store.ListDigests(ctx, dynamoClient, tableName, period, digestChannel, "", limit)
+			if err != nil {
+				slog.ErrorContext(ctx, "Error listing digests", "error", err)
+				return createResponse(500, map[string]string{"error": "Internal server error"})
+			}
+			result[period] = digests
+		}
+		return createResponse(200, result)
+	}
+
+	if params, ok := matchRoute("/api/digests/{period}", path); ok {
+		period := params["period"]
+		if period != store.DigestDaily && period != store.DigestWeekly {
+			return createResponse(400, map[string]string{"error": "period must be daily or weekly"})
+		}
+		digestChannel := request.QueryStringParameters["channelId"]
+		if digestChannel == "" {
+			digestChannel = store.DigestAllChannels
+		}
+		date := request.QueryStringParameters["date"]
+		if date != "" {
+			if _, err := time.Parse("2006-01-02", date); err != nil {
+				return createResponse(400, map[string]string{"error": "date must be YYYY-MM-DD"})
+			}
+		}
+		digests, err := store.ListDigests(ctx, dynamoClient, tableName, period, digestChannel, date, 1)
+		if err != nil {
+			slog.ErrorContext(ctx, "Error getting digest", "error", err)
+			return createResponse(500, map[string]string{"error": "Internal server error"})
+		}
+		if len(digests) == 0 {
+			return createResponse(404, map[string]string{"error": "Digest not found"})
+		}
+		return createResponse(200, digests[0])
+	}
+
+	if path == "/api/tags" {
+		channelID, denied := requestChannel(ctx, request)
+		if denied != nil {
+			return *denied, nil
+		}
+		result, err := getTags(ctx, channelID)
+		if err != nil {
+			slog.ErrorContext(ctx, "Error getting tags", "error", err)
+			return createResponse(500, map[string]string{"error": "Internal server error"})
+		}
+		return createResponse(200, result)
+	}
+
+	if params, ok := matchRoute("/api/summaries/{videoId}/ask", path); ok && request.RequestContext.HTTP.Method == "POST" {
+		return askVideo(ctx, request, params["videoId"])
+	}
+
+	if params, ok := matchRoute("/api/summaries/{videoId}/stats", path); ok {
+		snapshots, err := getStatsHistory(ctx, params["videoId"])
+		if err != nil {
+			slog.ErrorContext(ctx, "Error getting stats history", "error", err)
+			return createResponse(500, map[string]string{"error": "Internal server error"})
+		}
+		return createResponse(200, map[string]interface{}{
+			"videoId":   params["videoId"],
+			"count":     len(snapshots),
+			"snapshots": snapshots,
+		})
+	}
+
+	if path == "/api/stats/costs" {
+		// Defaults to the last 30 days
+		now := time.Now().UTC()
+		from := request.QueryStringParameters["from"]
+		if from == "" {
+			from = now.AddDate(0, 0, -29).Format("2006-01-02")
+		}
+		to := request.QueryStringParameters["to"]
+		if to == "" {
+			to = now.Format("2006-01-02")
+		}
+		for _, d := range []string{from, to} {
+			if _, err := time.Parse("2006-01-02", d); err != nil {
+				return createResponse(400, map[string]string{"error": "from and to must be YYYY-MM-DD"})
+			}
+		}
+
+		costs, err := getCosts(ctx, from, to, request.QueryStringParameters["channelId"])
+		if err != nil {
+			slog.ErrorContext(ctx, "Error getting costs", "error", err)
+			return createResponse(500, map[string]string{"error": "Internal server error"})
+		}
+		return createResponse(200, costs)
+	}
+
 	return createResponse(404, map[string]string{"error": "Not Found"})
 }
 
 func main() {
+	var args []string
+	cfg, args = config.MustLoad()
+	if len(args) == 2 && args[0] == "config" && args[1] == "print" {
+		if err := cfg.Print(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	level, _ := logging.ParseLevel(cfg.LogLevel)
+	logging.Setup(os.Stdout, level)
+	setup()
+
 	lambda.Start(handler)
 }
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/ttakahashi/youtube-summary/internal/auth"
+	"github.com/ttakahashi/youtube-summary/internal/ratelimit"
+)
+
+var (
+	// Buckets of the "memory" mode live as long as the Lambda instance
+	memoryLimiter = ratelimit.NewMemoryLimiter()
+
+	limitsOnce sync.Once
+	limits     = ratelimit.DefaultLimits
+)
+
+// rateLimits returns the limits per route class, from RATE_LIMITS if set.
+func rateLimits() map[string]ratelimit.Limit {
+	limitsOnce.Do(func() {
+		if cfg.RateLimits != "" {
+			// Validated with the configuration
+			limits, _ = ratelimit.ParseLimits(cfg.RateLimits)
+		}
+	})
+	return limits
+}
+
+// rateLimiter returns the limiter RATE_LIMIT_MODE selects: "dynamodb" (the
+// default) shared by all instances, "memory" per Lambda instance, or "off".
+func rateLimiter() ratelimit.Limiter {
+	switch cfg.RateLimitMode {
+	case "off":
+		return nil
+	case "memory":
+		return memoryLimiter
+	}
+	return ratelimit.NewDynamoLimiter(dynamoClient, tableName)
+}
+
+// clientKey identifies the client a bucket belongs to: its API key or token
+// subject, or its source IP when anonymous.
+func clientKey(request events.APIGatewayV2HTTPRequest, principal *auth.Principal) string {
+	switch principal.Kind {
+	case "api_key":
+		return "key:" + principal.Subject
+	case "jwt":
+		return "jwt:" + principal.Subject
+	}
+	return "ip:" + request.RequestContext.HTTP.SourceIP
+}
+
+// rateLimit takes a token from the client's bucket for the route's class.
+// It returns the result to report in headers, or the 429 response to send
+// instead. Routes without a scope are not limited, and a failing limiter
+// lets requests through.
+func rateLimit(ctx context.Context, request events.APIGatewayV2HTTPRequest, principal *auth.Principal, path string) (*ratelimit.Result, *events.APIGatewayV2HTTPResponse) {
+	class := routeScope(request.RequestContext.HTTP.Method, path)
+	limiter := rateLimiter()
+	if class == "" || limiter == nil {
+		return nil, nil
+	}
+	limit, ok := rateLimits()[class]
+	if !ok {
+		return nil, nil
+	}
+
+	result, err := limiter.Take(ctx, class+"#"+clientKey(request, principal), limit, time.Now())
+	if err != nil {
+		slog.ErrorContext(ctx, "Error applying rate limit", "error", err)
+		return nil, nil
+	}
+	if result.Allowed {
+		return &result, nil
+	}
+	resp, _ := createResponse(429, map[string]string{"error": "Rate limit exceeded"})
+	setRateLimitHeaders(&resp, &result)
+	resp.Headers["Retry-After"] = strconv.Itoa(int(result.RetryAfter.Seconds()))
+	return nil, &resp
+}
+
+// setRateLimitHeaders reports the client's bucket in the RateLimit headers
+// of the IETF draft.
+func setRateLimitHeaders(resp *events.APIGatewayV2HTTPResponse, r *ratelimit.Result) {
+	if r == nil {
+		return
+	}
+	if resp.Headers == nil {
+		resp.Headers = map[string]string{}
+	}
+	resp.Headers["RateLimit-Limit"] = strconv.Itoa(r.Limit.Burst)
+	resp.Headers["RateLimit-Remaining"] = strconv.Itoa(r.Remaining)
+	resp.Headers["RateLimit-Reset"] = strconv.Itoa(int(r.Reset.Seconds()))
+	window := int(float64(r.Limit.Burst) / r.Limit.PerMinute * 60)
+	resp.Headers["RateLimit-Policy"] = strconv.Itoa(r.Limit.Burst) + ";w=" + strconv.Itoa(window)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"html/template"
+	"log/slog"
+	netmail "net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/ttakahashi/youtube-summary/internal/mail"
+	"github.com/ttakahashi/youtube-summary/internal/store"
+)
+
+// subscribe adds an email subscription to a channel's summaries or to its
+// daily or weekly digests. Subscribing again changes the delivery and
+// replaces the unsubscribe token.
+func subscribe(ctx context.Context, request events.APIGatewayV2HTTPRequest, defaultChannelID string) (events.APIGatewayV2HTTPResponse, error) {
+	var input struct {
+		Email     string `json:"email"`
+		ChannelID string `json:"channelId"`
+		Delivery  string `json:"delivery"`
+	}
+	if err := decodeBody(request, &input); err != nil {
+		return createResponse(400, map[string]string{"error": "body must be JSON with email"})
+	}
+	addr, err := netmail.ParseAddress(input.Email)
+	if err != nil {
+		return createResponse(400, map[string]string{"error": "invalid email address"})
+	}
+	if input.ChannelID == "" {
+		input.ChannelID = defaultChannelID
+	}
+	switch input.Delivery {
+	case "":
+		input.Delivery = store.DeliverSummaries
+	case store.DeliverSummaries, store.DigestDaily, store.DigestWeekly:
+	default:
+		return createResponse(400, map[string]string{"error": "delivery must be summaries, daily or weekly"})
+	}
+	if input.Delivery == store.DeliverSummaries && input.ChannelID == store.DigestAllChannels {
+		return createResponse(400, map[string]string{"error": "summaries are delivered per channel"})
+	}
+
+	s := &store.Subscriber{
+		ChannelID: input.ChannelID,
+		Email:     addr.Address,
+		Delivery:  input.Delivery,
+		Token:     mail.NewToken(),
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+	if err := store.SaveSubscriber(ctx, dynamoClient, tableName, s); err != nil {
+		slog.ErrorContext(ctx, "Error saving subscriber", "error", err)
+		return createResponse(500, map[string]string{"error": "Internal server error"})
+	}
+	return createResponse(201, s)
+}
+
+// unsubscribeConfirmPage is shown when the link in an email is opened, so
+// that link scanners following it do not unsubscribe anyone.
+var unsubscribeConfirmPage = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html lang="ja">
+<head><meta charset="UTF-8"><title>配信停止</title></head>
+<body style="font-family: sans-serif; max-width: 640px; margin: 0 auto;">
+  <p>{{.Email}} への配信を停止しますか?</p>
+  <form method="post" action="{{.Action}}"><button type="submit">配信を停止する</button></form>
+</body>
+</html>
+`))
+
+// unsubscribe removes the subscription named by the query of the link in
+// every email. Opening the link with GET only asks for confirmation; the
+// subscription is removed by POST, from that page or from a mail client's
+// one-click unsubscribe.
+func unsubscribe(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	q := request.QueryStringParameters
+	if q["channelId"] == "" || q["email"] == "" || q["token"] == "" {
+		return createResponse(400, map[string]string{"error": "channelId, email and token are required"})
+	}
+	if request.RequestContext.HTTP.Method != "POST" {
+		query := url.Values{"channelId": {q["channelId"]}, "email": {q["email"]}, "token": {q["token"]}}
+		var page strings.Builder
+		if err := unsubscribeConfirmPage.Execute(&page, map[string]string{"Email": q["email"], "Action": "?" + query.Encode()}); err != nil {
+			slog.ErrorContext(ctx, "Error rendering unsubscribe page", "error", err)
+			return createResponse(500, map[string]string{"error": "Internal server error"})
+		}
+		return createTextResponse(200, "text/html; charset=utf-8", "", page.String())
+	}
+	removed, err := store.RemoveSubscriber(ctx, dynamoClient, tableName, q["channelId"], q["email"], q["token"])
+	if err != nil {
+		slog.ErrorContext(ctx, "Error removing subscriber", "error", err)
+		return createResponse(500, map[string]string{"error": "Internal server error"})
+	}
+	if !removed {
+		return createResponse(404, map[string]string{"error": "Subscription not found"})
+	}
+	return createResponse(200, map[string]bool{"unsubscribed": true})
+}
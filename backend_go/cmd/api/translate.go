@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/ttakahashi/youtube-summary/internal/blob"
+	"github.com/ttakahashi/youtube-summary/internal/i18n"
+	"github.com/ttakahashi/youtube-summary/internal/llm"
+	"github.com/ttakahashi/youtube-summary/internal/logging"
+)
+
+// pendingTranslation is a summary to be shown in another language.
+type pendingTranslation struct {
+	summary map[string]interface{}
+	item    map[string]types.AttributeValue
+}
+
+// pendingOf returns the translations in pending of the given summaries.
+func pendingOf(summaries []map[string]interface{}, pending []pendingTranslation) []pendingTranslation {
+	kept := map[string]bool{}
+	for _, s := range summaries {
+		if id, ok := s["videoId"].(string); ok {
+			kept[id] = true
+		}
+	}
+	var out []pendingTranslation
+	for _, p := range pending {
+		if id, ok := p.summary["videoId"].(string); ok && kept[id] {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// translateSummaries replaces the summaries with their translations into
+// lang. Stored translations are used as they are; missing ones are made
+// with the LLM, a few per call, and cached on the item. Summaries left
+// untranslated keep their "language" as i18n.Source.
+func translateSummaries(ctx context.Context, channelID, lang string, pending []pendingTranslation) {
+	// Translations are made in parallel so that the call stays within the
+	// API Gateway timeout. The rest are returned in the source language
+	// until a later call translates them.
+	maxNew := cfg.TranslateMax
+	if llmBudgetReached(ctx) {
+		maxNew = 0
+	}
+	ledger := llm.NewLedger(dynamoClient, tableName)
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, 4)
+	for _, p := range pending {
+		p.summary["language"] = i18n.Source
+		t, stored := i18n.FromItem(p.item, lang)
+		if !stored {
+			if maxNew <= 0 {
+				continue
+			}
+			maxNew--
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(p pendingTranslation, t *i18n.Translation) {
+			defer func() { <-sem; wg.Done() }()
+			var err error
+			if t != nil {
+				err = loadTranslatedDetail(ctx, t)
+			} else {
+				t, err = translateItem(ctx, channelID, lang, p, ledger)
+			}
+			if err != nil {
+				slog.ErrorContext(ctx, "Error translating summary", logging.KeyVideoID, p.summary["videoId"], "language", lang, "error", err)
+				return
+			}
+			p.summary["summary"] = t.ShortSummary
+			p.summary["detailSummary"] = t.DetailSummary
+			p.summary["language"] = lang
+		}(p, t)
+	}
+	wg.Wait()
+}
+
+// loadTranslatedDetail loads an offloaded translated detail summary.
+func loadTranslatedDetail(ctx context.Context, t *i18n.Translation) error {
+	if t.DetailSummaryRef == "" {
+		return nil
+	}
+	if blobStore == nil {
+		return fmt.Errorf("translation is offloaded to %s but no blob store is configured", t.DetailSummaryRef)
+	}
+	data, err := blob.GetCompressed(ctx, blobStore, t.DetailSummaryRef)
+	if err != nil {
+		return err
+	}
+	t.DetailSummary = string(data)
+	return nil
+}
+
+// translateItem translates a summary and caches the translation on its item.
+func translateItem(ctx context.Context, channelID, lang string, p pendingTranslation, ledger *llm.Ledger) (*i18n.Translation, error) {
+	shortSummary, _ := p.summary["summary"].(string)
+	detailSummary, _ := p.summary["detailSummary"].(string)
+	t, err := i18n.Translate(ctx, llmClient, lang, shortSummary, detailSummary)
+	if err != nil {
+		return nil, err
+	}
+	if err := ledger.Record(ctx, channelID, t.Usage, t.CostUSD); err != nil {
+		slog.ErrorContext(ctx, "Error recording LLM usage", "error", err)
+	}
+
+	_, err = dynamoClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]types.AttributeValue{
+			"hashtag":     &types.AttributeValueMemberS{Value: channelID},
+			"processedAt": p.item["processedAt"],
+		},
+		UpdateExpression:    aws.String("SET #t = :t"),
+		ConditionExpression: aws.String("attribute_exists(videoId)"),
+		ExpressionAttributeNames: map[string]string{
+			"#t": i18n.AttributeName(lang),
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":t": t.Attribute(),
+		},
+	})
+	if err != nil {
+		// The translation is still good for this response
+		slog.ErrorContext(ctx, "Error caching translation", "language", lang, "error", err)
+	}
+	return t, nil
+}
+
+// llmBudgetReached reports whether this month's LLM spend has reached
+// LLM_MONTHLY_BUDGET_USD.
+func llmBudgetReached(ctx context.Context) bool {
+	ceiling := cfg.MonthlyBudgetUSD
+	if ceiling <= 0 {
+		return false
+	}
+	monthToDate, err := llm.NewLedger(dynamoClient, tableName).MonthToDate(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error reading LLM spend", "error", err)
+		return true
+	}
+	return monthToDate >= ceiling
+}
@@ -0,0 +1,18 @@
+package main
+
+import "testing"
+
+func TestPendingOf(t *testing.T) {
+	summary := func(id string) map[string]interface{} { return map[string]interface{}{"videoId": id} }
+	a, b, c := summary("a"), summary("b"), summary("c")
+	pending := []pendingTranslation{{summary: a}, {summary: b}, {summary: c}}
+
+	// A trending page keeps some summaries, in another order
+	got := pendingOf([]map[string]interface{}{c, a}, pending)
+	if len(got) != 2 || got[0].summary["videoId"] != "a" || got[1].summary["videoId"] != "c" {
+		t.Errorf("pendingOf = %v, want the translations of a and c", got)
+	}
+	if got := pendingOf(nil, pending); len(got) != 0 {
+		t.Errorf("pendingOf of no summaries = %v, want none", got)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	lambdasvc "github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdatypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+// batchEvent is the event the batch Lambda takes; see BatchEvent in
+// cmd/batch.
+type batchEvent struct {
+	Job       string `json:"job"`
+	ChannelID string `json:"channelId,omitempty"`
+}
+
+// Jobs the API starts
+const batchJobProcessJobs = "process-jobs"
+
+// triggerBatch starts the batch Lambda named by BATCH_FUNCTION_NAME without
+// waiting for it. Without the variable work waits for the scheduled run.
+func triggerBatch(ctx context.Context, event batchEvent) error {
+	name := cfg.BatchFunctionName
+	if name == "" {
+		slog.InfoContext(ctx, "BATCH_FUNCTION_NAME not set; work waits for the next scheduled run", "job", event.Job)
+		return nil
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = lambdaClient.Invoke(ctx, &lambdasvc.InvokeInput{
+		FunctionName:   aws.String(name),
+		InvocationType: lambdatypes.InvocationTypeEvent,
+		Payload:        payload,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to start batch job %q: %w", event.Job, err)
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/ttakahashi/youtube-summary/internal/logging"
+	"github.com/ttakahashi/youtube-summary/internal/store"
+	"github.com/ttakahashi/youtube-summary/internal/websub"
+)
+
+// websubVerify answers the hub's verification of a subscription request
+// made by the batch. Only channels the batch asked for are confirmed.
+func websubVerify(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	q := request.QueryStringParameters
+	mode, topic := q["hub.mode"], q["hub.topic"]
+	channelID := websub.ChannelOfTopic(topic)
+	if channelID == "" {
+		return createResponse(404, map[string]string{"error": "Unknown topic"})
+	}
+	ctx = logging.With(ctx, logging.KeyChannelID, channelID)
+	sub, err := store.GetWebSubSubscription(ctx, dynamoClient, tableName, channelID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error getting WebSub subscription", "error", err)
+		return createResponse(500, map[string]string{"error": "Internal server error"})
+	}
+
+	now := time.Now().UTC()
+	switch mode {
+	case "subscribe":
+		if sub == nil || sub.Status == store.WebSubUnsubscribed {
+			return createResponse(404, map[string]string{"error": "Subscription not requested"})
+		}
+		lease := websub.DefaultLease
+		if s, err := strconv.Atoi(q["hub.lease_seconds"]); err == nil && s > 0 {
+			lease = time.Duration(s) * time.Second
+		}
+		sub.Status = store.WebSubActive
+		sub.VerifiedAt = now.Format(time.RFC3339)
+		sub.ExpiresAt = now.Add(lease).Format(time.RFC3339)
+		if err := store.SaveWebSubSubscription(ctx, dynamoClient, tableName, sub); err != nil {
+			slog.ErrorContext(ctx, "Error saving WebSub subscription", "error", err)
+			return createResponse(500, map[string]string{"error": "Internal server error"})
+		}
+		slog.InfoContext(ctx, "WebSub subscription verified", "expiresAt", sub.ExpiresAt)
+	case "unsubscribe":
+		if sub != nil && sub.Status != store.WebSubUnsubscribed {
+			return createResponse(404, map[string]string{"error": "Subscription still wanted"})
+		}
+	case "denied":
+		slog.WarnContext(ctx, "WebSub hub denied the subscription", "reason", q["hub.reason"])
+		if sub != nil {
+			sub.Status = store.WebSubUnsubscribed
+			if err := store.SaveWebSubSubscription(ctx, dynamoClient, tableName, sub); err != nil {
+				slog.ErrorContext(ctx, "Error saving WebSub subscription", "error", err)
+			}
+		}
+		return createTextResponse(200, "text/plain", "", "")
+	default:
+		return createResponse(400, map[string]string{"error": "Unknown hub.mode"})
+	}
+	return createTextResponse(200, "text/plain", "", q["hub.challenge"])
+}
+
+// getWebSubSecret returns WEBSUB_SECRET, or the secret named by
+// WEBSUB_SECRET_NAME.
+func getWebSubSecret(ctx context.Context) (string, error) {
+	name := cfg.WebSubSecretName
+	if name == "" {
+		return cfg.WebSubSecret, nil
+	}
+	secret, err := secretStore.Get(ctx, name)
+	if err != nil {
+		return "", fmt.Errorf("failed to get WebSub secret: %w", err)
+	}
+	return secret, nil
+}
+
+// websubNotify takes a pushed feed and queues its new videos for the batch,
+// which is started right away. The hub only needs a 2xx; pushes without a
+// valid signature are acknowledged but ignored, as WebSub requires.
+func websubNotify(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	body := []byte(request.Body)
+	if request.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(request.Body)
+		if err != nil {
+			return createResponse(400, map[string]string{"error": "invalid body"})
+		}
+		body = decoded
+	}
+	secret, err := getWebSubSecret(ctx)
+	if err != nil {
+		// The hub retries pushes that fail
+		slog.ErrorContext(ctx, "Error getting WebSub secret", "error", err)
+		return createResponse(500, map[string]string{"error": "Internal server error"})
+	}
+	if secret == "" {
+		// Anyone could queue videos with unsigned pushes
+		slog.ErrorContext(ctx, "Rejecting WebSub push: no WebSub secret is configured")
+		return createResponse(403, map[string]string{"error": "WebSub is not configured"})
+	}
+	if !websub.ValidSignature(request.Headers["x-hub-signature"], secret, body) {
+		slog.WarnContext(ctx, "Ignoring WebSub push with invalid signature")
+		return createTextResponse(202, "text/plain", "", "")
+	}
+
+	feed, err := websub.ParseFeed(body)
+	if err != nil {
+		slog.WarnContext(ctx, "Ignoring WebSub push", "error", err)
+		return createTextResponse(202, "text/plain", "", "")
+	}
+	for _, id := range feed.Deleted {
+		// Left to the reconcile job, which checks the video itself
+		slog.InfoContext(logging.With(ctx, logging.KeyVideoID, id), "WebSub: video was deleted")
+	}
+
+	queued := 0
+	for _, e := range feed.Entries {
+		ctx := logging.With(ctx, logging.KeyChannelID, e.ChannelID, logging.KeyVideoID, e.VideoID)
+		sub, err := store.GetWebSubSubscription(ctx, dynamoClient, tableName, e.ChannelID)
+		if err != nil {
+			slog.ErrorContext(ctx, "Error getting WebSub subscription", "error", err)
+			continue
+		}
+		if sub == nil || sub.Status == store.WebSubUnsubscribed {
+			slog.InfoContext(ctx, "WebSub: ignoring video of unsubscribed channel")
+			continue
+		}
+
+		// Title and description edits are pushed too
+		item, err := store.GetVideoItem(ctx, dynamoClient, tableName, e.VideoID)
+		if err != nil {
+			slog.ErrorContext(ctx, "Error checking video", "error", err)
+			continue
+		}
+		if item != nil {
+			continue
+		}
+		_, created, err := store.CreateJob(ctx, dynamoClient, tableName, e.VideoID, e.ChannelID, "websub", store.JobQueued)
+		if err != nil {
+			slog.ErrorContext(ctx, "Error queueing video", "error", err)
+			continue
+		}
+		if created {
+			slog.InfoContext(ctx, "WebSub: queued video")
+			queued++
+		}
+	}
+
+	if queued > 0 {
+		if err := triggerBatch(ctx, batchEvent{Job: batchJobProcessJobs}); err != nil {
+			slog.ErrorContext(ctx, "Error starting batch", "error", err)
+		}
+	}
+	return createTextResponse(202, "text/plain", "", "")
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/ttakahashi/youtube-summary/internal/config"
+	"github.com/ttakahashi/youtube-summary/internal/dynamotest"
+	"github.com/ttakahashi/youtube-summary/internal/store"
+)
+
+const pushedFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns:yt="http://www.youtube.com/xml/schemas/2015" xmlns="http://www.w3.org/2005/Atom">
+  <entry>
+    <yt:videoId>abcdefghijk</yt:videoId>
+    <yt:channelId>UCpushed</yt:channelId>
+    <title>新しい動画</title>
+  </entry>
+</feed>`
+
+func sign(secret, body string) string {
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write([]byte(body))
+	return "sha1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestWebsubNotify(t *testing.T) {
+	tests := []struct {
+		name       string
+		secret     string
+		signature  string
+		wantStatus int
+		wantQueued bool
+	}{
+		{name: "no secret configured", signature: sign("", pushedFeed), wantStatus: 403},
+		{name: "unsigned", secret: "s3cret", wantStatus: 202},
+		{name: "bad signature", secret: "s3cret", signature: sign("other", pushedFeed), wantStatus: 202},
+		{name: "signed", secret: "s3cret", signature: sign("s3cret", pushedFeed), wantStatus: 202, wantQueued: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prevCfg, prevClient, prevTable := cfg, dynamoClient, tableName
+			t.Cleanup(func() { cfg, dynamoClient, tableName = prevCfg, prevClient, prevTable })
+			cfg = &config.Config{WebSubSecret: tt.secret}
+			tableName = "table"
+
+			var queued []dynamotest.Call
+			dynamoClient, _ = dynamotest.NewClient(t, func(call dynamotest.Call) (interface{}, string) {
+				switch call.Operation {
+				case "GetItem": // the subscription
+					return map[string]interface{}{"Item": map[string]interface{}{
+						"hashtag":     dynamotest.S(store.WebSubPartition),
+						"processedAt": dynamotest.S("UCpushed"),
+						"status":      dynamotest.S(store.WebSubActive),
+					}}, ""
+				case "Query": // the video, not summarized yet
+					return map[string]interface{}{"Items": []interface{}{}}, ""
+				case "PutItem":
+					queued = append(queued, call)
+					return nil, ""
+				}
+				t.Errorf("unexpected %s", call.Operation)
+				return nil, "ValidationException"
+			})
+
+			resp, err := websubNotify(context.Background(), events.APIGatewayV2HTTPRequest{
+				Headers: map[string]string{"x-hub-signature": tt.signature},
+				Body:    pushedFeed,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if !tt.wantQueued {
+				if len(queued) > 0 {
+					t.Errorf("queued %d jobs, want none", len(queued))
+				}
+				return
+			}
+			if len(queued) != 1 {
+				t.Fatalf("queued %d jobs, want 1", len(queued))
+			}
+			item := queued[0].Input["Item"].(map[string]interface{})
+			if got := dynamotest.Value(item["channelId"]); got != "UCpushed" {
+				t.Errorf("job channelId = %q, want the pushed channel", got)
+			}
+			if got := dynamotest.Value(item["requestedVideoId"]); got != "abcdefghijk" {
+				t.Errorf("job video = %q, want abcdefghijk", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+// Command apikey issues, lists and revokes API keys, and signs JWTs for
+// clients that use tokens instead.
+//
+//	apikey issue -name frontend -scopes read,ask
+//	apikey list
+//	apikey revoke -id 1a2b3c4d
+//	apikey token -sub ci -scopes jobs -ttl 24h
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/ttakahashi/youtube-summary/internal/auth"
+	"github.com/ttakahashi/youtube-summary/internal/config"
+	"github.com/ttakahashi/youtube-summary/internal/secrets"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: apikey issue|list|revoke|token [flags]")
+	os.Exit(2)
+}
+
+// loadConfig reads the shared configuration from the environment and
+// CONFIG_FILE; the command's own flags follow the subcommand.
+func loadConfig() *config.Config {
+	cfg, _, err := config.Load(nil)
+	if err != nil {
+		log.Fatalf("invalid configuration: %v", err)
+	}
+	return cfg
+}
+
+func keyStore(ctx context.Context) *auth.DynamoKeyStore {
+	cfg := loadConfig()
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(cfg.Region))
+	if err != nil {
+		log.Fatalf("unable to load SDK config, %v", err)
+	}
+	return auth.NewDynamoKeyStore(dynamodb.NewFromConfig(awsCfg), cfg.Table)
+}
+
+func parseScopes(s string) []string {
+	scopes, err := auth.ParseScopes(s)
+	if err != nil {
+		log.Fatalf("%v (scopes: %s)", err, strings.Join(auth.Scopes, ", "))
+	}
+	if len(scopes) == 0 {
+		log.Fatalf("-scopes is required (scopes: %s)", strings.Join(auth.Scopes, ", "))
+	}
+	return scopes
+}
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		usage()
+	}
+	ctx := context.Background()
+	fs := flag.NewFlagSet(os.Args[1], flag.ExitOnError)
+
+	switch os.Args[1] {
+	case "issue":
+		name := fs.String("name", "", "who the key is for")
+		scopes := fs.String("scopes", auth.ScopeRead, "comma-separated scopes")
+		fs.Parse(os.Args[2:])
+		if *name == "" {
+			log.Fatal("-name is required")
+		}
+		key, k, err := keyStore(ctx).Issue(ctx, *name, parseScopes(*scopes))
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Issued key %s for %s with scopes %s. It is shown only once:\n%s\n", k.ID, k.Name, strings.Join(k.Scopes, ","), key)
+
+	case "list":
+		fs.Parse(os.Args[2:])
+		keys, err := keyStore(ctx).List(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, k := range keys {
+			status := "active"
+			if k.RevokedAt != "" {
+				status = "revoked " + k.RevokedAt
+			}
+			fmt.Printf("%s\t%s\t%s\t%s\t%s\n", k.ID, k.Name, strings.Join(k.Scopes, ","), k.CreatedAt, status)
+		}
+
+	case "revoke":
+		id := fs.String("id", "", "ID of the key, as shown by list")
+		fs.Parse(os.Args[2:])
+		if *id == "" {
+			log.Fatal("-id is required")
+		}
+		k, err := keyStore(ctx).Revoke(ctx, *id)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Revoked key %s (%s)\n", k.ID, k.Name)
+
+	case "token":
+		sub := fs.String("sub", "", "subject of the token")
+		scopes := fs.String("scopes", auth.ScopeRead, "comma-separated scopes")
+		ttl := fs.Duration("ttl", time.Hour, "lifetime of the token")
+		fs.Parse(os.Args[2:])
+		if *sub == "" {
+			log.Fatal("-sub is required")
+		}
+		cfg := loadConfig()
+		secret := cfg.JWTSecret
+		if cfg.JWTSecretName != "" {
+			provider, err := secrets.Open(ctx, cfg.SecretsBackend, cfg.Region, 0)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if secret, err = provider.Get(ctx, cfg.JWTSecretName); err != nil {
+				log.Fatalf("failed to get JWT secret: %v", err)
+			}
+		}
+		token, err := auth.SignJWT(secret, cfg.JWTIssuer, *sub, parseScopes(*scopes), *ttl)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(token)
+
+	default:
+		usage()
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/ttakahashi/youtube-summary/internal/logging"
+	"github.com/ttakahashi/youtube-summary/internal/store"
+)
+
+// batchChannels returns the channels a run processes: the channel the event
+// names, or else every channel configured through the admin API that is not
+// paused. Without any configured channel it falls back to CHANNEL_ID, as
+// before channels could be configured.
+func batchChannels(ctx context.Context, event BatchEvent) ([]*store.Channel, error) {
+	if event.ChannelID != "" {
+		channel, err := store.GetChannel(ctx, dynamoClient, tableName, event.ChannelID)
+		if err != nil {
+			return nil, err
+		}
+		if channel == nil {
+			channel = &store.Channel{ID: event.ChannelID}
+		}
+		return []*store.Channel{channel}, nil
+	}
+
+	configured, err := store.ListChannels(ctx, dynamoClient, tableName)
+	if err != nil {
+		return nil, err
+	}
+	if len(configured) == 0 {
+		return []*store.Channel{{ID: cfg.ChannelID}}, nil
+	}
+
+	var channels []*store.Channel
+	for _, c := range configured {
+		if c.Paused {
+			slog.InfoContext(logging.With(ctx, logging.KeyChannelID, c.ID), "Skipping paused channel", "title", c.Title)
+			continue
+		}
+		channels = append(channels, c)
+	}
+	if len(channels) == 0 {
+		slog.WarnContext(ctx, "All configured channels are paused", "count", len(configured))
+	}
+	return channels, nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Videos shorter than this are not worth splitting into inferred chapters.
+const minInferredChapterSeconds = 600
+
+// Chapter is a section of a video with its own summary.
+type Chapter struct {
+	Start   float64 `json:"-"`
+	Time    string  `json:"start"` // m:ss or h:mm:ss, as written in the description or by the model
+	Title   string  `json:"title"`
+	Summary string  `json:"summary,omitempty"`
+}
+
+// A chapter line starts (or, less often, ends) with a timestamp, e.g.
+// "0:00 Intro", "00:12:34 - Q&A" or "(1:02:03) Closing".
+var (
+	leadingChapterLine  = regexp.MustCompile(`^[\s\-–•*]*[(\[]?((?:\d{1,2}:)?\d{1,2}:\d{2})[)\]]?\s*[-–—:|]?\s*(.+)$`)
+	trailingChapterLine = regexp.MustCompile(`^(.+?)\s*[-–—:|]?\s*[(\[]?((?:\d{1,2}:)?\d{1,2}:\d{2})[)\]]?\s*$`)
+)
+
+// parseChapters extracts chapters from a video description. Like YouTube,
+// it only accepts a list of at least three ascending timestamps starting at
+// 0:00; anything else returns nil.
+func parseChapters(description string) []Chapter {
+	var chapters []Chapter
+	for _, line := range strings.Split(description, "\n") {
+		line = strings.TrimSpace(line)
+		var ts, title string
+		if m := leadingChapterLine.FindStringSubmatch(line); m != nil {
+			ts, title = m[1], m[2]
+		} else if m := trailingChapterLine.FindStringSubmatch(line); m != nil {
+			ts, title = m[2], m[1]
+		} else {
+			continue
+		}
+
+		start, err := parseTimestamp(ts)
+		if err != nil {
+			continue
+		}
+		if len(chapters) > 0 && start <= chapters[len(chapters)-1].Start {
+			continue
+		}
+		chapters = append(chapters, Chapter{Start: start, Time: ts, Title: strings.TrimSpace(title)})
+	}
+
+	if len(chapters) < 3 || chapters[0].Start != 0 {
+		return nil
+	}
+	return chapters
+}
+
+// parseTimestamp converts m:ss or h:mm:ss to seconds.
+func parseTimestamp(ts string) (float64, error) {
+	ts = strings.Trim(strings.TrimSpace(ts), "[]()")
+	parts := strings.Split(ts, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("invalid timestamp %q", ts)
+	}
+	seconds := 0
+	for _, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid timestamp %q", ts)
+		}
+		seconds = seconds*60 + n
+	}
+	return float64(seconds), nil
+}
+
+// normalizeChapters fills Start from the model's timestamps and drops
+// chapters it could not place, keeping them in playback order.
+func normalizeChapters(chapters []Chapter) []Chapter {
+	valid := chapters[:0]
+	for _, c := range chapters {
+		start, err := parseTimestamp(c.Time)
+		if err != nil || c.Title == "" {
+			continue
+		}
+		c.Start = start
+		valid = append(valid, c)
+	}
+	sort.SliceStable(valid, func(i, j int) bool { return valid[i].Start < valid[j].Start })
+	return valid
+}
+
+// chaptersAttribute converts chapters to a DynamoDB list of maps.
+func chaptersAttribute(chapters []Chapter) types.AttributeValue {
+	list := make([]types.AttributeValue, len(chapters))
+	for i, c := range chapters {
+		list[i] = &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+			"start":   &types.AttributeValueMemberN{Value: strconv.FormatFloat(c.Start, 'f', -1, 64)},
+			"title":   &types.AttributeValueMemberS{Value: c.Title},
+			"summary": &types.AttributeValueMemberS{Value: c.Summary},
+		}}
+	}
+	return &types.AttributeValueMemberL{Value: list}
+}
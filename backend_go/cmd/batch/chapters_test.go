@@ -0,0 +1,98 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func TestParseChapters(t *testing.T) {
+	tests := []struct {
+		name        string
+		description string
+		want        []Chapter
+	}{
+		{
+			name:        "leading timestamps",
+			description: "今日の動画です\n\n0:00 Intro\n1:30 - 本題\n1:02:03 まとめ\n",
+			want: []Chapter{
+				{Start: 0, Time: "0:00", Title: "Intro"},
+				{Start: 90, Time: "1:30", Title: "本題"},
+				{Start: 3723, Time: "1:02:03", Title: "まとめ"},
+			},
+		},
+		{
+			name:        "trailing and bracketed timestamps",
+			description: "Intro (0:00)\nQ&A [12:34]\nClosing - 20:00",
+			want: []Chapter{
+				{Start: 0, Time: "0:00", Title: "Intro"},
+				{Start: 754, Time: "12:34", Title: "Q&A"},
+				{Start: 1200, Time: "20:00", Title: "Closing"},
+			},
+		},
+		{
+			name:        "out of order lines are skipped",
+			description: "0:00 A\n5:00 B\n3:00 stray\n6:00 C",
+			want: []Chapter{
+				{Start: 0, Time: "0:00", Title: "A"},
+				{Start: 300, Time: "5:00", Title: "B"},
+				{Start: 360, Time: "6:00", Title: "C"},
+			},
+		},
+		{
+			name:        "fewer than three",
+			description: "0:00 A\n5:00 B",
+		},
+		{
+			name:        "not starting at zero",
+			description: "0:10 A\n5:00 B\n6:00 C",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseChapters(tt.description); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseChapters = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeChapters(t *testing.T) {
+	got := normalizeChapters([]Chapter{
+		{Time: "10:00", Title: "B"},
+		{Time: "soon", Title: "dropped"},
+		{Time: "0:00", Title: "A"},
+		{Time: "20:00", Title: ""},
+	})
+	want := []Chapter{
+		{Start: 0, Time: "0:00", Title: "A"},
+		{Start: 600, Time: "10:00", Title: "B"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("normalizeChapters = %+v, want %+v", got, want)
+	}
+}
+
+func TestChaptersAttribute(t *testing.T) {
+	chapters := []Chapter{
+		{Start: 0, Title: "Intro", Summary: "概要"},
+		{Start: 90.5, Title: "本題"},
+	}
+	list, ok := chaptersAttribute(chapters).(*types.AttributeValueMemberL)
+	if !ok || len(list.Value) != len(chapters) {
+		t.Fatalf("chaptersAttribute = %#v, want a list of %d", list, len(chapters))
+	}
+	for i, c := range chapters {
+		m := list.Value[i].(*types.AttributeValueMemberM).Value
+		want := map[string]types.AttributeValue{
+			"start":   &types.AttributeValueMemberN{Value: []string{"0", "90.5"}[i]},
+			"title":   &types.AttributeValueMemberS{Value: c.Title},
+			"summary": &types.AttributeValueMemberS{Value: c.Summary},
+		}
+		if !reflect.DeepEqual(m, want) {
+			t.Errorf("chapter %d = %#v, want %#v", i, m, want)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/ttakahashi/youtube-summary/internal/llm"
+	"github.com/ttakahashi/youtube-summary/internal/logging"
+	"github.com/ttakahashi/youtube-summary/internal/quota"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/youtube/v3"
+)
+
+// CommentSummary is the audience reaction to a video.
+type CommentSummary struct {
+	Summary   string   `json:"summary"`
+	Sentiment string   `json:"sentiment"` // positive, negative, mixed or neutral
+	Themes    []string `json:"themes"`
+	Questions []string `json:"questions"`
+
+	CommentCount int       `json:"-"`
+	Usage        llm.Usage `json:"-"`
+	CostUSD      float64   `json:"-"`
+}
+
+// fetchComments returns the text of videoID's most relevant top-level
+// comments. It returns no comments when they are disabled on the video.
+func fetchComments(ctx context.Context, ytService *youtube.Service, meter *quota.Meter, videoID string, max int64) ([]string, error) {
+	if err := meter.Charge(ctx, "commentThreads.list"); err != nil {
+		return nil, err
+	}
+	resp, err := ytService.CommentThreads.List([]string{"snippet"}).
+		VideoId(videoID).
+		Order("relevance").
+		TextFormat("plainText").
+		MaxResults(max).
+		Do()
+	if err != nil {
+		var apiErr *googleapi.Error
+		if errors.As(err, &apiErr) && apiErr.Code == http.StatusForbidden {
+			slog.InfoContext(ctx, "Comments are disabled")
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error fetching comments: %w", err)
+	}
+
+	var comments []string
+	for _, thread := range resp.Items {
+		if thread.Snippet == nil || thread.Snippet.TopLevelComment == nil || thread.Snippet.TopLevelComment.Snippet == nil {
+			continue
+		}
+		c := thread.Snippet.TopLevelComment.Snippet
+		comments = append(comments, fmt.Sprintf("(%d likes) %s", c.LikeCount, strings.TrimSpace(c.TextDisplay)))
+	}
+	return comments, nil
+}
+
+// generateCommentSummary summarizes the themes, sentiment and frequent
+// questions of comments.
+func generateCommentSummary(ctx context.Context, title string, comments []string) (*CommentSummary, error) {
+	text := strings.Join(comments, "\n---\n")
+	if len(text) > 30000 {
+		text = text[:30000]
+	}
+
+	prompt := fmt.Sprintf(`以下はYouTube動画「%s」に寄せられたコメントです。視聴者の反応を分析し、JSON形式で出力してください。
+
+1. summary: 視聴者の反応全体を200文字程度でまとめた要約
+2. sentiment: 全体的な感情の傾向 (positive, negative, mixed, neutral のいずれか)
+3. themes: よく話題になっているテーマ (最大5件、各30文字程度)
+4. questions: 視聴者からよく寄せられている質問 (最大5件、なければ空配列)
+
+コメント:
+%s
+
+出力形式（必ずこのJSONフォーマットのみを出力してください）:
+{
+  "summary": "...",
+  "sentiment": "...",
+  "themes": ["..."],
+  "questions": ["..."]
+}`, title, text)
+
+	resp, err := llmClient.Invoke(ctx, prompt, 2048)
+	if err != nil {
+		return nil, err
+	}
+
+	responseText := llm.StripCodeFence(resp.Text)
+	var summary CommentSummary
+	if err := json.Unmarshal([]byte(responseText), &summary); err != nil {
+		return nil, fmt.Errorf("failed to parse comment summary json: %w. Response: %s", err, responseText)
+	}
+	summary.CommentCount = len(comments)
+	summary.Usage = resp.Usage
+	summary.CostUSD = resp.CostUSD
+	return &summary, nil
+}
+
+// saveCommentSummary stores summary in the "commentSummary" attribute of
+// the video's item.
+func saveCommentSummary(ctx context.Context, stored storedVideo, summary *CommentSummary) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	_, err := dynamoClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:        aws.String(tableName),
+		Key:              stored.Key,
+		UpdateExpression: aws.String("SET commentSummary = :s, commentSummaryAt = :at"),
+		// Do not recreate items deleted in the meantime
+		ConditionExpression: aws.String("attribute_exists(videoId)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":s": &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+				"summary":      &types.AttributeValueMemberS{Value: summary.Summary},
+				"sentiment":    &types.AttributeValueMemberS{Value: summary.Sentiment},
+				"themes":       stringList(summary.Themes),
+				"questions":    stringList(summary.Questions),
+				"commentCount": &types.AttributeValueMemberN{Value: strconv.Itoa(summary.CommentCount)},
+				"costUsd":      &types.AttributeValueMemberN{Value: strconv.FormatFloat(summary.CostUSD, 'f', -1, 64)},
+			}},
+			":at": &types.AttributeValueMemberS{Value: now},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save comment summary: %w", err)
+	}
+	return nil
+}
+
+// summarizeComments reads the top comments of a stored video and saves
+// their summary. Videos without comments are left without one.
+func summarizeComments(ctx context.Context, ytService *youtube.Service, meter *quota.Meter, ledger *llm.Ledger, channelID string, stored storedVideo, stats *BatchStats) error {
+	comments, err := fetchComments(ctx, ytService, meter, stored.VideoID, int64(cfg.CommentMaxThreads))
+	if err != nil {
+		return err
+	}
+	if len(comments) == 0 {
+		return nil
+	}
+
+	slog.InfoContext(ctx, "Summarizing comments...", "count", len(comments))
+	summary, err := generateCommentSummary(ctx, stored.Title, comments)
+	if err != nil {
+		return err
+	}
+	stats.InputTokens += summary.Usage.InputTokens
+	stats.OutputTokens += summary.Usage.OutputTokens
+	stats.CostUSD += summary.CostUSD
+	if err := ledger.Record(ctx, channelID, summary.Usage, summary.CostUSD); err != nil {
+		slog.ErrorContext(ctx, "Error recording LLM usage for comments", "error", err)
+	}
+
+	if err := saveCommentSummary(ctx, stored, summary); err != nil {
+		return err
+	}
+	stats.CommentsSummarized++
+	return nil
+}
+
+// refreshComments re-summarizes the comments of channelID's recent videos
+// whose comment summary is missing or older than the refresh interval.
+func refreshComments(ctx context.Context, ytService *youtube.Service, meter *quota.Meter, ledger *llm.Ledger, budgetReached func() bool, channelID string, stats *BatchStats) error {
+	now := time.Now().UTC()
+	since := now.AddDate(0, 0, -cfg.CommentRefreshMaxAge).Format(time.RFC3339)
+	staleBefore := now.Add(-time.Duration(cfg.CommentRefreshInterval) * time.Hour).Format(time.RFC3339)
+
+	videos, err := listStoredVideos(ctx, channelID, since)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range videos {
+		if v.Availability != "" || v.CommentsAt >= staleBefore {
+			continue
+		}
+		if budgetReached() {
+			slog.WarnContext(ctx, "Monthly LLM budget reached. Not refreshing more comment summaries.")
+			stats.SummarizationPaused = true
+			return nil
+		}
+		ctx := logging.With(ctx, logging.KeyVideoID, v.VideoID)
+		if err := summarizeComments(ctx, ytService, meter, ledger, channelID, v, stats); err != nil {
+			if errors.Is(err, quota.ErrBudgetExceeded) {
+				return err
+			}
+			slog.ErrorContext(ctx, "Error summarizing comments", "error", err)
+			stats.Errors++
+		}
+	}
+	return nil
+}
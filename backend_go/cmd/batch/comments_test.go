@@ -0,0 +1,311 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/ttakahashi/youtube-summary/internal/config"
+	"github.com/ttakahashi/youtube-summary/internal/dynamotest"
+	"github.com/ttakahashi/youtube-summary/internal/llm"
+	"github.com/ttakahashi/youtube-summary/internal/quota"
+	"google.golang.org/api/option"
+	"google.golang.org/api/youtube/v3"
+)
+
+// fakeYouTube returns a YouTube service answered by h, and the video IDs
+// whose comments were requested.
+func fakeYouTube(t *testing.T, h http.HandlerFunc) (*youtube.Service, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var requested []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested = append(requested, r.URL.Query().Get("videoId"))
+		mu.Unlock()
+		h(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	service, err := youtube.NewService(context.Background(), option.WithEndpoint(srv.URL+"/"), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return service, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), requested...)
+	}
+}
+
+// commentThreads answers commentThreads.list with a comment per text.
+func commentThreads(texts ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var items []*youtube.CommentThread
+		for i, text := range texts {
+			items = append(items, &youtube.CommentThread{Snippet: &youtube.CommentThreadSnippet{
+				TopLevelComment: &youtube.Comment{Snippet: &youtube.CommentSnippet{
+					TextDisplay: text,
+					LikeCount:   int64(len(texts) - i),
+				}},
+			}})
+		}
+		// A thread without its comment, as deleted comments come back
+		items = append(items, &youtube.CommentThread{Snippet: &youtube.CommentThreadSnippet{}})
+		json.NewEncoder(w).Encode(&youtube.CommentThreadListResponse{Items: items})
+	}
+}
+
+// fakeLLM points llmClient at a fake Bedrock answering every prompt with
+// text.
+func fakeLLM(t *testing.T, text string) {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"content": []map[string]string{{"type": "text", "text": text}},
+			"usage":   map[string]int{"input_tokens": 1000, "output_tokens": 100},
+		})
+	}))
+	t.Cleanup(srv.Close)
+
+	bedrock := bedrockruntime.New(bedrockruntime.Options{
+		Region:           "us-east-1",
+		BaseEndpoint:     aws.String(srv.URL),
+		Credentials:      dynamotest.TestCredentials,
+		RetryMaxAttempts: 1,
+	})
+	prev := llmClient
+	llmClient = llm.NewClient(bedrock, "", nil)
+	t.Cleanup(func() { llmClient = prev })
+}
+
+// fakeMeter returns a quota meter that accepts every charge, or refuses
+// every charge when exhausted.
+func fakeMeter(t *testing.T, exhausted bool) *quota.Meter {
+	t.Helper()
+	client, _ := dynamotest.NewClient(t, func(call dynamotest.Call) (interface{}, string) {
+		if call.Operation == "UpdateItem" && exhausted {
+			return nil, "ConditionalCheckFailedException"
+		}
+		return nil, ""
+	})
+	meter, err := quota.NewMeter(context.Background(), client, "table", 10000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return meter
+}
+
+func TestFetchComments(t *testing.T) {
+	tests := []struct {
+		name       string
+		exhausted  bool
+		handler    http.HandlerFunc
+		want       []string
+		wantErr    bool
+		wantCalled bool
+	}{
+		{
+			name:       "top comments",
+			handler:    commentThreads("  最高の解説  ", "Thanks!"),
+			want:       []string{"(2 likes) 最高の解説", "(1 likes) Thanks!"},
+			wantCalled: true,
+		},
+		{
+			name: "comments disabled",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte(`{"error": {"code": 403, "message": "The video has disabled comments.", "errors": [{"reason": "commentsDisabled"}]}}`))
+			},
+			wantCalled: true,
+		},
+		{
+			name: "other errors",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`{"error": {"code": 404, "message": "Video not found."}}`))
+			},
+			wantErr:    true,
+			wantCalled: true,
+		},
+		{
+			name:      "quota exhausted",
+			exhausted: true,
+			handler:   commentThreads("never read"),
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, requested := fakeYouTube(t, tt.handler)
+			got, err := fetchComments(context.Background(), service, fakeMeter(t, tt.exhausted), "abcdefghijk", 50)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("fetchComments error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.exhausted && !errors.Is(err, quota.ErrBudgetExceeded) {
+				t.Errorf("fetchComments error = %v, want %v", err, quota.ErrBudgetExceeded)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("fetchComments = %q, want %q", got, tt.want)
+			}
+			if called := len(requested()) > 0; called != tt.wantCalled {
+				t.Errorf("YouTube called = %v, want %v", called, tt.wantCalled)
+			}
+		})
+	}
+}
+
+func TestGenerateCommentSummary(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     *CommentSummary
+		wantErr  bool
+	}{
+		{
+			name:     "json",
+			response: `{"summary": "好評", "sentiment": "positive", "themes": ["解説"], "questions": []}`,
+			want:     &CommentSummary{Summary: "好評", Sentiment: "positive", Themes: []string{"解説"}, Questions: []string{}},
+		},
+		{
+			name:     "code fence",
+			response: "```json\n{\"summary\": \"賛否両論\", \"sentiment\": \"mixed\", \"themes\": [\"価格\", \"音質\"], \"questions\": [\"続編は？\"]}\n```",
+			want:     &CommentSummary{Summary: "賛否両論", Sentiment: "mixed", Themes: []string{"価格", "音質"}, Questions: []string{"続編は？"}},
+		},
+		{
+			name:     "not json",
+			response: "コメントを要約できませんでした。",
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeLLM(t, tt.response)
+			got, err := generateCommentSummary(context.Background(), "動画", []string{"(2 likes) a", "(1 likes) b"})
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("generateCommentSummary = %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.CommentCount != 2 || got.Usage != (llm.Usage{InputTokens: 1000, OutputTokens: 100}) || got.CostUSD <= 0 {
+				t.Errorf("count, usage, cost = %d, %+v, %v", got.CommentCount, got.Usage, got.CostUSD)
+			}
+			got.CommentCount, got.Usage, got.CostUSD = 0, llm.Usage{}, 0
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("generateCommentSummary = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRefreshComments(t *testing.T) {
+	const channelID = "UCchannel"
+	now := time.Now().UTC()
+	ago := func(d time.Duration) string { return now.Add(-d).Format(time.RFC3339) }
+
+	// Stored videos by ID, with when their comments were last summarized
+	stored := []struct {
+		videoID      string
+		commentsAt   string
+		availability string
+	}{
+		{videoID: "fresh", commentsAt: ago(time.Hour)},
+		{videoID: "stale", commentsAt: ago(48 * time.Hour)},
+		{videoID: "never"},
+		{videoID: "removed", availability: "private"},
+	}
+
+	tests := []struct {
+		name          string
+		budgetAfter   int // summaries after which the LLM budget is reached; -1 for never
+		exhausted     bool
+		wantRefreshed []string
+		wantPaused    bool
+		wantErr       error
+	}{
+		{name: "stale and missing summaries", budgetAfter: -1, wantRefreshed: []string{"never", "stale"}},
+		{name: "llm budget reached", budgetAfter: 1, wantRefreshed: []string{"stale"}, wantPaused: true},
+		{name: "llm budget reached before", budgetAfter: 0, wantPaused: true},
+		{name: "youtube quota exhausted", budgetAfter: -1, exhausted: true, wantErr: quota.ErrBudgetExceeded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prevCfg, prevClient, prevTable := cfg, dynamoClient, tableName
+			t.Cleanup(func() { cfg, dynamoClient, tableName = prevCfg, prevClient, prevTable })
+			cfg = &config.Config{CommentMaxThreads: 100, CommentRefreshMaxAge: 7, CommentRefreshInterval: 24}
+			tableName = "table"
+
+			var mu sync.Mutex
+			var refreshed []string
+			dynamoClient, _ = dynamotest.NewClient(t, func(call dynamotest.Call) (interface{}, string) {
+				switch call.Operation {
+				case "Query":
+					var items []map[string]interface{}
+					for _, v := range stored {
+						item := map[string]interface{}{
+							"hashtag":     dynamotest.S(channelID),
+							"processedAt": dynamotest.S(v.videoID),
+							"videoId":     dynamotest.S(v.videoID),
+							"publishedAt": dynamotest.S(ago(72 * time.Hour)),
+						}
+						if v.commentsAt != "" {
+							item["commentSummaryAt"] = dynamotest.S(v.commentsAt)
+						}
+						if v.availability != "" {
+							item["availability"] = dynamotest.S(v.availability)
+						}
+						items = append(items, item)
+					}
+					return map[string]interface{}{"Items": items}, ""
+				case "UpdateItem":
+					if hashtag, videoID := call.Key(); hashtag == channelID {
+						mu.Lock()
+						refreshed = append(refreshed, videoID)
+						mu.Unlock()
+					}
+					return nil, ""
+				}
+				t.Errorf("unexpected %s", call.Operation)
+				return nil, "ValidationException"
+			})
+			fakeLLM(t, `{"summary": "好評", "sentiment": "positive", "themes": [], "questions": []}`)
+			service, _ := fakeYouTube(t, commentThreads("great"))
+
+			stats := &BatchStats{}
+			budgetReached := func() bool {
+				return tt.budgetAfter >= 0 && stats.CommentsSummarized >= tt.budgetAfter
+			}
+			err := refreshComments(context.Background(), service, fakeMeter(t, tt.exhausted), llm.NewLedger(dynamoClient, tableName), budgetReached, channelID, stats)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("refreshComments = %v, want %v", err, tt.wantErr)
+			}
+
+			sort.Strings(refreshed)
+			if strings.Join(refreshed, ",") != strings.Join(tt.wantRefreshed, ",") {
+				t.Errorf("refreshed %v, want %v", refreshed, tt.wantRefreshed)
+			}
+			if stats.CommentsSummarized != len(tt.wantRefreshed) || stats.SummarizationPaused != tt.wantPaused {
+				t.Errorf("summarized, paused = %d, %v; want %d, %v",
+					stats.CommentsSummarized, stats.SummarizationPaused, len(tt.wantRefreshed), tt.wantPaused)
+			}
+		})
+	}
+}
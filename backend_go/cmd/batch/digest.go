@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/ttakahashi/youtube-summary/internal/llm"
+	"github.com/ttakahashi/youtube-summary/internal/store"
+)
+
+// digestWindow returns the last complete day, or week starting on Monday,
+// before now in loc.
+func digestWindow(period string, now time.Time, loc *time.Location) (start, end time.Time) {
+	now = now.In(loc)
+	end = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	if period == store.DigestWeekly {
+		// Back to this week's Monday
+		end = end.AddDate(0, 0, -((int(end.Weekday()) + 6) % 7))
+		return end.AddDate(0, 0, -7), end
+	}
+	return end.AddDate(0, 0, -1), end
+}
+
+// digestVideo is a summarized video considered for a digest.
+type digestVideo struct {
+	ID        string
+	Title     string
+	Summary   string
+	ViewCount string
+}
+
+// listDigestVideos returns channelID's visible summarized videos published
+// in [start, end).
+func listDigestVideos(ctx context.Context, channelID string, start, end time.Time) ([]digestVideo, error) {
+	var videos []digestVideo
+	var lastEvaluatedKey map[string]types.AttributeValue
+	for {
+		resp, err := dynamoClient.Query(ctx, &dynamodb.QueryInput{
+			TableName:              aws.String(tableName),
+			KeyConditionExpression: aws.String("hashtag = :h"),
+			FilterExpression:       aws.String("publishedAt >= :start AND publishedAt < :end AND attribute_exists(summary) AND attribute_not_exists(availability)"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":h":     &types.AttributeValueMemberS{Value: channelID},
+				":start": &types.AttributeValueMemberS{Value: start.UTC().Format(time.RFC3339)},
+				":end":   &types.AttributeValueMemberS{Value: end.UTC().Format(time.RFC3339)},
+			},
+			ProjectionExpression: aws.String("videoId, title, summary, viewCount"),
+			ExclusiveStartKey:    lastEvaluatedKey,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list videos for digest: %w", err)
+		}
+		for _, item := range resp.Items {
+			var v digestVideo
+			if s, ok := item["videoId"].(*types.AttributeValueMemberS); ok {
+				v.ID = s.Value
+			}
+			if s, ok := item["title"].(*types.AttributeValueMemberS); ok {
+				v.Title = s.Value
+			}
+			if s, ok := item["summary"].(*types.AttributeValueMemberS); ok {
+				v.Summary = s.Value
+			}
+			if n, ok := item["viewCount"].(*types.AttributeValueMemberN); ok {
+				v.ViewCount = n.Value
+			}
+			if v.ID != "" && v.Summary != "" {
+				videos = append(videos, v)
+			}
+		}
+		if resp.LastEvaluatedKey == nil {
+			return videos, nil
+		}
+		lastEvaluatedKey = resp.LastEvaluatedKey
+	}
+}
+
+// generateDigest asks the model for a digest of the given material, each
+// entry headed by its video ID or channel.
+func generateDigest(ctx context.Context, periodLabel, material string) (*store.Digest, *llm.Response, error) {
+	prompt := fmt.Sprintf(`以下は%sに公開されたYouTube動画の要約です。これらをまとめたダイジェストをJSON形式で出力してください。
+
+1. headline: この期間を一言で表す見出し (40文字程度)
+2. summary: 期間全体の動向や共通するテーマをまとめたダイジェスト (800文字程度、Markdown形式)
+3. highlights: 特に注目すべき動画 (最大5件)。video_id と、注目すべき理由 (reason、100文字程度)
+
+%s
+出力形式（必ずこのJSONフォーマットのみを出力してください）:
+{
+  "headline": "...",
+  "summary": "...",
+  "highlights": [
+    {"video_id": "...", "reason": "..."}
+  ]
+}`, periodLabel, material)
+
+	resp, err := llmClient.Invoke(ctx, prompt, 4096)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	responseText := llm.StripCodeFence(resp.Text)
+	var out struct {
+		Headline   string `json:"headline"`
+		Summary    string `json:"summary"`
+		Highlights []struct {
+			VideoID string `json:"video_id"`
+			Reason  string `json:"reason"`
+		} `json:"highlights"`
+	}
+	if err := json.Unmarshal([]byte(responseText), &out); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse digest json: %w. Response: %s", err, responseText)
+	}
+	d := &store.Digest{Headline: out.Headline, Summary: out.Summary, Highlights: []store.Highlight{}}
+	for _, h := range out.Highlights {
+		d.Highlights = append(d.Highlights, store.Highlight{VideoID: h.VideoID, Reason: h.Reason})
+	}
+	return d, resp, nil
+}
+
+// digestPeriod returns the last complete period in DIGEST_TIMEZONE and how
+// the digest prompt names it.
+func digestPeriod(period string) (start, end time.Time, label string, err error) {
+	loc, err := time.LoadLocation(cfg.DigestTimezone)
+	if err != nil {
+		return start, end, "", fmt.Errorf("invalid DIGEST_TIMEZONE: %w", err)
+	}
+	start, end = digestWindow(period, time.Now(), loc)
+	label = start.Format("2006年1月2日")
+	if period == store.DigestWeekly {
+		label += "から" + end.AddDate(0, 0, -1).Format("1月2日") + "まで"
+	}
+	return start, end, label, nil
+}
+
+// buildDigest writes the digest of channelID's videos for the last complete
+// period.
+func buildDigest(ctx context.Context, period, channelID string, ledger *llm.Ledger, budgetReached func() bool, stats *BatchStats) error {
+	start, end, periodLabel, err := digestPeriod(period)
+	if err != nil {
+		return err
+	}
+
+	videos, err := listDigestVideos(ctx, channelID, start, end)
+	if err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "Building digest", "period", period, "start", start.Format("2006-01-02"), "videos", len(videos))
+	if len(videos) == 0 {
+		return nil
+	}
+	if budgetReached() {
+		stats.SummarizationPaused = true
+		return fmt.Errorf("monthly LLM budget reached")
+	}
+
+	titles := map[string]string{}
+	ids := make([]string, len(videos))
+	var b strings.Builder
+	for i, v := range videos {
+		titles[v.ID] = v.Title
+		ids[i] = v.ID
+		fmt.Fprintf(&b, "## video_id: %s\nタイトル: %s\n再生回数: %s\n%s\n\n", v.ID, v.Title, v.ViewCount, v.Summary)
+	}
+	digest, resp, err := generateDigest(ctx, periodLabel, b.String())
+	if err != nil {
+		return err
+	}
+	recordDigestUsage(ctx, channelID, resp, ledger, stats)
+
+	digest.Period = period
+	digest.Start = start.Format("2006-01-02")
+	digest.End = end.Format("2006-01-02")
+	digest.ChannelID = channelID
+	digest.VideoIDs = ids
+	digest.CostUSD = resp.CostUSD
+	digest.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	for i, h := range digest.Highlights {
+		digest.Highlights[i].Title = titles[h.VideoID]
+	}
+	if err := store.SaveDigest(ctx, dynamoClient, tableName, digest); err != nil {
+		return err
+	}
+	stats.DigestsBuilt++
+	emailDigest(ctx, digest, stats)
+	return nil
+}
+
+// buildAllChannelsDigest combines the channel digests of the last complete
+// period. The handler calls it once, after every channel's digest of the run
+// is saved. With a single channel it is a copy. It is left alone when the
+// digest already covers the same videos, so a repeated job adds no LLM call.
+func buildAllChannelsDigest(ctx context.Context, period string, stats *BatchStats) error {
+	start, _, periodLabel, err := digestPeriod(period)
+	if err != nil {
+		return err
+	}
+	digests, err := store.ListDigests(ctx, dynamoClient, tableName, period, "", start.Format("2006-01-02"), 0)
+	if err != nil {
+		return err
+	}
+	var channels []*store.Digest
+	var existing *store.Digest
+	var videoIDs []string
+	for _, d := range digests {
+		if d.ChannelID == store.DigestAllChannels {
+			existing = d
+			continue
+		}
+		channels = append(channels, d)
+		videoIDs = append(videoIDs, d.VideoIDs...)
+	}
+	slog.InfoContext(ctx, "Building all-channels digest", "period", period, "start", start.Format("2006-01-02"), "channels", len(channels))
+	if len(channels) == 0 {
+		return nil
+	}
+	if existing != nil && sameVideos(existing.VideoIDs, videoIDs) {
+		slog.InfoContext(ctx, "All-channels digest is up to date. Skipping.")
+		return nil
+	}
+
+	all := *channels[0]
+	all.ChannelID = store.DigestAllChannels
+	all.VideoIDs = videoIDs
+	all.CostUSD = 0
+	all.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	if len(channels) > 1 {
+		ledger := llm.NewLedger(dynamoClient, tableName)
+		if cfg.MonthlyBudgetUSD > 0 {
+			monthToDate, err := ledger.MonthToDate(ctx)
+			if err != nil {
+				return err
+			}
+			if monthToDate >= cfg.MonthlyBudgetUSD {
+				stats.SummarizationPaused = true
+				return fmt.Errorf("monthly LLM budget reached")
+			}
+		}
+
+		titles := map[string]string{}
+		var b strings.Builder
+		for _, d := range channels {
+			fmt.Fprintf(&b, "## チャンネル %s: %s\n%s\n", d.ChannelID, d.Headline, d.Summary)
+			for _, h := range d.Highlights {
+				titles[h.VideoID] = h.Title
+				fmt.Fprintf(&b, "- video_id: %s (%s): %s\n", h.VideoID, h.Title, h.Reason)
+			}
+			b.WriteString("\n")
+		}
+		combined, resp, err := generateDigest(ctx, periodLabel, b.String())
+		if err != nil {
+			return err
+		}
+		recordDigestUsage(ctx, store.DigestAllChannels, resp, ledger, stats)
+		all.Headline, all.Summary, all.Highlights = combined.Headline, combined.Summary, combined.Highlights
+		for i, h := range all.Highlights {
+			all.Highlights[i].Title = titles[h.VideoID]
+		}
+		all.CostUSD = resp.CostUSD
+	}
+
+	if err := store.SaveDigest(ctx, dynamoClient, tableName, &all); err != nil {
+		return err
+	}
+	stats.DigestsBuilt++
+	emailDigest(ctx, &all, stats)
+	return nil
+}
+
+// sameVideos reports whether a and b hold the same video IDs in any order.
+func sameVideos(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(a, b)
+}
+
+func recordDigestUsage(ctx context.Context, channelID string, resp *llm.Response, ledger *llm.Ledger, stats *BatchStats) {
+	stats.InputTokens += resp.Usage.InputTokens
+	stats.OutputTokens += resp.Usage.OutputTokens
+	stats.CostUSD += resp.CostUSD
+	if err := ledger.Record(ctx, channelID, resp.Usage, resp.CostUSD); err != nil {
+		slog.ErrorContext(ctx, "Error recording LLM usage for digest", "error", err)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/ttakahashi/youtube-summary/internal/mail"
+	"github.com/ttakahashi/youtube-summary/internal/store"
+)
+
+// loadMailer returns the sender for the SMTP server in SMTP_HOST, or nil
+// when email delivery is not configured. For a local SMTP sink such as
+// Mailpit, set SMTP_HOST=localhost and SMTP_PORT=1025 without a username.
+func loadMailer(ctx context.Context) (*mail.Sender, error) {
+	if cfg.SMTPHost == "" {
+		return nil, nil
+	}
+	config := mail.Config{
+		Host:     cfg.SMTPHost,
+		Port:     cfg.SMTPPort,
+		Username: cfg.SMTPUsername,
+		Password: cfg.SMTPPassword,
+		From:     cfg.MailFrom,
+	}
+	if name := cfg.SMTPPasswordSecret; name != "" {
+		password, err := secretStore.Get(ctx, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get SMTP password: %w", err)
+		}
+		config.Password = password
+	}
+	return mail.NewSender(config)
+}
+
+// emailSummary mails a saved summary to the subscribers of its channel who
+// want every summary.
+func emailSummary(ctx context.Context, channelID string, video VideoDetails, summary *SummaryData, stats *BatchStats) {
+	if mailer == nil {
+		return
+	}
+	subscribers, err := store.ListSubscribers(ctx, dynamoClient, tableName, channelID, store.DeliverSummaries)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error listing subscribers", "error", err)
+		stats.EmailsFailed++
+		return
+	}
+	s := &mail.Summary{
+		VideoID:       video.ID,
+		Title:         video.Title,
+		ChannelTitle:  video.ChannelTitle,
+		URL:           "https://www.youtube.com/watch?v=" + video.ID,
+		PublishedAt:   video.PublishedAt,
+		ShortSummary:  summary.ShortSummary,
+		DetailSummary: summary.DetailSummary,
+	}
+	if video.Thumbnails != nil && video.Thumbnails.Medium != nil {
+		s.ThumbnailURL = video.Thumbnails.Medium.Url
+	}
+	for _, sub := range subscribers {
+		msg, err := mail.SummaryMessage(sub.Email, mail.UnsubscribeURL(cfg.APIBaseURL, sub), s)
+		if err == nil {
+			err = mailer.Send(msg)
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "Error mailing summary", "error", err)
+			stats.EmailsFailed++
+			continue
+		}
+		stats.EmailsSent++
+	}
+}
+
+// emailDigest mails d to the subscribers of its channel who chose its period
+// and have not been sent a digest of the same period and channel before.
+func emailDigest(ctx context.Context, d *store.Digest, stats *BatchStats) {
+	if mailer == nil {
+		return
+	}
+	subscribers, err := store.ListSubscribers(ctx, dynamoClient, tableName, d.ChannelID, d.Period)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error listing subscribers", "error", err)
+		stats.EmailsFailed++
+		return
+	}
+	for _, sub := range subscribers {
+		first, err := store.MarkDigestSent(ctx, dynamoClient, tableName, d, sub.Email)
+		if err != nil {
+			slog.ErrorContext(ctx, "Error marking digest sent", "period", d.Period, "error", err)
+			stats.EmailsFailed++
+			continue
+		}
+		if !first {
+			continue
+		}
+		msg, err := mail.DigestMessage(sub.Email, mail.UnsubscribeURL(cfg.APIBaseURL, sub), d)
+		if err == nil {
+			err = mailer.Send(msg)
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "Error mailing digest", "period", d.Period, "error", err)
+			stats.EmailsFailed++
+			if err := store.UnmarkDigestSent(ctx, dynamoClient, tableName, d, sub.Email); err != nil {
+				slog.ErrorContext(ctx, "Error unmarking digest sent", "period", d.Period, "error", err)
+			}
+			continue
+		}
+		stats.EmailsSent++
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/ttakahashi/youtube-summary/internal/logging"
+	"github.com/ttakahashi/youtube-summary/internal/store"
+	"google.golang.org/api/youtube/v3"
+)
+
+// A queued job fails after this many runs without a summary, e.g. when the
+// video never gets captions. Runs spent waiting for a stream do not count.
+const maxJobAttempts = 5
+
+// listQueuedJobs returns the jobs submitted through the API that are
+// waiting for a run.
+func listQueuedJobs(ctx context.Context) ([]*store.Job, error) {
+	return store.ListJobs(ctx, dynamoClient, tableName, store.JobQueued)
+}
+
+// wrongChannel reports whether video is not of the channel job expects it
+// from, e.g. when a WebSub push named a video of another channel.
+func wrongChannel(job *store.Job, video *youtube.Video) bool {
+	return job.ChannelID != "" && video.Snippet.ChannelId != job.ChannelID
+}
+
+// finishJobs records the outcome of this run for each queued job. videos
+// are the Videos.List results of the run, by ID.
+func finishJobs(ctx context.Context, jobs []*store.Job, videos map[string]*youtube.Video) {
+	for _, job := range jobs {
+		ctx := logging.With(ctx, logging.KeyVideoID, job.VideoID, "jobId", job.ID)
+		item, err := getVideoItem(ctx, job.VideoID)
+		if err != nil {
+			slog.ErrorContext(ctx, "Error checking job", "error", err)
+			continue
+		}
+		video := videos[job.VideoID]
+
+		switch {
+		case store.HasSummary(item):
+			job.Status = store.JobDone
+			job.Error = ""
+			if v, ok := item["hashtag"].(*types.AttributeValueMemberS); ok {
+				job.ChannelID = v.Value
+			}
+		case video == nil:
+			job.Status = store.JobFailed
+			job.Error = "video not found or not public"
+		case wrongChannel(job, video):
+			job.Status = store.JobFailed
+			job.Error = fmt.Sprintf("video is not of channel %s", job.ChannelID)
+		case video.Snippet.LiveBroadcastContent == "upcoming" || video.Snippet.LiveBroadcastContent == "live":
+			// Wait for the archive without using up attempts
+			job.ChannelID = video.Snippet.ChannelId
+		default:
+			job.ChannelID = video.Snippet.ChannelId
+			job.Attempts++
+			if job.Attempts >= maxJobAttempts {
+				job.Status = store.JobFailed
+				job.Error = fmt.Sprintf("no summary after %d runs; the video may have no captions", job.Attempts)
+			}
+		}
+
+		if err := store.SaveJob(ctx, dynamoClient, tableName, job); err != nil {
+			slog.ErrorContext(ctx, "Error saving job", "error", err)
+			continue
+		}
+		slog.InfoContext(ctx, "Job updated", "status", job.Status)
+	}
+}
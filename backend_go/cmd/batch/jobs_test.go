@@ -0,0 +1,27 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/ttakahashi/youtube-summary/internal/store"
+	"google.golang.org/api/youtube/v3"
+)
+
+func TestWrongChannel(t *testing.T) {
+	tests := []struct {
+		jobChannel   string
+		videoChannel string
+		want         bool
+	}{
+		{jobChannel: "", videoChannel: "UCa", want: false},
+		{jobChannel: "UCa", videoChannel: "UCa", want: false},
+		{jobChannel: "UCa", videoChannel: "UCb", want: true},
+	}
+	for _, tt := range tests {
+		job := &store.Job{VideoID: "abcdefghijk", ChannelID: tt.jobChannel}
+		video := &youtube.Video{Id: "abcdefghijk", Snippet: &youtube.VideoSnippet{ChannelId: tt.videoChannel}}
+		if got := wrongChannel(job, video); got != tt.want {
+			t.Errorf("wrongChannel(job of %q, video of %q) = %v, want %v", tt.jobChannel, tt.videoChannel, got, tt.want)
+		}
+	}
+}
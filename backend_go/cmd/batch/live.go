@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"google.golang.org/api/youtube/v3"
+)
+
+// Live status values stored in the "liveStatus" attribute.
+const (
+	liveStatusUpcoming         = "upcoming"          // scheduled stream or premiere
+	liveStatusLive             = "live"              // currently streaming
+	liveStatusAwaitingCaptions = "awaiting_captions" // archive exists, captions not yet
+)
+
+// Videos still waiting for their stream to end or their captions to appear
+// are kept under "pending#<channelID>", one item per video ID, so that every
+// run revisits them even after they drop out of the search results.
+func pendingPartition(channelID string) string {
+	return "pending#" + channelID
+}
+
+// applyLiveDetails copies the live broadcast state of item into video.
+func applyLiveDetails(video *VideoDetails, item *youtube.Video) {
+	video.LiveStatus = ""
+	switch item.Snippet.LiveBroadcastContent {
+	case "upcoming":
+		video.LiveStatus = liveStatusUpcoming
+	case "live":
+		video.LiveStatus = liveStatusLive
+	}
+
+	d := item.LiveStreamingDetails
+	if d == nil {
+		return
+	}
+	video.ScheduledStartTime = d.ScheduledStartTime
+	video.ActualStartTime = d.ActualStartTime
+	video.ActualEndTime = d.ActualEndTime
+
+	if d.ActualStartTime != "" && d.ActualEndTime != "" {
+		start, err1 := time.Parse(time.RFC3339, d.ActualStartTime)
+		end, err2 := time.Parse(time.RFC3339, d.ActualEndTime)
+		if err1 == nil && err2 == nil && end.After(start) {
+			video.StreamDurationSeconds = int64(end.Sub(start).Seconds())
+		}
+	}
+}
+
+// listPendingVideos returns the IDs of channelID's pending videos.
+func listPendingVideos(ctx context.Context, channelID string) ([]string, error) {
+	var ids []string
+	var lastEvaluatedKey map[string]types.AttributeValue
+	for {
+		resp, err := dynamoClient.Query(ctx, &dynamodb.QueryInput{
+			TableName:              aws.String(tableName),
+			KeyConditionExpression: aws.String("hashtag = :p"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":p": &types.AttributeValueMemberS{Value: pendingPartition(channelID)},
+			},
+			ExclusiveStartKey: lastEvaluatedKey,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list pending videos: %w", err)
+		}
+		for _, item := range resp.Items {
+			if v, ok := item["processedAt"].(*types.AttributeValueMemberS); ok {
+				ids = append(ids, v.Value)
+			}
+		}
+		if resp.LastEvaluatedKey == nil {
+			return ids, nil
+		}
+		lastEvaluatedKey = resp.LastEvaluatedKey
+	}
+}
+
+// markPending records that video must be revisited on later runs.
+func markPending(ctx context.Context, channelID string, video VideoDetails, status string) error {
+	item := map[string]types.AttributeValue{
+		"hashtag":     &types.AttributeValueMemberS{Value: pendingPartition(channelID)},
+		"processedAt": &types.AttributeValueMemberS{Value: video.ID},
+		"liveStatus":  &types.AttributeValueMemberS{Value: status},
+		"updatedAt":   &types.AttributeValueMemberS{Value: time.Now().UTC().Format(time.RFC3339)},
+	}
+	if video.ScheduledStartTime != "" {
+		item["scheduledStartTime"] = &types.AttributeValueMemberS{Value: video.ScheduledStartTime}
+	}
+	_, err := dynamoClient.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(tableName),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to mark %s pending: %w", video.ID, err)
+	}
+	return nil
+}
+
+// clearPending stops revisiting videoID.
+func clearPending(ctx context.Context, channelID, videoID string) error {
+	_, err := dynamoClient.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]types.AttributeValue{
+			"hashtag":     &types.AttributeValueMemberS{Value: pendingPartition(channelID)},
+			"processedAt": &types.AttributeValueMemberS{Value: videoID},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to clear pending %s: %w", videoID, err)
+	}
+	return nil
+}
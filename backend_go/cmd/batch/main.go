@@ -3,111 +3,290 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"log/slog"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-lambda-go/lambdacontext"
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
-	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
 	"github.com/horiagug/youtube-transcript-api-go/pkg/yt_transcript"
+	"github.com/ttakahashi/youtube-summary/internal/blob"
+	"github.com/ttakahashi/youtube-summary/internal/config"
+	"github.com/ttakahashi/youtube-summary/internal/i18n"
+	"github.com/ttakahashi/youtube-summary/internal/llm"
+	"github.com/ttakahashi/youtube-summary/internal/logging"
+	"github.com/ttakahashi/youtube-summary/internal/mail"
+	"github.com/ttakahashi/youtube-summary/internal/notify"
+	"github.com/ttakahashi/youtube-summary/internal/quota"
+	"github.com/ttakahashi/youtube-summary/internal/secrets"
+	"github.com/ttakahashi/youtube-summary/internal/store"
+	"github.com/ttakahashi/youtube-summary/internal/tags"
+	"github.com/ttakahashi/youtube-summary/internal/transcript"
 	"google.golang.org/api/option"
 	"google.golang.org/api/youtube/v3"
 )
 
 // Global Configuration
 var (
-	dynamoClient   *dynamodb.Client
-	secretsClient  *secretsmanager.Client
-	bedrockClient  *bedrockruntime.Client
-	tableName      string
-	minViewCount   uint64
-	minLikeCount   uint64
+	cfg          *config.Config
+	dynamoClient *dynamodb.Client
+	secretStore  secrets.Provider
+	llmClient    *llm.Client
+	tableName    string
+	minViewCount uint64
+	minLikeCount uint64
+
+	// Large transcripts (and optionally detail summaries) are offloaded here
+	blobStore              blob.Store
+	transcriptOffloadBytes = 100 * 1024
+	offloadDetailSummary   bool
+
+	// Comment summaries are an optional stage after summarization
+	commentSummaryEnabled bool
+
+	// Summaries are also translated into these languages at batch time
+	summaryLanguages []string
+
+	// New summaries are announced to chat and webhooks, and mailed to
+	// subscribers; both are optional
+	notifier *notify.Notifier
+	mailer   *mail.Sender
+
+	// Tags and entities are normalized against this vocabulary
+	tagVocabulary = tags.DefaultVocabulary
+
+	// Shorts handling, from the configuration and the channel
+	shortsMode       string
+	shortsMaxSeconds int64
 )
 
 type BatchStats struct {
-	VideosFound            int `json:"videos_found"`
-	VideosFiltered         int `json:"videos_filtered"`
-	VideosWithoutTx        int `json:"videos_without_transcript"`
-	VideosAlreadyProcessed int `json:"videos_already_processed"`
-	VideosSummarized       int `json:"videos_summarized"`
-	Errors                 int `json:"errors"`
+	VideosFound            int     `json:"videos_found"`
+	VideosFiltered         int     `json:"videos_filtered"`
+	VideosWithoutTx        int     `json:"videos_without_transcript"`
+	VideosAlreadyProcessed int     `json:"videos_already_processed"`
+	VideosSummarized       int     `json:"videos_summarized"`
+	Errors                 int     `json:"errors"`
+	QuotaUnitsUsed         int64   `json:"quota_units_used"`
+	QuotaUnitsToday        int64   `json:"quota_units_today"`
+	InputTokens            int64   `json:"input_tokens"`
+	OutputTokens           int64   `json:"output_tokens"`
+	CostUSD                float64 `json:"cost_usd"`
+	SummarizationPaused    bool    `json:"summarization_paused"`
+	VideosPendingLive      int     `json:"videos_pending_live"`
+	ShortsFound            int     `json:"shorts_found"`
+	ShortsSkipped          int     `json:"shorts_skipped"`
+	ShortsDigested         int     `json:"shorts_digested"`
+	StatsRefreshed         int     `json:"stats_refreshed"`
+	VideosUnavailable      int     `json:"videos_unavailable"`
+	VideosRestored         int     `json:"videos_restored"`
+	CommentsSummarized     int     `json:"comments_summarized"`
+	DigestsBuilt           int     `json:"digests_built"`
+	NotificationsFailed    int     `json:"notifications_failed"`
+	EmailsSent             int     `json:"emails_sent"`
+	EmailsFailed           int     `json:"emails_failed"`
+}
+
+// BatchEvent selects the job a run performs. The zero value is the regular
+// search-and-summarize run.
+type BatchEvent struct {
+	Job string `json:"job"`
+
+	// Limits the run to this channel, as when started from the admin API
+	ChannelID string `json:"channelId,omitempty"`
 }
 
+// Jobs other than the regular run
+const (
+	jobRefreshStats    = "refresh-stats"    // re-read statistics of recent videos
+	jobReconcile       = "reconcile"        // mark deleted, private and blocked videos
+	jobRefreshComments = "refresh-comments" // re-summarize comments of recent videos
+	jobProcessJobs     = "process-jobs"     // only the videos submitted through the API
+	jobDailyDigest     = "daily-digest"     // digest of yesterday's videos
+	jobWeeklyDigest    = "weekly-digest"    // digest of last week's videos
+	jobRenewWebSub     = "renew-websub"     // renew the push subscription to new uploads
+)
+
 type VideoDetails struct {
 	ID           string
 	Title        string
 	ChannelTitle string
 	PublishedAt  string
+	Description  string
 	Thumbnails   *youtube.ThumbnailDetails
 	ViewCount    uint64
 	LikeCount    uint64
+
+	// Sort key of the stored item, kept so that updates replace it
+	ProcessedAt string
+
+	DurationSeconds int64
+	IsShort         bool
+	ShortsHandling  string // set when a Short was skipped or digested
+	ShortsDigestAt  string // sort key of the digest covering this Short
+
+	// Live streams and premieres
+	LiveStatus            string
+	ScheduledStartTime    string
+	ActualStartTime       string
+	ActualEndTime         string
+	StreamDurationSeconds int64
 }
 
 type SummaryData struct {
-	ShortSummary  string `json:"short_summary"`
-	DetailSummary string `json:"detail_summary"`
+	ShortSummary  string    `json:"short_summary"`
+	DetailSummary string    `json:"detail_summary"`
+	Chapters      []Chapter `json:"chapters,omitempty"`
+
+	// Normalized against tagVocabulary after parsing
+	Tags     []string      `json:"tags"`
+	Entities tags.Entities `json:"entities"`
+	Category string        `json:"category"`
+
+	// Translations of the summaries by language
+	Translations map[string]*i18n.Translation `json:"-"`
+
+	// Filled from the model response, not the model output
+	ChaptersSource string    `json:"-"` // "description" or "inferred"
+	Usage          llm.Usage `json:"-"`
+	CostUSD        float64   `json:"-"`
 }
 
-func init() {
+// setup creates the clients once the configuration is loaded.
+func setup() {
 	// Initialize AWS clients
-	cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion("ap-northeast-1"))
+	awsCfg, err := awsconfig.LoadDefaultConfig(context.TODO(), awsconfig.WithRegion(cfg.Region))
 	if err != nil {
 		log.Fatalf("unable to load SDK config, %v", err)
 	}
 
-	dynamoClient = dynamodb.NewFromConfig(cfg)
-	secretsClient = secretsmanager.NewFromConfig(cfg)
+	dynamoClient = dynamodb.NewFromConfig(awsCfg)
 
-	// Bedrock client needs us-east-1 region for Claude models
-	bedrockCfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion("us-east-1"))
+	// Kept across warm invocations, so cached secrets are reused
+	secretStore, err = secrets.Open(context.TODO(), cfg.SecretsBackend, cfg.Region, time.Duration(cfg.SecretsCacheTTL)*time.Second)
+	if err != nil {
+		log.Fatalf("unable to open secrets backend, %v", err)
+	}
+
+	// Claude models may only be offered in another region
+	bedrockCfg, err := awsconfig.LoadDefaultConfig(context.TODO(), awsconfig.WithRegion(cfg.BedrockRegion))
 	if err != nil {
 		log.Fatalf("unable to load Bedrock SDK config, %v", err)
 	}
-	bedrockClient = bedrockruntime.NewFromConfig(bedrockCfg)
-}
 
-func getSecret(ctx context.Context, secretName string) (string, error) {
-	input := &secretsmanager.GetSecretValueInput{
-		SecretId: aws.String(secretName),
+	// Both were validated with the configuration
+	prices := llm.DefaultPrices
+	if cfg.PriceTable != "" {
+		prices, _ = llm.ParsePriceTable(cfg.PriceTable)
 	}
-	result, err := secretsClient.GetSecretValue(ctx, input)
-	if err != nil {
-		return "", err
+	llmClient = llm.NewClient(bedrockruntime.NewFromConfig(bedrockCfg), cfg.ModelID, prices)
+
+	if cfg.TagVocabulary != "" {
+		tagVocabulary, _ = tags.ParseVocabulary(cfg.TagVocabulary)
 	}
-	if result.SecretString != nil {
-		return *result.SecretString, nil
+
+	blobStore, err = blob.Open(context.TODO(), cfg.BlobStore, cfg.Region)
+	if err != nil {
+		log.Fatalf("unable to open blob store, %v", err)
 	}
-	return "", fmt.Errorf("secret string is empty")
+	transcriptOffloadBytes = cfg.TranscriptOffloadBytes
+	offloadDetailSummary = cfg.OffloadDetailSummary
 }
 
-// getTranscript uses youtube-transcript-api-go to fetch subtitles
-func getTranscript(videoID string) (string, error) {
+// getTranscript uses youtube-transcript-api-go to fetch timed subtitle segments
+func getTranscript(videoID string) ([]transcript.Segment, error) {
 	client := yt_transcript.NewClient()
-	// Try Japanese first
+	// Try Japanese first. GetTranscripts always keeps formatting tags, so take
+	// the JSON formatter output with them stripped and read it back.
 	text, err := client.GetFormattedTranscripts(videoID, []string{"ja"}, false)
 	if err != nil {
-		return "", fmt.Errorf("failed to get transcript: %w", err)
+		return nil, fmt.Errorf("failed to get transcript: %w", err)
+	}
+	segments, err := transcript.ParseLegacy(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transcript: %w", err)
+	}
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("failed to get transcript: no caption lines")
 	}
-	return text, nil
+	return segments, nil
 }
 
-func generateSummary(ctx context.Context, transcript, title string) (*SummaryData, error) {
+// chapterPrompt returns the chapter task and output format to add to the
+// summary prompt, or empty strings when the video gets no chapters.
+func chapterPrompt(chapters []Chapter, segments []transcript.Segment) (task, format string) {
+	format = `,
+  "chapters": [
+    {"start": "0:00", "title": "...", "summary": "..."}
+  ]`
+
+	if len(chapters) > 0 {
+		var list strings.Builder
+		for _, c := range chapters {
+			fmt.Fprintf(&list, "%s %s\n", transcript.FormatTimestamp(c.Start), c.Title)
+		}
+		task = `
+3. chapters: 以下のチャプター一覧の各チャプターについて、200文字程度の要約 (summary) を作成すること。start と title はチャプター一覧のものをそのまま使うこと
+
+チャプター一覧:
+` + list.String()
+		return task, format
+	}
+
+	if len(segments) == 0 || segments[len(segments)-1].End() < minInferredChapterSeconds {
+		return "", ""
+	}
+	task = `
+3. chapters: 動画の内容を話題の切れ目で5〜15程度のチャプターに分け、各チャプターの開始位置 (start: 字幕テキストの [分:秒] から選ぶ)、短い見出し (title)、200文字程度の要約 (summary) を出力すること。最初のチャプターは 0:00 から始めること
+`
+	return task, format
+}
+
+func generateSummary(ctx context.Context, segments []transcript.Segment, video VideoDetails) (*SummaryData, error) {
+	title := video.Title
+
+	// Shorts get a lighter prompt without chapters when configured so
+	shortLength, detailLength := 400, 4000
+	var chapters []Chapter
+	var chapterTask, chapterFormat string
+	if video.IsShort && shortsMode == shortsModeBrief {
+		shortLength, detailLength = 150, 800
+	} else {
+		chapters = parseChapters(video.Description)
+		chapterTask, chapterFormat = chapterPrompt(chapters, segments)
+	}
+
+	// Blocks of about 30 seconds keep the timestamps useful without
+	// spending too many tokens on them
+	text := transcript.Timestamped(segments, 30)
+
 	// Truncate transcript to avoid token limits
-	if len(transcript) > 20000 {
-		transcript = transcript[:20000]
+	if len(text) > 20000 {
+		text = text[:20000]
 	}
 
 	prompt := fmt.Sprintf(`以下のYouTube動画の字幕テキストを元に、以下の2種類の要約をJSON形式で出力してください。
 
-1. short_summary: 400文字程度の簡潔な要約（動画を見るかどうか判断できる情報を含める）
-2. detail_summary: 4000文字程度の詳細な要約（動画の内容を詳細に解説し、視聴しなくても内容が分かるレベルにする。章立てや箇条書き（Markdown形式）を使って読みやすくすること）
+1. short_summary: %d文字程度の簡潔な要約（動画を見るかどうか判断できる情報を含める）
+2. detail_summary: %d文字程度の詳細な要約（動画の内容を詳細に解説し、視聴しなくても内容が分かるレベルにする。章立てや箇条書き（Markdown形式）を使って読みやすくすること）%s
+
+あわせて、動画を話題別に分類するための以下の情報も出力してください。
+- tags: 動画の主なキーワード (5〜10件、各20文字以内の名詞)
+- entities: 言及されている固有名詞。people (人物)、organizations (組織・企業)、products (製品・サービス) に分ける
+- category: 動画のカテゴリ。次のいずれか1つ: %s
+
+字幕テキストの各ブロックの先頭には [分:秒] 形式の再生位置が付いています。detail_summary では、各トピックの説明に対応する再生位置を [12:34] のように同じ形式で添えてください。
 
 動画タイトル: %s
 
@@ -117,56 +296,19 @@ func generateSummary(ctx context.Context, transcript, title string) (*SummaryDat
 出力形式（必ずこのJSONフォーマットのみを出力してください）:
 {
   "short_summary": "...",
-  "detail_summary": "..."
-}`, title, transcript)
-
-	// Claude Messages API request body
-	reqBody := map[string]interface{}{
-		"anthropic_version": "bedrock-2023-05-31",
-		"max_tokens":        4096, // Increased tokens for detailed summary
-		"messages": []map[string]string{
-			{"role": "user", "content": prompt},
-		},
-	}
+  "detail_summary": "...",
+  "tags": ["..."],
+  "entities": {"people": ["..."], "organizations": ["..."], "products": ["..."]},
+  "category": "..."%s
+}`, shortLength, detailLength, chapterTask, strings.Join(tags.Categories, ", "), title, text, chapterFormat)
 
-	reqJSON, err := json.Marshal(reqBody)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	resp, err := bedrockClient.InvokeModel(ctx, &bedrockruntime.InvokeModelInput{
-		ModelId:     aws.String("arn:aws:bedrock:us-east-1:031921999648:inference-profile/global.anthropic.claude-haiku-4-5-20251001-v1:0"),
-		ContentType: aws.String("application/json"),
-		Body:        reqJSON,
-	})
+	resp, err := llmClient.Invoke(ctx, prompt, 8192) // Room for the detailed summary and chapter summaries
 	if err != nil {
-		return nil, fmt.Errorf("bedrock invoke failed: %w", err)
+		return nil, err
 	}
 
-	// Parse response from Bedrock
-	var result struct {
-		Content []struct {
-			Text string `json:"text"`
-		} `json:"content"`
-	}
-	if err := json.Unmarshal(resp.Body, &result); err != nil {
-		return nil, fmt.Errorf("failed to parse bedrock response: %w", err)
-	}
-
-	if len(result.Content) == 0 {
-		return nil, fmt.Errorf("no content in response")
-	}
-
-	responseText := result.Content[0].Text
-
 	// Clean up response text (remove markdown code blocks if present)
-	responseText = strings.TrimSpace(responseText)
-	if strings.Contains(responseText, "```") {
-		// Remove ```json and ``` 
-		responseText = strings.ReplaceAll(responseText, "```json", "")
-		responseText = strings.ReplaceAll(responseText, "```", "")
-		responseText = strings.TrimSpace(responseText)
-	}
+	responseText := llm.StripCodeFence(resp.Text)
 
 	// Parse JSON output from Claude
 	var summaryData SummaryData
@@ -174,55 +316,40 @@ func generateSummary(ctx context.Context, transcript, title string) (*SummaryDat
 		// Fallback: try to extract JSON if Claude added text usually shouldn't happen with strict prompt
 		return nil, fmt.Errorf("failed to parse summary json: %w. Response: %s", err, responseText)
 	}
+	summaryData.Usage = resp.Usage
+	summaryData.CostUSD = resp.CostUSD
 
-	return &summaryData, nil
-}
-
-func getVideoItem(ctx context.Context, videoID string) (map[string]types.AttributeValue, error) {
-	// Query GSI to get Primary Key
-	input := &dynamodb.QueryInput{
-		TableName:              aws.String(tableName),
-		IndexName:              aws.String("videoId-index"),
-		KeyConditionExpression: aws.String("videoId = :vid"),
-		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":vid": &types.AttributeValueMemberS{Value: videoID},
-		},
-		Limit: aws.Int32(1),
-	}
-
-	resp, err := dynamoClient.Query(ctx, input)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query index: %w", err)
-	}
-
-	if len(resp.Items) == 0 {
-		return nil, nil // Not found
-	}
-
-	// Found in index. Get full item from base table.
-	item := resp.Items[0]
-	hashtag := item["hashtag"].(*types.AttributeValueMemberS).Value
-	processedAt := item["processedAt"].(*types.AttributeValueMemberS).Value
+	summaryData.Tags = tagVocabulary.NormalizeAll(summaryData.Tags)
+	summaryData.Entities = tagVocabulary.NormalizeEntities(summaryData.Entities)
+	summaryData.Category = tags.Category(summaryData.Category)
 
-	getItemInput := &dynamodb.GetItemInput{
-		TableName: aws.String(tableName),
-		Key: map[string]types.AttributeValue{
-			"hashtag":     &types.AttributeValueMemberS{Value: hashtag},
-			"processedAt": &types.AttributeValueMemberS{Value: processedAt},
-		},
+	summaryData.Chapters = normalizeChapters(summaryData.Chapters)
+	switch {
+	case len(chapters) > 0:
+		summaryData.ChaptersSource = "description"
+		if len(summaryData.Chapters) == 0 {
+			// Keep the navigation even if the model skipped the summaries
+			summaryData.Chapters = chapters
+		}
+	case chapterTask != "" && len(summaryData.Chapters) > 0:
+		summaryData.ChaptersSource = "inferred"
+	default:
+		summaryData.Chapters = nil
 	}
 
-	getResult, err := dynamoClient.GetItem(ctx, getItemInput)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get item: %w", err)
-	}
+	return &summaryData, nil
+}
 
-	return getResult.Item, nil
+func getVideoItem(ctx context.Context, videoID string) (map[string]types.AttributeValue, error) {
+	return store.GetVideoItem(ctx, dynamoClient, tableName, videoID)
 }
 
-func saveVideoData(ctx context.Context, channelID string, video VideoDetails, transcript string, summary *SummaryData) error {
-	log.Printf("DEBUG: Saving video data for %s to table %s", video.ID, tableName)
+func saveVideoData(ctx context.Context, channelID string, video VideoDetails, segments []transcript.Segment, summary *SummaryData) error {
+	slog.DebugContext(ctx, "Saving video data", "table", tableName)
 	now := time.Now().UTC().Format(time.RFC3339)
+	if video.ProcessedAt != "" {
+		now = video.ProcessedAt
+	}
 
 	// Flatten thumbnails to a map if needed, or store as Map/JSON
 	// For simplicity, we just store the medium URL
@@ -241,12 +368,94 @@ func saveVideoData(ctx context.Context, channelID string, video VideoDetails, tr
 		"viewCount":    &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", video.ViewCount)},
 		"likeCount":    &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", video.LikeCount)},
 		"thumbnailUrl": &types.AttributeValueMemberS{Value: thumbURL},
-		"transcript":   &types.AttributeValueMemberS{Value: transcript},
+	}
+
+	if video.LiveStatus != "" {
+		item["liveStatus"] = &types.AttributeValueMemberS{Value: video.LiveStatus}
+	}
+	for name, value := range map[string]string{
+		"scheduledStartTime": video.ScheduledStartTime,
+		"actualStartTime":    video.ActualStartTime,
+		"actualEndTime":      video.ActualEndTime,
+	} {
+		if value != "" {
+			item[name] = &types.AttributeValueMemberS{Value: value}
+		}
+	}
+	if video.DurationSeconds > 0 {
+		item["durationSeconds"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(video.DurationSeconds, 10)}
+	}
+	item["isShort"] = &types.AttributeValueMemberBOOL{Value: video.IsShort}
+	if video.ShortsHandling != "" {
+		item["shortsHandling"] = &types.AttributeValueMemberS{Value: video.ShortsHandling}
+	}
+	if video.ShortsDigestAt != "" {
+		item["shortsDigestAt"] = &types.AttributeValueMemberS{Value: video.ShortsDigestAt}
+	}
+	if video.StreamDurationSeconds > 0 {
+		item["streamDurationSeconds"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(video.StreamDurationSeconds, 10)}
+	}
+
+	// Timed segments replace the old flattened "transcript" attribute.
+	// Long ones go to the blob store to stay clear of the 400KB item limit.
+	if len(segments) > 0 {
+		encodedSegments, err := transcript.Encode(segments)
+		if err != nil {
+			return err
+		}
+		if blobStore != nil && len(encodedSegments) > transcriptOffloadBytes {
+			key := store.TranscriptBlobKey(video.ID)
+			if err := blob.PutCompressed(ctx, blobStore, key, []byte(encodedSegments)); err != nil {
+				return err
+			}
+			item["transcriptRef"] = &types.AttributeValueMemberS{Value: key}
+		} else {
+			item["transcriptSegments"] = &types.AttributeValueMemberS{Value: encodedSegments}
+		}
 	}
 
 	if summary != nil {
 		item["summary"] = &types.AttributeValueMemberS{Value: summary.ShortSummary}
-		item["detailSummary"] = &types.AttributeValueMemberS{Value: summary.DetailSummary}
+		switch {
+		case summary.DetailSummary == "":
+			// Shorts summarized in a digest only have a short summary
+		case blobStore != nil && offloadDetailSummary:
+			key := store.DetailSummaryBlobKey(video.ID)
+			if err := blob.PutCompressed(ctx, blobStore, key, []byte(summary.DetailSummary)); err != nil {
+				return err
+			}
+			item["detailSummaryRef"] = &types.AttributeValueMemberS{Value: key}
+		default:
+			item["detailSummary"] = &types.AttributeValueMemberS{Value: summary.DetailSummary}
+		}
+		item["inputTokens"] = &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", summary.Usage.InputTokens)}
+		item["outputTokens"] = &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", summary.Usage.OutputTokens)}
+		item["costUsd"] = &types.AttributeValueMemberN{Value: strconv.FormatFloat(summary.CostUSD, 'f', -1, 64)}
+		if len(summary.Chapters) > 0 {
+			item["chapters"] = chaptersAttribute(summary.Chapters)
+			item["chaptersSource"] = &types.AttributeValueMemberS{Value: summary.ChaptersSource}
+		}
+		for lang, t := range summary.Translations {
+			if blobStore != nil && offloadDetailSummary {
+				key := store.TranslatedDetailSummaryBlobKey(video.ID, lang)
+				if err := blob.PutCompressed(ctx, blobStore, key, []byte(t.DetailSummary)); err != nil {
+					return err
+				}
+				t.DetailSummaryRef = key
+			}
+			item[i18n.AttributeName(lang)] = t.Attribute()
+		}
+		if len(summary.Tags) > 0 {
+			item["tags"] = stringList(summary.Tags)
+		}
+		if summary.Category != "" {
+			item["topicCategory"] = &types.AttributeValueMemberS{Value: summary.Category}
+		}
+		item["entities"] = &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+			"people":        stringList(summary.Entities.People),
+			"organizations": stringList(summary.Entities.Organizations),
+			"products":      stringList(summary.Entities.Products),
+		}}
 	}
 
 	_, err := dynamoClient.PutItem(ctx, &dynamodb.PutItemInput{
@@ -256,88 +465,324 @@ func saveVideoData(ctx context.Context, channelID string, video VideoDetails, tr
 	return err
 }
 
-func handler(ctx context.Context) (BatchStats, error) {
-	log.Println("Starting batch processing (Go) - Channel mode (Search.List)")
-
-	stats := BatchStats{}
-	tableName = os.Getenv("DYNAMODB_TABLE")
-	if tableName == "" {
-		tableName = "youtube-summary-dev"
+// stringList converts values to a DynamoDB list.
+func stringList(values []string) types.AttributeValue {
+	list := make([]types.AttributeValue, len(values))
+	for i, v := range values {
+		list[i] = &types.AttributeValueMemberS{Value: v}
 	}
+	return &types.AttributeValueMemberL{Value: list}
+}
 
-	// Get channel ID from environment
-	channelID := os.Getenv("CHANNEL_ID")
-	if channelID == "" {
-		// For backward compatibility or testing defaults
-		channelID = "UC2kM01yXNnouBsJJ0ghyfMg"
+// fetchVideos looks up the details of ids, 50 per Videos.List call.
+func fetchVideos(ctx context.Context, ytService *youtube.Service, meter *quota.Meter, ids []string) ([]*youtube.Video, error) {
+	var videos []*youtube.Video
+	for start := 0; start < len(ids); start += 50 {
+		end := min(start+50, len(ids))
+		call := ytService.Videos.List([]string{"snippet", "statistics", "contentDetails", "liveStreamingDetails"}).
+			Id(strings.Join(ids[start:end], ","))
+
+		if err := meter.Charge(ctx, "videos.list"); err != nil {
+			return videos, err
+		}
+		resp, err := call.Do()
+		if err != nil {
+			return videos, fmt.Errorf("error fetching video details: %w", err)
+		}
+		videos = append(videos, resp.Items...)
 	}
-	log.Printf("Processing channel: %s", channelID)
+	return videos, nil
+}
 
-	// Get Secrets
-	ytSecret := os.Getenv("YOUTUBE_API_SECRET")
-	if ytSecret == "" {
-		ytSecret = "youtube-summary/youtube-api-key"
+func handler(ctx context.Context, event BatchEvent) (stats BatchStats, err error) {
+	// Lambda's request ID names the run, so its own log lines match ours
+	runID := logging.NewRunID()
+	if lc, ok := lambdacontext.FromContext(ctx); ok {
+		runID = lc.AwsRequestID
+	}
+	ctx = logging.With(ctx, logging.KeyRunID, runID, "job", event.Job)
+	if event.Job == "" {
+		slog.InfoContext(ctx, "Starting batch processing (Go) - Channel mode (Search.List)")
+	} else {
+		slog.InfoContext(ctx, "Starting batch job (Go)")
 	}
+	defer func() {
+		slog.InfoContext(ctx, "Batch finished", "stats", stats, "error", err)
+	}()
+
+	tableName = cfg.Table
 
-	ytKey, err := getSecret(ctx, ytSecret)
+	channels, err := batchChannels(ctx, event)
 	if err != nil {
-		log.Printf("Error getting YouTube API key: %v", err)
 		return stats, err
 	}
+	if event.Job == jobProcessJobs && len(channels) > 1 {
+		// Queued videos may belong to any channel; one pass handles them all
+		channels = channels[:1]
+	}
+
+	if notifier, err = loadNotifier(ctx); err != nil {
+		return stats, err
+	}
+	if mailer, err = loadMailer(ctx); err != nil {
+		return stats, err
+	}
+
+	// One failing channel does not stop the others, unless the YouTube
+	// quota is spent
+	var errs []error
+	for i, channel := range channels {
+		if err := runChannel(ctx, event, channel, i == 0, &stats); err != nil {
+			slog.ErrorContext(logging.With(ctx, logging.KeyChannelID, channel.ID), "Error processing channel", "error", err)
+			errs = append(errs, fmt.Errorf("channel %s: %w", channel.ID, err))
+			if errors.Is(err, quota.ErrBudgetExceeded) {
+				break
+			}
+		}
+	}
+
+	// The all-channels digest needs every channel's digest, so it waits for
+	// a run over all channels that succeeded
+	if event.ChannelID == "" && len(errs) == 0 {
+		var err error
+		switch event.Job {
+		case jobDailyDigest:
+			err = buildAllChannelsDigest(logging.Stage(ctx, event.Job), store.DigestDaily, &stats)
+		case jobWeeklyDigest:
+			err = buildAllChannelsDigest(logging.Stage(ctx, event.Job), store.DigestWeekly, &stats)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("all channels: %w", err))
+		}
+	}
+	return stats, errors.Join(errs...)
+}
+
+// runChannel performs event's job for one channel, adding to stats. Settings
+// of the channel override the environment. Only the first channel of a run
+// picks up videos submitted through the API.
+func runChannel(ctx context.Context, event BatchEvent, channel *store.Channel, first bool, stats *BatchStats) (err error) {
+	// Reset what an earlier channel of the run may have set
+	shortsMode = cfg.ShortsMode
+	shortsMaxSeconds = cfg.ShortsMaxSeconds
+	commentSummaryEnabled = cfg.CommentSummary
+
+	if channel.ShortsMode != "" {
+		shortsMode = channel.ShortsMode
+	}
+	languages := cfg.SummaryLanguages
+	if len(channel.SummaryLanguages) > 0 {
+		languages = channel.SummaryLanguages
+	}
+	summaryLanguages = nil
+	for _, tag := range languages {
+		if lang := i18n.Normalize(tag); lang != "" && lang != i18n.Source && !slices.Contains(summaryLanguages, lang) {
+			summaryLanguages = append(summaryLanguages, lang)
+		}
+	}
+	if channel.CommentSummary != nil {
+		commentSummaryEnabled = *channel.CommentSummary
+	}
+
+	channelID := channel.ID
+	ctx = logging.With(ctx, logging.KeyChannelID, channelID, logging.KeyStage, "setup")
+	slog.InfoContext(ctx, "Processing channel")
+
+	// Get Secrets
+	ytKey, err := secretStore.Get(ctx, cfg.YouTubeAPISecret)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error getting YouTube API key", "error", err)
+		return err
+	}
 
 	// YouTube Client
 	ytService, err := youtube.NewService(ctx, option.WithAPIKey(ytKey))
 	if err != nil {
-		log.Printf("Error creating YouTube service: %v", err)
-		return stats, err
+		slog.ErrorContext(ctx, "Error creating YouTube service", "error", err)
+		return err
 	}
 
-	// 1. Search for recent videos (including live archives)
-	// We use Search.List with order=date to get the latest videos.
-	searchCall := ytService.Search.List([]string{"id"}).
-		ChannelId(channelID).
-		Order("date").
-		Type("video").
-		MaxResults(50) // 50 is the maximum allowed by YouTube API per request
-
-	searchResp, err := searchCall.Do()
+	// Every YouTube call is charged against the daily quota budget
+	meter, err := quota.NewMeter(ctx, dynamoClient, tableName, cfg.YouTubeQuota)
 	if err != nil {
-		return stats, fmt.Errorf("error searching videos: %w", err)
+		return err
 	}
+	defer func() {
+		stats.QuotaUnitsUsed += meter.Spent()
+		stats.QuotaUnitsToday = meter.Used()
+	}()
+	slog.InfoContext(ctx, "YouTube quota", "usedToday", meter.Used(), "budget", meter.Budget())
 
-	stats.VideosFound = len(searchResp.Items)
-	log.Printf("Found %d videos in search results", stats.VideosFound)
+	// Summarization pauses once the month's LLM spend reaches the ceiling
+	ledger := llm.NewLedger(dynamoClient, tableName)
+	monthlyCeiling := cfg.MonthlyBudgetUSD
+	var monthToDate float64
+	if monthlyCeiling > 0 {
+		monthToDate, err = ledger.MonthToDate(ctx)
+		if err != nil {
+			return err
+		}
+		slog.InfoContext(ctx, "LLM spend this month", "monthToDateUsd", monthToDate, "budgetUsd", monthlyCeiling)
+	}
+	// monthToDate already counts what earlier channels of this run spent
+	costAtStart := stats.CostUSD
+	budgetReached := func() bool {
+		return monthlyCeiling > 0 && monthToDate+stats.CostUSD-costAtStart >= monthlyCeiling
+	}
 
-	if len(searchResp.Items) == 0 {
-		return stats, nil
+	if event.Job != "" {
+		ctx = logging.Stage(ctx, event.Job)
+	}
+	switch event.Job {
+	case "":
+	case jobRefreshStats:
+		return refreshStats(ctx, ytService, meter, channelID, stats)
+	case jobReconcile:
+		return reconcile(ctx, ytService, meter, channelID, stats)
+	case jobRefreshComments:
+		return refreshComments(ctx, ytService, meter, ledger, budgetReached, channelID, stats)
+	case jobDailyDigest:
+		return buildDigest(ctx, store.DigestDaily, channelID, ledger, budgetReached, stats)
+	case jobWeeklyDigest:
+		return buildDigest(ctx, store.DigestWeekly, channelID, ledger, budgetReached, stats)
+	case jobRenewWebSub:
+		return renewWebSub(ctx, channelID)
+	case jobProcessJobs:
+	default:
+		return fmt.Errorf("unknown job %q", event.Job)
 	}
 
-	// Collect Video IDs
 	var videoIDs []string
-	for _, item := range searchResp.Items {
-		videoIDs = append(videoIDs, item.Id.VideoId)
+	seen := map[string]bool{}
+	if event.Job == "" {
+		// The scheduled run keeps the push subscription alive, so pushes
+		// keep arriving between runs
+		if err := renewWebSub(logging.Stage(ctx, jobRenewWebSub), channelID); err != nil {
+			slog.ErrorContext(ctx, "Error renewing WebSub subscription", "error", err)
+		}
+
+		// Refuse to start a run that cannot complete within the budget
+		if !meter.Allows("search.list", "videos.list") {
+			return fmt.Errorf("not starting run: %w", quota.ErrBudgetExceeded)
+		}
+
+		// 1. Search for recent videos (including live archives)
+		ctx = logging.Stage(ctx, "search")
+		// We use Search.List with order=date to get the latest videos.
+		searchCall := ytService.Search.List([]string{"id"}).
+			ChannelId(channelID).
+			Order("date").
+			Type("video").
+			MaxResults(50) // 50 is the maximum allowed by YouTube API per request
+
+		if err := meter.Charge(ctx, "search.list"); err != nil {
+			return err
+		}
+		searchResp, err := searchCall.Do()
+		if err != nil {
+			return fmt.Errorf("error searching videos: %w", err)
+		}
+
+		stats.VideosFound += len(searchResp.Items)
+		slog.InfoContext(ctx, "Found videos in search results", "count", len(searchResp.Items))
+
+		// Collect Video IDs
+		for _, item := range searchResp.Items {
+			videoIDs = append(videoIDs, item.Id.VideoId)
+			seen[item.Id.VideoId] = true
+		}
+	}
+
+	// Revisit streams and premieres that were not ready on earlier runs
+	pendingIDs, err := listPendingVideos(ctx, channelID)
+	if err != nil {
+		return err
+	}
+	pending := map[string]bool{}
+	for _, id := range pendingIDs {
+		pending[id] = true
+		if !seen[id] {
+			videoIDs = append(videoIDs, id)
+			seen[id] = true
+		}
+	}
+	if len(pendingIDs) > 0 {
+		slog.InfoContext(ctx, "Revisiting pending live videos", "count", len(pendingIDs))
+	}
+
+	// Videos submitted through the API
+	var jobs []*store.Job
+	if first {
+		if jobs, err = listQueuedJobs(ctx); err != nil {
+			return err
+		}
+	}
+	jobOnly := map[string]*store.Job{} // jobs for videos not found otherwise
+	for _, job := range jobs {
+		if !seen[job.VideoID] {
+			videoIDs = append(videoIDs, job.VideoID)
+			seen[job.VideoID] = true
+			jobOnly[job.VideoID] = job
+		}
+	}
+	if len(jobs) > 0 {
+		slog.InfoContext(ctx, "Processing queued jobs", "count", len(jobs))
 	}
 
-	// 2. Get Video Details (Stats, ContentDetails)
+	if len(videoIDs) == 0 {
+		return nil
+	}
+
+	// 2. Get Video Details (Stats, ContentDetails, LiveStreamingDetails)
 	// Search API doesn't return viewCount or likeCount, so we need Videos.List
-	videosCall := ytService.Videos.List([]string{"snippet", "statistics", "contentDetails"}).
-		Id(strings.Join(videoIDs, ","))
-	
-	videosResp, err := videosCall.Do()
+	videos, err := fetchVideos(ctx, ytService, meter, videoIDs)
 	if err != nil {
-		return stats, fmt.Errorf("error fetching video details: %w", err)
+		return err
+	}
+
+	// Pending videos that no longer come back were deleted or made private
+	returned := map[string]*youtube.Video{}
+	for _, item := range videos {
+		returned[item.Id] = item
+	}
+	defer finishJobs(ctx, jobs, returned)
+	for _, id := range pendingIDs {
+		if returned[id] == nil {
+			ctx := logging.With(ctx, logging.KeyVideoID, id)
+			slog.InfoContext(ctx, "Pending video is no longer available. Dropping it.")
+			if err := clearPending(ctx, channelID, id); err != nil {
+				slog.ErrorContext(ctx, "Error clearing pending video", "error", err)
+			}
+		}
 	}
 
-	for _, item := range videosResp.Items {
+	// Shorts collected for this run's digest
+	var shortClips []shortClip
+
+	for _, item := range videos {
 		videoID := item.Id
 		title := item.Snippet.Title
-		log.Printf("Processing video: %s (%s)", title, videoID)
+
+		if job := jobOnly[videoID]; job != nil && wrongChannel(job, item) {
+			// Left for finishJobs to fail
+			slog.WarnContext(logging.With(ctx, logging.KeyVideoID, videoID), "Skipping job video of another channel", "channelId", item.Snippet.ChannelId, "expected", job.ChannelID)
+			continue
+		}
+
+		// Videos submitted as jobs may belong to other channels
+		channelID := channelID
+		if item.Snippet.ChannelId != "" {
+			channelID = item.Snippet.ChannelId
+		}
+		ctx := logging.With(ctx, logging.KeyChannelID, channelID, logging.KeyVideoID, videoID, logging.KeyStage, "details")
+		slog.InfoContext(ctx, "Processing video", "title", title)
 
 		// Create VideoDetails struct for saving later
 		videoDetails := VideoDetails{
 			ID:           videoID,
 			Title:        title,
 			PublishedAt:  item.Snippet.PublishedAt,
+			Description:  item.Snippet.Description,
 			ChannelTitle: item.Snippet.ChannelTitle,
 			ViewCount:    item.Statistics.ViewCount,
 			LikeCount:    item.Statistics.LikeCount,
@@ -348,57 +793,125 @@ func handler(ctx context.Context) (BatchStats, error) {
 		if item.Snippet.Thumbnails != nil {
 			videoDetails.Thumbnails = item.Snippet.Thumbnails
 		}
+		applyLiveDetails(&videoDetails, item)
+		if item.ContentDetails != nil && item.ContentDetails.Duration != "" {
+			if d, err := parseISODuration(item.ContentDetails.Duration); err == nil {
+				videoDetails.DurationSeconds = d
+			} else {
+				slog.WarnContext(ctx, "Error parsing duration", "error", err)
+			}
+		}
+		videoDetails.IsShort = classifyShort(videoDetails, shortsMaxSeconds)
+		if videoDetails.IsShort {
+			stats.ShortsFound++
+		}
 
 		// Check if already processed
 		existingItem, err := getVideoItem(ctx, videoID)
 		if err != nil {
-			log.Printf("Error checking DB for %s: %v", videoID, err)
+			slog.ErrorContext(ctx, "Error checking DB", "error", err)
 			// Continue or fail? Continue trying to process seems safe.
 		}
 
 		if existingItem != nil {
-			// Check if detailSummary exists
-			if _, ok := existingItem["detailSummary"]; ok {
-				log.Printf("Video %s already has summary. Skipping.", videoID)
+			if v, ok := existingItem["processedAt"].(*types.AttributeValueMemberS); ok {
+				videoDetails.ProcessedAt = v.Value
+			}
+
+			if store.HasSummary(existingItem) {
+				slog.InfoContext(ctx, "Video already has summary. Skipping.")
 				stats.VideosAlreadyProcessed++
+				if pending[videoID] {
+					if err := clearPending(ctx, channelID, videoID); err != nil {
+						slog.ErrorContext(ctx, "Error clearing pending video", "error", err)
+					}
+				}
+				continue
+			}
+		}
+
+		// Every save during this run updates the same item
+		if videoDetails.ProcessedAt == "" {
+			videoDetails.ProcessedAt = time.Now().UTC().Format(time.RFC3339)
+		}
+
+		// Upcoming and in-progress streams have no transcript yet. Track them
+		// with their schedule and come back once the archive is available.
+		if videoDetails.LiveStatus == liveStatusUpcoming || videoDetails.LiveStatus == liveStatusLive {
+			slog.InfoContext(ctx, "Live video not ready. Tracking as pending.", "liveStatus", videoDetails.LiveStatus, "scheduledStartTime", videoDetails.ScheduledStartTime)
+			stats.VideosPendingLive++
+			if err := saveVideoData(ctx, channelID, videoDetails, nil, nil); err != nil {
+				slog.ErrorContext(ctx, "Error saving pending video", "error", err)
+				stats.Errors++
 				continue
 			}
+			if err := markPending(ctx, channelID, videoDetails, videoDetails.LiveStatus); err != nil {
+				slog.ErrorContext(ctx, "Error marking video pending", "error", err)
+				stats.Errors++
+			}
+			continue
+		}
+
+		if videoDetails.IsShort && shortsMode == shortsModeSkip {
+			slog.InfoContext(ctx, "Video is a Short. Skipping.", "durationSeconds", videoDetails.DurationSeconds)
+			videoDetails.ShortsHandling = shortsModeSkip
+			if err := saveVideoData(ctx, channelID, videoDetails, nil, nil); err != nil {
+				slog.ErrorContext(ctx, "Error saving Short", "error", err)
+				stats.Errors++
+			}
+			stats.ShortsSkipped++
+			continue
 		}
 
 		// Retrieve or Fetch Transcript
-		var transcript string
+		ctx = logging.Stage(ctx, "transcript")
+		var segments []transcript.Segment
 		// Check DB first
 		if existingItem != nil {
-			if t, ok := existingItem["transcript"]; ok {
-				transcript = t.(*types.AttributeValueMemberS).Value
-				log.Printf("Found existing transcript for %s", videoID)
+			segments, err = store.TranscriptSegments(ctx, blobStore, existingItem)
+			if err != nil {
+				slog.ErrorContext(ctx, "Error reading stored transcript", "error", err)
+			} else if len(segments) > 0 {
+				slog.InfoContext(ctx, "Found existing transcript")
 			}
 		}
 
 		// If no transcript, and LOCAL_RUN, fetch it
-		if transcript == "" {
-			if os.Getenv("LOCAL_RUN") == "true" {
+		if len(segments) == 0 {
+			if cfg.LocalRun {
 				// Check filters (optional)
 				vc := item.Statistics.ViewCount
 				if vc < 100 {
-					log.Printf("Notice: Video %s has low views (%d)", videoID, vc)
+					slog.InfoContext(ctx, "Video has low views", "viewCount", vc)
 				}
 
-				log.Printf("Fetching transcript for %s...", videoID)
+				slog.InfoContext(ctx, "Fetching transcript...")
 				fetchedTx, err := getTranscript(videoID)
 				if err != nil {
-					log.Printf("No transcript found for %s: %v", videoID, err)
+					slog.WarnContext(ctx, "No transcript found", "error", err)
 					stats.VideosWithoutTx++
+
+					// Captions of a stream archive appear some time after it ends
+					if videoDetails.ActualEndTime != "" {
+						videoDetails.LiveStatus = liveStatusAwaitingCaptions
+						if err := saveVideoData(ctx, channelID, videoDetails, nil, nil); err != nil {
+							slog.ErrorContext(ctx, "Error saving pending video", "error", err)
+						}
+						if err := markPending(ctx, channelID, videoDetails, liveStatusAwaitingCaptions); err != nil {
+							slog.ErrorContext(ctx, "Error marking video pending", "error", err)
+						}
+						stats.VideosPendingLive++
+					}
 					continue
 				}
-				transcript = fetchedTx
+				segments = fetchedTx
 
 				// Save transcript immediately to avoid re-fetching
-				if err := saveVideoData(ctx, channelID, videoDetails, transcript, nil); err != nil {
-					log.Printf("Error saving transcript for %s: %v", videoID, err)
+				if err := saveVideoData(ctx, channelID, videoDetails, segments, nil); err != nil {
+					slog.ErrorContext(ctx, "Error saving transcript", "error", err)
 					// Proceed anyway to try summarizing?
 				} else {
-					log.Printf("Saved transcript for %s", videoID)
+					slog.InfoContext(ctx, "Saved transcript")
 				}
 
 				// Add delay to avoid YouTube rate limiting locally
@@ -406,41 +919,120 @@ func handler(ctx context.Context) (BatchStats, error) {
 
 			} else {
 				// AWS Lambda mode but no transcript in DB
-				log.Printf("Skipping video %s: No transcript in DB and not running locally", videoID)
+				slog.InfoContext(ctx, "Skipping video: No transcript in DB and not running locally")
 				continue
 			}
 		}
 
 		// At this point we have a transcript (or we continued).
+		if videoDetails.IsShort && shortsMode == shortsModeDigest {
+			shortClips = append(shortClips, shortClip{video: videoDetails, segments: segments})
+			continue
+		}
+
+		if budgetReached() {
+			if !stats.SummarizationPaused {
+				slog.WarnContext(ctx, "Monthly LLM budget reached. Pausing summarization.", "budgetUsd", monthlyCeiling)
+				stats.SummarizationPaused = true
+			}
+			continue
+		}
+
 		// Generate summary with Bedrock
-		log.Printf("Generating summary for %s...", videoID)
-		summaryData, err := generateSummary(ctx, transcript, title)
+		ctx = logging.Stage(ctx, "summarize")
+		slog.InfoContext(ctx, "Generating summary...")
+		summaryData, err := generateSummary(ctx, segments, videoDetails)
 		if err != nil {
-			log.Printf("Error summarizing %s: %v", videoID, err)
+			slog.ErrorContext(ctx, "Error summarizing", "error", err)
 			stats.Errors++
 			continue
 		}
+		stats.InputTokens += summaryData.Usage.InputTokens
+		stats.OutputTokens += summaryData.Usage.OutputTokens
+		stats.CostUSD += summaryData.CostUSD
+		if err := ledger.Record(ctx, channelID, summaryData.Usage, summaryData.CostUSD); err != nil {
+			slog.ErrorContext(ctx, "Error recording LLM usage", "error", err)
+		}
+
+		// Translations are best effort; a missing one is made on request
+		ctx = logging.Stage(ctx, "translate")
+		summaryData.Translations = map[string]*i18n.Translation{}
+		for _, lang := range summaryLanguages {
+			if budgetReached() {
+				break
+			}
+			t, err := i18n.Translate(ctx, llmClient, lang, summaryData.ShortSummary, summaryData.DetailSummary)
+			if err != nil {
+				slog.ErrorContext(ctx, "Error translating summary", "language", lang, "error", err)
+				stats.Errors++
+				continue
+			}
+			stats.InputTokens += t.Usage.InputTokens
+			stats.OutputTokens += t.Usage.OutputTokens
+			stats.CostUSD += t.CostUSD
+			if err := ledger.Record(ctx, channelID, t.Usage, t.CostUSD); err != nil {
+				slog.ErrorContext(ctx, "Error recording LLM usage", "error", err)
+			}
+			summaryData.Translations[lang] = t
+		}
 
 		// Save processing result (Summary + Transcript + Metadata)
-		if err := saveVideoData(ctx, channelID, videoDetails, transcript, summaryData); err != nil {
-			log.Printf("Error saving summary for %s: %v", videoID, err)
+		ctx = logging.Stage(ctx, "save")
+		if err := saveVideoData(ctx, channelID, videoDetails, segments, summaryData); err != nil {
+			slog.ErrorContext(ctx, "Error saving summary", "error", err)
 			stats.Errors++
 		} else {
-			log.Printf("Successfully processed video %s", videoID)
+			slog.InfoContext(ctx, "Successfully processed video", "costUsd", summaryData.CostUSD)
 			stats.VideosSummarized++
+			notifySummary(logging.Stage(ctx, "notify"), channelID, videoDetails, summaryData, stats)
+			emailSummary(logging.Stage(ctx, "email"), channelID, videoDetails, summaryData, stats)
+			if commentSummaryEnabled && !videoDetails.IsShort && !budgetReached() {
+				if err := summarizeComments(logging.Stage(ctx, "comments"), ytService, meter, ledger, channelID, storedVideoOf(channelID, videoDetails), stats); err != nil {
+					slog.ErrorContext(ctx, "Error summarizing comments", "error", err)
+					if errors.Is(err, quota.ErrBudgetExceeded) {
+						commentSummaryEnabled = false
+					}
+				}
+			}
+			if pending[videoID] {
+				if err := clearPending(ctx, channelID, videoID); err != nil {
+					slog.ErrorContext(ctx, "Error clearing pending video", "error", err)
+				}
+			}
+		}
+	}
+
+	if len(shortClips) > 0 {
+		ctx = logging.Stage(ctx, "shorts-digest")
+		if budgetReached() {
+			slog.WarnContext(ctx, "Monthly LLM budget reached. Not generating the Shorts digest.", "budgetUsd", monthlyCeiling)
+			stats.SummarizationPaused = true
+		} else if err := digestShorts(ctx, channelID, shortClips, ledger, stats); err != nil {
+			slog.ErrorContext(ctx, "Error generating Shorts digest", "error", err)
+			stats.Errors++
 		}
 	}
-	return stats, nil
+	return nil
 }
 
 func main() {
-	if os.Getenv("LOCAL_RUN") == "true" {
-		log.Println("Running in local mode...")
-		stats, err := handler(context.Background())
-		if err != nil {
+	var args []string
+	cfg, args = config.MustLoad()
+	if len(args) == 2 && args[0] == "config" && args[1] == "print" {
+		if err := cfg.Print(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	level, _ := logging.ParseLevel(cfg.LogLevel)
+	logging.Setup(os.Stdout, level)
+	setup()
+
+	if cfg.LocalRun {
+		slog.Info("Running in local mode...")
+		if _, err := handler(context.Background(), BatchEvent{Job: cfg.BatchJob, ChannelID: cfg.BatchChannelID}); err != nil {
 			log.Fatalf("Local execution failed: %v", err)
 		}
-		log.Printf("Local execution finished. Stats: %+v", stats)
 	} else {
 		lambda.Start(handler)
 	}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/ttakahashi/youtube-summary/internal/notify"
+)
+
+// loadNotifier returns the notifier for the targets in NOTIFY_TARGETS, or in
+// the secret named by NOTIFY_TARGETS_SECRET since webhook URLs are
+// credentials. It returns nil when neither is set.
+func loadNotifier(ctx context.Context) (*notify.Notifier, error) {
+	config := cfg.NotifyTargets
+	if name := cfg.NotifyTargetsSecret; name != "" {
+		secret, err := secretStore.Get(ctx, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get notification targets: %w", err)
+		}
+		config = secret
+	}
+	if config == "" {
+		return nil, nil
+	}
+	targets, err := notify.ParseTargets(config)
+	if err != nil {
+		return nil, err
+	}
+	slog.InfoContext(ctx, "Notifying targets of new summaries", "targets", len(targets))
+	return notify.New(targets, notify.NewDynamoDeduper(dynamoClient, tableName)), nil
+}
+
+// notifySummary announces a saved summary. Failures are counted but do not
+// fail the run; deliveries already made are not repeated on a later run.
+func notifySummary(ctx context.Context, channelID string, video VideoDetails, summary *SummaryData, stats *BatchStats) {
+	if notifier == nil {
+		return
+	}
+	event := notify.Event{
+		Type:         notify.EventSummaryCreated,
+		VideoID:      video.ID,
+		ChannelID:    channelID,
+		ChannelTitle: video.ChannelTitle,
+		Title:        video.Title,
+		Summary:      summary.ShortSummary,
+		URL:          "https://www.youtube.com/watch?v=" + video.ID,
+		PublishedAt:  video.PublishedAt,
+	}
+	if video.Thumbnails != nil && video.Thumbnails.Medium != nil {
+		event.ThumbnailURL = video.Thumbnails.Medium.Url
+	}
+	if err := notifier.Notify(ctx, event); err != nil {
+		stats.NotificationsFailed++
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/ttakahashi/youtube-summary/internal/logging"
+	"github.com/ttakahashi/youtube-summary/internal/quota"
+	"google.golang.org/api/youtube/v3"
+)
+
+// Availability values stored in the "availability" attribute. Videos.List
+// does not return deleted videos, nor private ones to an API key, so the two
+// cannot be told apart and are both recorded as unavailable.
+const (
+	availabilityUnavailable   = "unavailable"    // deleted or private
+	availabilityPrivate       = "private"        // returned, but no longer public
+	availabilityRegionBlocked = "region_blocked" // blocked in the audience region
+)
+
+// videoAvailability tells why item cannot be watched in region, or returns
+// "" if it can. A nil item was not returned by Videos.List.
+func videoAvailability(item *youtube.Video, region string) string {
+	if item == nil {
+		return availabilityUnavailable
+	}
+	if item.Status != nil && item.Status.PrivacyStatus == "private" {
+		return availabilityPrivate
+	}
+	if item.ContentDetails != nil && item.ContentDetails.RegionRestriction != nil {
+		r := item.ContentDetails.RegionRestriction
+		if slices.Contains(r.Blocked, region) {
+			return availabilityRegionBlocked
+		}
+		if len(r.Allowed) > 0 && !slices.Contains(r.Allowed, region) {
+			return availabilityRegionBlocked
+		}
+	}
+	return ""
+}
+
+// reconcile checks every stored video of channelID against Videos.List,
+// 50 IDs per call, and records the ones that can no longer be watched with
+// the reason and when it was first seen. Videos that are watchable again
+// have the marks removed.
+func reconcile(ctx context.Context, ytService *youtube.Service, meter *quota.Meter, channelID string, stats *BatchStats) error {
+	region := cfg.AudienceRegion
+
+	videos, err := listStoredVideos(ctx, channelID, "")
+	if err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "Reconciling stored videos", "videos", len(videos), "region", region)
+
+	for start := 0; start < len(videos); start += 50 {
+		batch := videos[start:min(start+50, len(videos))]
+		ids := make([]string, len(batch))
+		for i, v := range batch {
+			ids[i] = v.VideoID
+		}
+
+		if err := meter.Charge(ctx, "videos.list"); err != nil {
+			return err
+		}
+		resp, err := ytService.Videos.List([]string{"status", "contentDetails"}).Id(strings.Join(ids, ",")).Do()
+		if err != nil {
+			return fmt.Errorf("error fetching video status: %w", err)
+		}
+		returned := map[string]*youtube.Video{}
+		for _, item := range resp.Items {
+			returned[item.Id] = item
+		}
+
+		for _, stored := range batch {
+			availability := videoAvailability(returned[stored.VideoID], region)
+			if availability == stored.Availability {
+				continue
+			}
+			ctx := logging.With(ctx, logging.KeyVideoID, stored.VideoID)
+			if err := setAvailability(ctx, stored, availability); err != nil {
+				slog.ErrorContext(ctx, "Error reconciling video", "error", err)
+				stats.Errors++
+				continue
+			}
+			if availability == "" {
+				slog.InfoContext(ctx, "Video is available again")
+				stats.VideosRestored++
+			} else {
+				slog.InfoContext(ctx, "Video is unavailable", "availability", availability)
+				stats.VideosUnavailable++
+			}
+		}
+	}
+	return nil
+}
+
+// setAvailability records availability on the stored item, or clears it
+// when availability is empty.
+func setAvailability(ctx context.Context, stored storedVideo, availability string) error {
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(tableName),
+		Key:       stored.Key,
+		// Do not recreate items deleted since they were listed
+		ConditionExpression: aws.String("attribute_exists(videoId)"),
+	}
+	if availability == "" {
+		input.UpdateExpression = aws.String("REMOVE availability, unavailableSince")
+	} else {
+		// Keep the time it first became unavailable when only the reason changes
+		input.UpdateExpression = aws.String("SET availability = :a, unavailableSince = if_not_exists(unavailableSince, :now)")
+		input.ExpressionAttributeValues = map[string]types.AttributeValue{
+			":a":   &types.AttributeValueMemberS{Value: availability},
+			":now": &types.AttributeValueMemberS{Value: time.Now().UTC().Format(time.RFC3339)},
+		}
+	}
+
+	if _, err := dynamoClient.UpdateItem(ctx, input); err != nil {
+		return fmt.Errorf("failed to update availability: %w", err)
+	}
+	return nil
+}
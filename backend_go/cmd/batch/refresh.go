@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/ttakahashi/youtube-summary/internal/logging"
+	"github.com/ttakahashi/youtube-summary/internal/quota"
+	"google.golang.org/api/youtube/v3"
+)
+
+// storedVideo is the part of a stored item the maintenance jobs need.
+type storedVideo struct {
+	Key          map[string]types.AttributeValue
+	VideoID      string
+	Title        string
+	PublishedAt  string
+	ViewCount    uint64
+	StatsAt      string // when ViewCount was read: statsUpdatedAt, or processedAt
+	Availability string // recorded by the reconcile job; empty while available
+	CommentsAt   string // when comments were last summarized
+}
+
+// storedVideoOf returns the stored form of a video saved in this run.
+func storedVideoOf(channelID string, video VideoDetails) storedVideo {
+	return storedVideo{
+		Key: map[string]types.AttributeValue{
+			"hashtag":     &types.AttributeValueMemberS{Value: channelID},
+			"processedAt": &types.AttributeValueMemberS{Value: video.ProcessedAt},
+		},
+		VideoID:     video.ID,
+		Title:       video.Title,
+		PublishedAt: video.PublishedAt,
+		ViewCount:   video.ViewCount,
+	}
+}
+
+// listStoredVideos returns channelID's stored videos published at or after
+// since (RFC 3339); an empty since returns all of them.
+func listStoredVideos(ctx context.Context, channelID, since string) ([]storedVideo, error) {
+	var videos []storedVideo
+	var lastEvaluatedKey map[string]types.AttributeValue
+
+	for {
+		input := &dynamodb.QueryInput{
+			TableName:              aws.String(tableName),
+			KeyConditionExpression: aws.String("hashtag = :h"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":h": &types.AttributeValueMemberS{Value: channelID},
+			},
+			ProjectionExpression: aws.String("hashtag, processedAt, videoId, title, publishedAt, viewCount, statsUpdatedAt, availability, commentSummaryAt"),
+			ExclusiveStartKey:    lastEvaluatedKey,
+		}
+		if since != "" {
+			input.FilterExpression = aws.String("publishedAt >= :since")
+			input.ExpressionAttributeValues[":since"] = &types.AttributeValueMemberS{Value: since}
+		}
+
+		resp, err := dynamoClient.Query(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list stored videos: %w", err)
+		}
+
+		for _, item := range resp.Items {
+			v := storedVideo{
+				Key: map[string]types.AttributeValue{
+					"hashtag":     item["hashtag"],
+					"processedAt": item["processedAt"],
+				},
+			}
+			if s, ok := item["videoId"].(*types.AttributeValueMemberS); ok {
+				v.VideoID = s.Value
+			}
+			if s, ok := item["title"].(*types.AttributeValueMemberS); ok {
+				v.Title = s.Value
+			}
+			if s, ok := item["publishedAt"].(*types.AttributeValueMemberS); ok {
+				v.PublishedAt = s.Value
+			}
+			if n, ok := item["viewCount"].(*types.AttributeValueMemberN); ok {
+				v.ViewCount, _ = strconv.ParseUint(n.Value, 10, 64)
+			}
+			if s, ok := item["statsUpdatedAt"].(*types.AttributeValueMemberS); ok {
+				v.StatsAt = s.Value
+			} else if s, ok := item["processedAt"].(*types.AttributeValueMemberS); ok {
+				v.StatsAt = s.Value
+			}
+			if s, ok := item["availability"].(*types.AttributeValueMemberS); ok {
+				v.Availability = s.Value
+			}
+			if s, ok := item["commentSummaryAt"].(*types.AttributeValueMemberS); ok {
+				v.CommentsAt = s.Value
+			}
+			if v.VideoID != "" {
+				videos = append(videos, v)
+			}
+		}
+
+		if resp.LastEvaluatedKey == nil {
+			return videos, nil
+		}
+		lastEvaluatedKey = resp.LastEvaluatedKey
+	}
+}
+
+// refreshStats re-reads the statistics of channelID's recent videos, 50 IDs
+// per Videos.List call. Each reading is appended to the video's time series
+// under "stats#<videoID>", and the item gets the new counts together with
+// its view growth rate for the "trending" sort.
+func refreshStats(ctx context.Context, ytService *youtube.Service, meter *quota.Meter, channelID string, stats *BatchStats) error {
+	since := time.Now().UTC().AddDate(0, 0, -cfg.StatsRefreshMaxAge).Format(time.RFC3339)
+
+	videos, err := listStoredVideos(ctx, channelID, since)
+	if err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "Refreshing statistics", "videos", len(videos), "since", since)
+
+	for start := 0; start < len(videos); start += 50 {
+		batch := videos[start:min(start+50, len(videos))]
+		ids := make([]string, len(batch))
+		byID := map[string]storedVideo{}
+		for i, v := range batch {
+			ids[i] = v.VideoID
+			byID[v.VideoID] = v
+		}
+
+		if err := meter.Charge(ctx, "videos.list"); err != nil {
+			return err
+		}
+		resp, err := ytService.Videos.List([]string{"statistics"}).Id(strings.Join(ids, ",")).Do()
+		if err != nil {
+			return fmt.Errorf("error fetching video statistics: %w", err)
+		}
+
+		now := time.Now().UTC()
+		for _, item := range resp.Items {
+			stored, ok := byID[item.Id]
+			if !ok || item.Statistics == nil {
+				continue
+			}
+			if err := recordStats(ctx, stored, item.Statistics, now); err != nil {
+				slog.ErrorContext(logging.With(ctx, logging.KeyVideoID, item.Id), "Error refreshing statistics", "error", err)
+				stats.Errors++
+				continue
+			}
+			stats.StatsRefreshed++
+		}
+	}
+	return nil
+}
+
+// recordStats appends a snapshot and updates the item's counts and growth.
+func recordStats(ctx context.Context, stored storedVideo, s *youtube.VideoStatistics, now time.Time) error {
+	at := now.Format(time.RFC3339)
+
+	_, err := dynamoClient.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(tableName),
+		Item: map[string]types.AttributeValue{
+			"hashtag":      &types.AttributeValueMemberS{Value: "stats#" + stored.VideoID},
+			"processedAt":  &types.AttributeValueMemberS{Value: at},
+			"viewCount":    &types.AttributeValueMemberN{Value: strconv.FormatUint(s.ViewCount, 10)},
+			"likeCount":    &types.AttributeValueMemberN{Value: strconv.FormatUint(s.LikeCount, 10)},
+			"commentCount": &types.AttributeValueMemberN{Value: strconv.FormatUint(s.CommentCount, 10)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save snapshot: %w", err)
+	}
+
+	// Views gained per hour since the previous reading
+	growth := 0.0
+	if prev, err := time.Parse(time.RFC3339, stored.StatsAt); err == nil {
+		if hours := now.Sub(prev).Hours(); hours > 0 && s.ViewCount >= stored.ViewCount {
+			growth = float64(s.ViewCount-stored.ViewCount) / hours
+		}
+	}
+
+	_, err = dynamoClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:        aws.String(tableName),
+		Key:              stored.Key,
+		UpdateExpression: aws.String("SET viewCount = :v, likeCount = :l, statsUpdatedAt = :at, viewGrowthPerHour = :g"),
+		// Do not recreate items deleted since they were listed
+		ConditionExpression: aws.String("attribute_exists(videoId)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":v":  &types.AttributeValueMemberN{Value: strconv.FormatUint(s.ViewCount, 10)},
+			":l":  &types.AttributeValueMemberN{Value: strconv.FormatUint(s.LikeCount, 10)},
+			":at": &types.AttributeValueMemberS{Value: at},
+			":g":  &types.AttributeValueMemberN{Value: strconv.FormatFloat(growth, 'f', 2, 64)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update item: %w", err)
+	}
+	return nil
+}
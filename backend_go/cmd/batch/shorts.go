@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/ttakahashi/youtube-summary/internal/llm"
+	"github.com/ttakahashi/youtube-summary/internal/logging"
+	"github.com/ttakahashi/youtube-summary/internal/transcript"
+)
+
+// How Shorts are handled, set with SHORTS_MODE.
+const (
+	shortsModeSummarize = "summarize" // same as any other video
+	shortsModeSkip      = "skip"      // store metadata only
+	shortsModeDigest    = "digest"    // one combined summary per run
+	shortsModeBrief     = "brief"     // shorter prompt and summaries
+)
+
+var isoDuration = regexp.MustCompile(`^P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+
+// parseISODuration converts a contentDetails duration such as "PT1H2M3S"
+// to seconds.
+func parseISODuration(d string) (int64, error) {
+	m := isoDuration.FindStringSubmatch(d)
+	if m == nil || d == "P" || d == "PT" {
+		return 0, fmt.Errorf("invalid ISO 8601 duration %q", d)
+	}
+	var seconds float64
+	for i, unit := range []float64{86400, 3600, 60, 1} {
+		if m[i+1] == "" {
+			continue
+		}
+		n, err := strconv.ParseFloat(m[i+1], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid ISO 8601 duration %q", d)
+		}
+		seconds += n * unit
+	}
+	return int64(seconds), nil
+}
+
+// classifyShort reports whether video is a Short: a non-live video no
+// longer than maxSeconds, or, when the duration is unknown, one its creator
+// tagged #shorts.
+func classifyShort(video VideoDetails, maxSeconds int64) bool {
+	if video.LiveStatus != "" || video.ActualStartTime != "" {
+		return false
+	}
+	if video.DurationSeconds > 0 {
+		return video.DurationSeconds <= maxSeconds
+	}
+	return strings.Contains(strings.ToLower(video.Title+"\n"+video.Description), "#shorts")
+}
+
+// shortClip is a Short waiting to be summarized in the run's digest.
+type shortClip struct {
+	video    VideoDetails
+	segments []transcript.Segment
+}
+
+// ShortsDigest is one summary covering all Shorts found in a run.
+type ShortsDigest struct {
+	Summary string `json:"summary"`
+	Items   []struct {
+		VideoID string `json:"video_id"`
+		Summary string `json:"summary"`
+	} `json:"items"`
+
+	Usage   llm.Usage `json:"-"`
+	CostUSD float64   `json:"-"`
+}
+
+// generateShortsDigest summarizes clips together in a single LLM call.
+func generateShortsDigest(ctx context.Context, clips []shortClip) (*ShortsDigest, error) {
+	var b strings.Builder
+	for _, c := range clips {
+		text := transcript.PlainText(c.segments)
+		if len(text) > 2000 {
+			text = text[:2000]
+		}
+		fmt.Fprintf(&b, "## video_id: %s\nタイトル: %s\n%s\n\n", c.video.ID, c.video.Title, text)
+	}
+
+	prompt := fmt.Sprintf(`以下はYouTubeチャンネルに投稿された複数のショート動画の字幕です。以下をJSON形式で出力してください。
+
+1. summary: ショート動画全体に共通するテーマや見どころをまとめた400文字程度の要約
+2. items: 各ショート動画について、video_id と100文字程度の一言要約 (summary)
+
+%s
+出力形式（必ずこのJSONフォーマットのみを出力してください）:
+{
+  "summary": "...",
+  "items": [
+    {"video_id": "...", "summary": "..."}
+  ]
+}`, b.String())
+
+	resp, err := llmClient.Invoke(ctx, prompt, 4096)
+	if err != nil {
+		return nil, err
+	}
+
+	responseText := llm.StripCodeFence(resp.Text)
+	var digest ShortsDigest
+	if err := json.Unmarshal([]byte(responseText), &digest); err != nil {
+		return nil, fmt.Errorf("failed to parse shorts digest json: %w. Response: %s", err, responseText)
+	}
+	digest.Usage = resp.Usage
+	digest.CostUSD = resp.CostUSD
+	return &digest, nil
+}
+
+// saveShortsDigest stores the digest under "shorts-digest#<channelID>" and
+// returns its sort key.
+func saveShortsDigest(ctx context.Context, channelID string, clips []shortClip, digest *ShortsDigest) (string, error) {
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	ids := make([]types.AttributeValue, len(clips))
+	for i, c := range clips {
+		ids[i] = &types.AttributeValueMemberS{Value: c.video.ID}
+	}
+	items := make([]types.AttributeValue, 0, len(digest.Items))
+	for _, it := range digest.Items {
+		items = append(items, &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+			"videoId": &types.AttributeValueMemberS{Value: it.VideoID},
+			"summary": &types.AttributeValueMemberS{Value: it.Summary},
+		}})
+	}
+
+	_, err := dynamoClient.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(tableName),
+		Item: map[string]types.AttributeValue{
+			"hashtag":     &types.AttributeValueMemberS{Value: "shorts-digest#" + channelID},
+			"processedAt": &types.AttributeValueMemberS{Value: now},
+			"summary":     &types.AttributeValueMemberS{Value: digest.Summary},
+			"videoIds":    &types.AttributeValueMemberL{Value: ids},
+			"items":       &types.AttributeValueMemberL{Value: items},
+			"costUsd":     &types.AttributeValueMemberN{Value: strconv.FormatFloat(digest.CostUSD, 'f', -1, 64)},
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to save shorts digest: %w", err)
+	}
+	return now, nil
+}
+
+// digestShorts summarizes clips in one digest and marks each Short with its
+// one-line summary and the digest it belongs to.
+func digestShorts(ctx context.Context, channelID string, clips []shortClip, ledger *llm.Ledger, stats *BatchStats) error {
+	slog.InfoContext(ctx, "Generating digest for Shorts...", "count", len(clips))
+	digest, err := generateShortsDigest(ctx, clips)
+	if err != nil {
+		return err
+	}
+	stats.InputTokens += digest.Usage.InputTokens
+	stats.OutputTokens += digest.Usage.OutputTokens
+	stats.CostUSD += digest.CostUSD
+	if err := ledger.Record(ctx, channelID, digest.Usage, digest.CostUSD); err != nil {
+		slog.ErrorContext(ctx, "Error recording LLM usage for Shorts digest", "error", err)
+	}
+
+	digestAt, err := saveShortsDigest(ctx, channelID, clips, digest)
+	if err != nil {
+		return err
+	}
+
+	itemSummaries := map[string]string{}
+	for _, it := range digest.Items {
+		itemSummaries[it.VideoID] = it.Summary
+	}
+	for _, c := range clips {
+		c.video.ShortsHandling = shortsModeDigest
+		c.video.ShortsDigestAt = digestAt
+		summary := &SummaryData{ShortSummary: itemSummaries[c.video.ID]}
+		if err := saveVideoData(ctx, channelID, c.video, c.segments, summary); err != nil {
+			slog.ErrorContext(logging.With(ctx, logging.KeyVideoID, c.video.ID), "Error saving Short", "error", err)
+			stats.Errors++
+			continue
+		}
+		stats.ShortsDigested++
+	}
+	return nil
+}
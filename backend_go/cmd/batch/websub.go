@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/ttakahashi/youtube-summary/internal/store"
+	"github.com/ttakahashi/youtube-summary/internal/websub"
+)
+
+// renewWebSub subscribes to channelID's uploads feed at the API callback in
+// WEBSUB_CALLBACK_URL when there is no active subscription or the current
+// one expires soon. It does nothing without WEBSUB_CALLBACK_URL.
+func renewWebSub(ctx context.Context, channelID string) error {
+	callback := cfg.WebSubCallbackURL
+	if callback == "" {
+		return nil
+	}
+	renewBefore := time.Duration(cfg.WebSubRenewBeforeHours) * time.Hour
+
+	sub, err := store.GetWebSubSubscription(ctx, dynamoClient, tableName, channelID)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	if sub != nil && sub.Status == store.WebSubActive {
+		expires, err := time.Parse(time.RFC3339, sub.ExpiresAt)
+		if err == nil && expires.Sub(now) > renewBefore {
+			return nil
+		}
+	}
+	if sub != nil && sub.Status == store.WebSubPending {
+		// The hub verifies within seconds; retry requests left unverified
+		requested, err := time.Parse(time.RFC3339, sub.RequestedAt)
+		if err == nil && now.Sub(requested) < time.Hour {
+			return nil
+		}
+	}
+
+	secret := cfg.WebSubSecret
+	if name := cfg.WebSubSecretName; name != "" {
+		if secret, err = secretStore.Get(ctx, name); err != nil {
+			return fmt.Errorf("failed to get WebSub secret: %w", err)
+		}
+	}
+	if secret == "" {
+		// The API ignores unsigned pushes
+		return fmt.Errorf("a WebSub secret is required to subscribe")
+	}
+
+	if sub == nil {
+		sub = &store.WebSubSubscription{ChannelID: channelID}
+	}
+	sub.Topic = websub.Topic(channelID)
+	if sub.Status != store.WebSubActive {
+		// An active subscription stays active until the renewal is verified
+		sub.Status = store.WebSubPending
+	}
+	sub.RequestedAt = now.Format(time.RFC3339)
+	// Saved first, so that the callback finds the request when the hub
+	// verifies it
+	if err := store.SaveWebSubSubscription(ctx, dynamoClient, tableName, sub); err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	if err := websub.Subscribe(ctx, client, callback, sub.Topic, secret, websub.DefaultLease); err != nil {
+		return fmt.Errorf("failed to renew WebSub subscription: %w", err)
+	}
+	slog.InfoContext(ctx, "Requested WebSub subscription")
+	return nil
+}
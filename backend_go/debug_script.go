@@ -8,35 +8,24 @@ import (
 	"net/http"
 	"strings"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/ttakahashi/youtube-summary/internal/config"
+	"github.com/ttakahashi/youtube-summary/internal/secrets"
 	"google.golang.org/api/option"
 	"google.golang.org/api/youtube/v3"
 )
 
-var secretsClient *secretsmanager.Client
+var (
+	cfg         *config.Config
+	secretStore secrets.Provider
+)
 
 func init() {
-	cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion("ap-northeast-1"))
-	if err != nil {
-		log.Fatalf("unable to load SDK config, %v", err)
-	}
-	secretsClient = secretsmanager.NewFromConfig(cfg)
-}
-
-func getSecret(ctx context.Context, secretName string) (string, error) {
-	input := &secretsmanager.GetSecretValueInput{
-		SecretId: aws.String(secretName),
-	}
-	result, err := secretsClient.GetSecretValue(ctx, input)
+	cfg, _ = config.MustLoad()
+	var err error
+	secretStore, err = secrets.Open(context.TODO(), cfg.SecretsBackend, cfg.Region, 0)
 	if err != nil {
-		return "", err
-	}
-	if result.SecretString != nil {
-		return *result.SecretString, nil
+		log.Fatalf("unable to open secrets backend, %v", err)
 	}
-	return "", fmt.Errorf("secret string is empty")
 }
 
 func getTranscriptDebug(videoID string) (string, error) {
@@ -53,7 +42,7 @@ func getTranscriptDebug(videoID string) (string, error) {
 	}
 	body := string(bodyBytes)
 
-    // DEBUG: Check for captionTracks existence
+    // Check for captionTracks existence
 	if !strings.Contains(body, "captionTracks") {
         // Output a snippet of the body to see what's going on (avoid too much log)
         // Check if it's "Sign in" page or something
@@ -83,11 +72,10 @@ func getTranscriptDebug(videoID string) (string, error) {
 
 func main() {
 	ctx := context.Background()
-	channelID := "UC2kM01yXNnouBsJJ0ghyfMg" // @noiehoie
+	channelID := cfg.ChannelID
 
 	// Get Secrets
-	ytSecret := "youtube-summary/youtube-api-key"
-	ytKey, err := getSecret(ctx, ytSecret)
+	ytKey, err := secretStore.Get(ctx, cfg.YouTubeAPISecret)
 	if err != nil {
 		log.Fatalf("Error getting key: %v", err)
 	}
@@ -0,0 +1,115 @@
+// Package auth authenticates API clients by API key or JWT bearer token and
+// checks the scopes they were granted.
+package auth
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+)
+
+// Scopes.
+const (
+	ScopeRead  = "read"  // summaries, transcripts, digests and statistics
+	ScopeAsk   = "ask"   // questions answered by the model
+	ScopeJobs  = "jobs"  // on-demand summarization jobs
+	ScopeAdmin = "admin" // channel management and subscriptions; implies all others
+)
+
+// Scopes lists every scope.
+var Scopes = []string{ScopeRead, ScopeAsk, ScopeJobs, ScopeAdmin}
+
+// ParseScopes parses a comma- or space-separated list of scopes.
+func ParseScopes(s string) ([]string, error) {
+	var scopes []string
+	for _, f := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' }) {
+		if !slices.Contains(Scopes, f) {
+			return nil, errors.New("unknown scope " + f)
+		}
+		if !slices.Contains(scopes, f) {
+			scopes = append(scopes, f)
+		}
+	}
+	return scopes, nil
+}
+
+// Principal is an authenticated client.
+type Principal struct {
+	Kind    string // "api_key", "jwt" or "anonymous"
+	Subject string // key ID or token subject
+	Scopes  []string
+}
+
+// Has reports whether p was granted scope.
+func (p *Principal) Has(scope string) bool {
+	return slices.Contains(p.Scopes, scope) || slices.Contains(p.Scopes, ScopeAdmin)
+}
+
+var (
+	// ErrNoCredentials is returned when a request carries no credentials.
+	ErrNoCredentials = errors.New("no credentials")
+	// ErrInvalidCredentials is returned for unknown, revoked or expired
+	// keys and tokens, and for tokens with a bad signature.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// KeyStore looks up API keys by the hash of the key.
+type KeyStore interface {
+	LookupKey(ctx context.Context, hash string) (*APIKey, error)
+}
+
+// SecretFunc returns the shared secret of JWTs, which may have to be read
+// from a secrets backend.
+type SecretFunc func(ctx context.Context) (string, error)
+
+// StaticSecret returns a SecretFunc for a secret known up front.
+func StaticSecret(secret string) SecretFunc {
+	return func(ctx context.Context) (string, error) {
+		return secret, nil
+	}
+}
+
+// Authenticator accepts API keys, given in the X-API-Key header or as a
+// bearer token, and JWTs signed with the shared secret.
+type Authenticator struct {
+	keys      KeyStore
+	jwtSecret SecretFunc
+	issuer    string
+}
+
+// NewAuthenticator returns an authenticator that looks keys up in keys and
+// verifies JWTs with the secret from jwtSecret, which is only called for
+// requests presenting a JWT. A nil func or an empty secret disables JWTs; a
+// non-empty issuer is required to match the token's iss claim.
+func NewAuthenticator(keys KeyStore, jwtSecret SecretFunc, issuer string) *Authenticator {
+	return &Authenticator{keys: keys, jwtSecret: jwtSecret, issuer: issuer}
+}
+
+// Authenticate returns the client presenting the given Authorization and
+// X-API-Key header values.
+func (a *Authenticator) Authenticate(ctx context.Context, authorization, apiKey string) (*Principal, error) {
+	if apiKey == "" {
+		token, ok := strings.CutPrefix(authorization, "Bearer ")
+		if !ok {
+			if authorization != "" {
+				return nil, ErrInvalidCredentials
+			}
+			return nil, ErrNoCredentials
+		}
+		token = strings.TrimSpace(token)
+		if !IsAPIKey(token) {
+			return a.verifyJWT(ctx, token)
+		}
+		apiKey = token
+	}
+
+	key, err := a.keys.LookupKey(ctx, HashKey(apiKey))
+	if err != nil {
+		return nil, err
+	}
+	if key == nil || key.RevokedAt != "" {
+		return nil, ErrInvalidCredentials
+	}
+	return &Principal{Kind: "api_key", Subject: key.ID, Scopes: key.Scopes}, nil
+}
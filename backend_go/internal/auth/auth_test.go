@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestParseScopes(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []string
+		wantErr bool
+	}{
+		{in: "", want: nil},
+		{in: "read", want: []string{"read"}},
+		{in: "read,ask", want: []string{"read", "ask"}},
+		{in: "read ask, jobs", want: []string{"read", "ask", "jobs"}},
+		{in: "read,read", want: []string{"read"}},
+		{in: "read,write", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseScopes(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseScopes(%q) = %v, want an error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil || !slices.Equal(got, tt.want) {
+			t.Errorf("ParseScopes(%q) = %v, %v; want %v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestPrincipalHas(t *testing.T) {
+	tests := []struct {
+		scopes []string
+		scope  string
+		want   bool
+	}{
+		{scopes: []string{ScopeRead}, scope: ScopeRead, want: true},
+		{scopes: []string{ScopeRead}, scope: ScopeAsk, want: false},
+		{scopes: []string{ScopeRead, ScopeJobs}, scope: ScopeJobs, want: true},
+		{scopes: []string{ScopeAdmin}, scope: ScopeAsk, want: true},
+		{scopes: nil, scope: ScopeRead, want: false},
+	}
+	for _, tt := range tests {
+		p := &Principal{Scopes: tt.scopes}
+		if got := p.Has(tt.scope); got != tt.want {
+			t.Errorf("Principal{Scopes: %v}.Has(%q) = %v, want %v", tt.scopes, tt.scope, got, tt.want)
+		}
+	}
+}
+
+// memoryKeys is a KeyStore of keys by hash.
+type memoryKeys map[string]*APIKey
+
+func (m memoryKeys) LookupKey(ctx context.Context, hash string) (*APIKey, error) {
+	return m[hash], nil
+}
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	keys := memoryKeys{
+		HashKey("ysk_active"):  {ID: "active", Scopes: []string{ScopeRead, ScopeAsk}},
+		HashKey("ysk_revoked"): {ID: "revoked", Scopes: []string{ScopeRead}, RevokedAt: "2025-01-01T00:00:00Z"},
+	}
+	a := NewAuthenticator(keys, nil, "")
+
+	tests := []struct {
+		name          string
+		authorization string
+		apiKey        string
+		wantSubject   string
+		wantErr       error
+	}{
+		{name: "X-API-Key", apiKey: "ysk_active", wantSubject: "active"},
+		{name: "bearer key", authorization: "Bearer ysk_active", wantSubject: "active"},
+		{name: "unknown key", apiKey: "ysk_unknown", wantErr: ErrInvalidCredentials},
+		{name: "revoked key", apiKey: "ysk_revoked", wantErr: ErrInvalidCredentials},
+		{name: "no credentials", wantErr: ErrNoCredentials},
+		{name: "other scheme", authorization: "Basic dXNlcjpwYXNz", wantErr: ErrInvalidCredentials},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := a.Authenticate(context.Background(), tt.authorization, tt.apiKey)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Authenticate = %+v, %v; want %v", p, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Authenticate: %v", err)
+			}
+			if p.Kind != "api_key" || p.Subject != tt.wantSubject {
+				t.Errorf("Authenticate = %+v, want api_key %s", p, tt.wantSubject)
+			}
+		})
+	}
+}
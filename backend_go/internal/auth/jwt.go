@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// claims are the JWT claims the API reads. Scopes are given in "scope", as
+// a space-separated string like OAuth 2.0 access tokens, or as a "scopes"
+// array.
+type claims struct {
+	Subject   string   `json:"sub"`
+	Issuer    string   `json:"iss,omitempty"`
+	ExpiresAt int64    `json:"exp"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	Scope     string   `json:"scope,omitempty"`
+	Scopes    []string `json:"scopes,omitempty"`
+}
+
+var jwtHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// leeway allows for clock skew between the issuer and the API.
+const leeway = time.Minute
+
+// verifyJWT checks an HS256 token and returns its subject and scopes.
+// Tokens must expire.
+func (a *Authenticator) verifyJWT(ctx context.Context, token string) (*Principal, error) {
+	if a.jwtSecret == nil {
+		return nil, ErrInvalidCredentials
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidCredentials
+	}
+
+	header, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	var h struct {
+		Alg string `json:"alg"`
+	}
+	if json.Unmarshal(header, &h) != nil || h.Alg != "HS256" {
+		return nil, ErrInvalidCredentials
+	}
+	secret, err := a.jwtSecret(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if secret == "" {
+		return nil, ErrInvalidCredentials
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(sig, sign([]byte(secret), parts[0]+"."+parts[1])) {
+		return nil, ErrInvalidCredentials
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	var c claims
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	now := time.Now()
+	if c.ExpiresAt == 0 || now.After(time.Unix(c.ExpiresAt, 0).Add(leeway)) {
+		return nil, ErrInvalidCredentials
+	}
+	if c.NotBefore != 0 && now.Add(leeway).Before(time.Unix(c.NotBefore, 0)) {
+		return nil, ErrInvalidCredentials
+	}
+	if a.issuer != "" && c.Issuer != a.issuer {
+		return nil, ErrInvalidCredentials
+	}
+
+	scopes := c.Scopes
+	if c.Scope != "" {
+		scopes = append(scopes, strings.Fields(c.Scope)...)
+	}
+	return &Principal{Kind: "jwt", Subject: c.Subject, Scopes: scopes}, nil
+}
+
+// SignJWT returns an HS256 token for subject with scopes, valid for ttl.
+func SignJWT(secret, issuer, subject string, scopes []string, ttl time.Duration) (string, error) {
+	if secret == "" {
+		return "", errors.New("JWT secret is not set")
+	}
+	now := time.Now()
+	payload, err := json.Marshal(claims{
+		Subject:   subject,
+		Issuer:    issuer,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(ttl).Unix(),
+		Scope:     strings.Join(scopes, " "),
+	})
+	if err != nil {
+		return "", err
+	}
+	signed := jwtHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sign([]byte(secret), signed)), nil
+}
+
+func sign(secret []byte, data string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,203 @@
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSecret = "test-secret"
+
+// signClaims signs c like SignJWT, so that tests can set any claim.
+func signClaims(t *testing.T, secret string, c claims) string {
+	t.Helper()
+	payload, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := jwtHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sign([]byte(secret), signed))
+}
+
+func TestSignJWTRoundTrip(t *testing.T) {
+	token, err := SignJWT(testSecret, "issuer", "ci", []string{ScopeRead, ScopeJobs}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := NewAuthenticator(nil, StaticSecret(testSecret), "issuer")
+	p, err := a.Authenticate(context.Background(), "Bearer "+token, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Kind != "jwt" || p.Subject != "ci" || !slices.Equal(p.Scopes, []string{ScopeRead, ScopeJobs}) {
+		t.Errorf("Authenticate = %+v, want jwt ci with read and jobs", p)
+	}
+}
+
+func TestSignJWTWithoutSecret(t *testing.T) {
+	if _, err := SignJWT("", "", "ci", []string{ScopeRead}, time.Hour); err == nil {
+		t.Error("SignJWT without a secret succeeded")
+	}
+}
+
+func TestVerifyJWT(t *testing.T) {
+	now := time.Now()
+	valid := claims{Subject: "ci", Issuer: "issuer", ExpiresAt: now.Add(time.Hour).Unix()}
+
+	tests := []struct {
+		name       string
+		secret     string // of the authenticator
+		issuer     string // of the authenticator
+		token      string
+		wantErr    error
+		wantScopes []string
+	}{
+		{
+			name:   "valid",
+			secret: testSecret,
+			token:  signClaims(t, testSecret, valid),
+		},
+		{
+			name:   "any issuer when none is required",
+			secret: testSecret,
+			token:  signClaims(t, testSecret, claims{Subject: "ci", Issuer: "other", ExpiresAt: valid.ExpiresAt}),
+		},
+		{
+			name:       "scope string and scopes array",
+			secret:     testSecret,
+			token:      signClaims(t, testSecret, claims{Subject: "ci", ExpiresAt: valid.ExpiresAt, Scope: "read ask", Scopes: []string{"jobs"}}),
+			wantScopes: []string{"jobs", "read", "ask"},
+		},
+		{
+			name:    "wrong secret",
+			secret:  "other-secret",
+			token:   signClaims(t, testSecret, valid),
+			wantErr: ErrInvalidCredentials,
+		},
+		{
+			name:    "JWTs disabled",
+			secret:  "",
+			token:   signClaims(t, testSecret, valid),
+			wantErr: ErrInvalidCredentials,
+		},
+		{
+			name:    "wrong issuer",
+			secret:  testSecret,
+			issuer:  "someone-else",
+			token:   signClaims(t, testSecret, valid),
+			wantErr: ErrInvalidCredentials,
+		},
+		{
+			name:    "expired",
+			secret:  testSecret,
+			token:   signClaims(t, testSecret, claims{Subject: "ci", ExpiresAt: now.Add(-2 * leeway).Unix()}),
+			wantErr: ErrInvalidCredentials,
+		},
+		{
+			name:   "expired within the leeway",
+			secret: testSecret,
+			token:  signClaims(t, testSecret, claims{Subject: "ci", ExpiresAt: now.Add(-leeway / 2).Unix()}),
+		},
+		{
+			name:    "no expiry",
+			secret:  testSecret,
+			token:   signClaims(t, testSecret, claims{Subject: "ci"}),
+			wantErr: ErrInvalidCredentials,
+		},
+		{
+			name:    "not yet valid",
+			secret:  testSecret,
+			token:   signClaims(t, testSecret, claims{Subject: "ci", ExpiresAt: valid.ExpiresAt, NotBefore: now.Add(2 * leeway).Unix()}),
+			wantErr: ErrInvalidCredentials,
+		},
+		{
+			name:    "tampered payload",
+			secret:  testSecret,
+			token:   tamper(signClaims(t, testSecret, valid), signClaims(t, testSecret, claims{Subject: "admin", ExpiresAt: valid.ExpiresAt, Scope: "admin"})),
+			wantErr: ErrInvalidCredentials,
+		},
+		{
+			name:    "other algorithm",
+			secret:  testSecret,
+			token:   base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." + strings.Split(signClaims(t, testSecret, valid), ".")[1] + ".",
+			wantErr: ErrInvalidCredentials,
+		},
+		{
+			name:    "malformed",
+			secret:  testSecret,
+			token:   "not-a-token",
+			wantErr: ErrInvalidCredentials,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewAuthenticator(nil, StaticSecret(tt.secret), tt.issuer)
+			p, err := a.Authenticate(context.Background(), "Bearer "+tt.token, "")
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Authenticate = %+v, %v; want %v", p, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Authenticate: %v", err)
+			}
+			if p.Subject != "ci" {
+				t.Errorf("subject = %q, want ci", p.Subject)
+			}
+			if tt.wantScopes != nil && !slices.Equal(p.Scopes, tt.wantScopes) {
+				t.Errorf("scopes = %v, want %v", p.Scopes, tt.wantScopes)
+			}
+		})
+	}
+}
+
+// tamper returns token with the payload of other but its own signature.
+func tamper(token, other string) string {
+	t, o := strings.Split(token, "."), strings.Split(other, ".")
+	return t[0] + "." + o[1] + "." + t[2]
+}
+
+func TestJWTSecretReadLazily(t *testing.T) {
+	token := signClaims(t, testSecret, claims{Subject: "ci", ExpiresAt: time.Now().Add(time.Hour).Unix()})
+	keys := memoryKeys{HashKey("ysk_active"): {ID: "active", Scopes: []string{ScopeRead}}}
+	errBackend := errors.New("secrets backend unavailable")
+
+	tests := []struct {
+		name          string
+		authorization string
+		apiKey        string
+		secretErr     error
+		wantCalls     int
+		wantErr       error
+	}{
+		{name: "no credentials", wantErr: ErrNoCredentials},
+		{name: "X-API-Key", apiKey: "ysk_active"},
+		{name: "bearer key", authorization: "Bearer ysk_active"},
+		{name: "malformed token", authorization: "Bearer not-a-token", wantErr: ErrInvalidCredentials},
+		{name: "JWT", authorization: "Bearer " + token, wantCalls: 1},
+		{name: "secret unavailable", authorization: "Bearer " + token, secretErr: errBackend, wantCalls: 1, wantErr: errBackend},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			a := NewAuthenticator(keys, func(ctx context.Context) (string, error) {
+				calls++
+				return testSecret, tt.secretErr
+			}, "")
+			_, err := a.Authenticate(context.Background(), tt.authorization, tt.apiKey)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Authenticate error = %v, want %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("secret read %d times, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// API keys look like "ysk_<id>_<secret>". Only their SHA-256 hash is
+// stored; the ID names the key when listing and revoking it.
+const keyPrefix = "ysk_"
+
+// keysPartition holds one item per key, sorted by hash.
+const keysPartition = "apikeys"
+
+// APIKey is an issued API key, without the key itself.
+type APIKey struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	Scopes    []string `json:"scopes"`
+	CreatedAt string   `json:"createdAt"`
+	RevokedAt string   `json:"revokedAt,omitempty"`
+	hash      string
+}
+
+// IsAPIKey reports whether s has the form of an API key.
+func IsAPIKey(s string) bool {
+	return strings.HasPrefix(s, keyPrefix)
+}
+
+// HashKey returns the stored form of key.
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) string {
+	buf := make([]byte, n)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// DynamoKeyStore keeps API keys in the table.
+type DynamoKeyStore struct {
+	client *dynamodb.Client
+	table  string
+}
+
+// NewDynamoKeyStore returns a key store backed by table.
+func NewDynamoKeyStore(client *dynamodb.Client, table string) *DynamoKeyStore {
+	return &DynamoKeyStore{client: client, table: table}
+}
+
+func keyItemKey(hash string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"hashtag":     &types.AttributeValueMemberS{Value: keysPartition},
+		"processedAt": &types.AttributeValueMemberS{Value: hash},
+	}
+}
+
+func keyFromItem(item map[string]types.AttributeValue) *APIKey {
+	k := &APIKey{}
+	for name, field := range map[string]*string{
+		"processedAt": &k.hash,
+		"keyId":       &k.ID,
+		"name":        &k.Name,
+		"createdAt":   &k.CreatedAt,
+		"revokedAt":   &k.RevokedAt,
+	} {
+		if v, ok := item[name].(*types.AttributeValueMemberS); ok {
+			*field = v.Value
+		}
+	}
+	if v, ok := item["scopes"].(*types.AttributeValueMemberSS); ok {
+		k.Scopes = v.Value
+	}
+	return k
+}
+
+// Issue creates a key with scopes and returns it. The key cannot be
+// recovered later.
+func (s *DynamoKeyStore) Issue(ctx context.Context, name string, scopes []string) (string, *APIKey, error) {
+	if len(scopes) == 0 {
+		return "", nil, errors.New("a key needs at least one scope")
+	}
+	id := randomHex(4)
+	key := keyPrefix + id + "_" + randomHex(24)
+	k := &APIKey{ID: id, Name: name, Scopes: scopes, CreatedAt: time.Now().UTC().Format(time.RFC3339)}
+
+	item := keyItemKey(HashKey(key))
+	item["keyId"] = &types.AttributeValueMemberS{Value: k.ID}
+	item["name"] = &types.AttributeValueMemberS{Value: k.Name}
+	item["scopes"] = &types.AttributeValueMemberSS{Value: k.Scopes}
+	item["createdAt"] = &types.AttributeValueMemberS{Value: k.CreatedAt}
+	_, err := s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(s.table),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(processedAt)"),
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to save API key: %w", err)
+	}
+	return key, k, nil
+}
+
+// LookupKey returns the key with hash, or nil if there is none.
+func (s *DynamoKeyStore) LookupKey(ctx context.Context, hash string) (*APIKey, error) {
+	resp, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(s.table),
+		Key:       keyItemKey(hash),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up API key: %w", err)
+	}
+	if resp.Item == nil {
+		return nil, nil
+	}
+	return keyFromItem(resp.Item), nil
+}
+
+// List returns every issued key, revoked ones included.
+func (s *DynamoKeyStore) List(ctx context.Context) ([]*APIKey, error) {
+	var keys []*APIKey
+	var lastEvaluatedKey map[string]types.AttributeValue
+	for {
+		resp, err := s.client.Query(ctx, &dynamodb.QueryInput{
+			TableName:              aws.String(s.table),
+			KeyConditionExpression: aws.String("hashtag = :p"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":p": &types.AttributeValueMemberS{Value: keysPartition},
+			},
+			ExclusiveStartKey: lastEvaluatedKey,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list API keys: %w", err)
+		}
+		for _, item := range resp.Items {
+			keys = append(keys, keyFromItem(item))
+		}
+		if resp.LastEvaluatedKey == nil {
+			return keys, nil
+		}
+		lastEvaluatedKey = resp.LastEvaluatedKey
+	}
+}
+
+// Revoke revokes the key with id. Revoked keys are kept so that they show
+// up in List.
+func (s *DynamoKeyStore) Revoke(ctx context.Context, id string) (*APIKey, error) {
+	keys, err := s.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range keys {
+		if k.ID != id {
+			continue
+		}
+		if k.RevokedAt != "" {
+			return k, nil
+		}
+		k.RevokedAt = time.Now().UTC().Format(time.RFC3339)
+		_, err := s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+			TableName:        aws.String(s.table),
+			Key:              keyItemKey(k.hash),
+			UpdateExpression: aws.String("SET revokedAt = :r"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":r": &types.AttributeValueMemberS{Value: k.RevokedAt},
+			},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to revoke API key: %w", err)
+		}
+		return k, nil
+	}
+	return nil, fmt.Errorf("no API key with ID %q", id)
+}
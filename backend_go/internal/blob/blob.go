@@ -0,0 +1,79 @@
+// Package blob stores large payloads, such as long transcripts, outside of
+// DynamoDB items. Items keep the blob key and load the data on demand.
+package blob
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// ErrNotFound is returned by Get when no blob is stored under the key.
+var ErrNotFound = errors.New("blob not found")
+
+// Store is a flat key/value store for opaque data.
+type Store interface {
+	Put(ctx context.Context, key string, data []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+}
+
+// Open returns the store described by location:
+//
+//	s3://bucket/optional/prefix
+//	file:///var/lib/youtube-summary/blobs
+//
+// An empty location returns nil, meaning offloading is disabled. S3 buckets
+// are expected in region.
+func Open(ctx context.Context, location, region string) (Store, error) {
+	if location == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("invalid blob store location %q: %w", location, err)
+	}
+	switch u.Scheme {
+	case "s3":
+		return NewS3Store(ctx, region, u.Host, strings.TrimPrefix(u.Path, "/"))
+	case "file":
+		return NewFileStore(u.Path)
+	default:
+		return nil, fmt.Errorf("unsupported blob store scheme %q", u.Scheme)
+	}
+}
+
+// PutCompressed gzips data and stores it under key.
+func PutCompressed(ctx context.Context, s Store, key string, data []byte) error {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return fmt.Errorf("failed to compress blob %s: %w", key, err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to compress blob %s: %w", key, err)
+	}
+	return s.Put(ctx, key, buf.Bytes())
+}
+
+// GetCompressed loads and decompresses a blob stored with PutCompressed.
+func GetCompressed(ctx context.Context, s Store, key string) ([]byte, error) {
+	data, err := s.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress blob %s: %w", key, err)
+	}
+	defer zr.Close()
+	out, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress blob %s: %w", key, err)
+	}
+	return out, nil
+}
@@ -0,0 +1,131 @@
+package blob
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileStorePath(t *testing.T) {
+	s := &FileStore{dir: "/var/blobs"}
+	tests := []struct {
+		key     string
+		want    string
+		wantErr bool
+	}{
+		{key: "transcripts/abc.json.gz", want: "/var/blobs/transcripts/abc.json.gz"},
+		{key: "/transcripts/abc.json.gz", want: "/var/blobs/transcripts/abc.json.gz"},
+		{key: "a//b/./c", want: "/var/blobs/a/b/c"},
+		{key: "../etc/passwd", wantErr: true},
+		{key: "transcripts/../../etc/passwd", wantErr: true},
+		{key: "a/..", wantErr: true},
+		{key: "", wantErr: true},
+		{key: "/", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := s.path(tt.key)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("path(%q) = %q, want an error", tt.key, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("path(%q) = %q, %v; want %q", tt.key, got, err, tt.want)
+		}
+	}
+}
+
+func TestFileStorePutGet(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	s, err := NewFileStore(filepath.Join(dir, "blobs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Put(ctx, "transcripts/abc.json", []byte("first")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(ctx, "transcripts/abc.json", []byte("second")); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.Get(ctx, "transcripts/abc.json")
+	if err != nil || string(got) != "second" {
+		t.Errorf("Get = %q, %v; want the last put", got, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "blobs", "transcripts", "abc.json.tmp")); !os.IsNotExist(err) {
+		t.Errorf("temporary file left behind: %v", err)
+	}
+
+	if _, err := s.Get(ctx, "transcripts/missing.json"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get of a missing key = %v, want ErrNotFound", err)
+	}
+	if err := s.Put(ctx, "../outside", []byte("x")); err == nil {
+		t.Error("Put outside the root succeeded")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "outside")); !os.IsNotExist(err) {
+		t.Error("Put wrote outside the root")
+	}
+}
+
+func TestCompressedRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte(strings.Repeat("字幕のテキスト ", 1000))
+	if err := PutCompressed(ctx, s, "big.gz", data); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := s.Get(ctx, "big.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) >= len(data) {
+		t.Errorf("stored %d bytes of %d, want them compressed", len(stored), len(data))
+	}
+	got, err := GetCompressed(ctx, s, "big.gz")
+	if err != nil || !bytes.Equal(got, data) {
+		t.Errorf("GetCompressed = %d bytes, %v; want the original %d", len(got), err, len(data))
+	}
+
+	if err := s.Put(ctx, "plain", []byte("not gzip")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetCompressed(ctx, s, "plain"); err == nil {
+		t.Error("GetCompressed of uncompressed data succeeded")
+	}
+}
+
+func TestOpen(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		location string
+		wantNil  bool
+		wantErr  bool
+	}{
+		{location: "", wantNil: true},
+		{location: "file://" + dir},
+		{location: "s3://", wantErr: true}, // no bucket
+		{location: "gs://bucket", wantErr: true},
+		{location: "::", wantErr: true},
+	}
+	for _, tt := range tests {
+		s, err := Open(context.Background(), tt.location, "us-east-1")
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Open(%q) = %v, want an error", tt.location, s)
+			}
+			continue
+		}
+		if err != nil || tt.wantNil != (s == nil) {
+			t.Errorf("Open(%q) = %v, %v", tt.location, s, err)
+		}
+	}
+}
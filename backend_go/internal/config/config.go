@@ -0,0 +1,184 @@
+// Package config loads the settings shared by the batch and the API from
+// defaults, an optional YAML or TOML file, environment variables and
+// command-line flags, in increasing order of precedence.
+//
+// Every setting has an environment variable name. The same name in lower
+// case is its key in the file, and with dashes instead of underscores its
+// flag: DYNAMODB_TABLE, dynamodb_table and -dynamodb-table.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// Config holds every setting. Fields tagged secret are redacted by Print.
+type Config struct {
+	// Deployment
+	Environment   string `env:"ENVIRONMENT" help:"deployment environment (dev, prd)"`
+	Region        string `env:"AWS_REGION" default:"ap-northeast-1" help:"region of the table, blobs, secrets and Lambdas"`
+	BedrockRegion string `env:"BEDROCK_REGION" default:"us-east-1" help:"region Bedrock is called in"`
+	Table         string `env:"DYNAMODB_TABLE" default:"youtube-summary-dev" help:"DynamoDB table"`
+	ChannelID     string `env:"CHANNEL_ID" default:"UC2kM01yXNnouBsJJ0ghyfMg" help:"channel processed while none is configured through the admin API"`
+	BlobStore     string `env:"BLOB_STORE" help:"s3:// or file:// location of offloaded transcripts and summaries"`
+	LocalRun      bool   `env:"LOCAL_RUN" help:"run the batch once instead of as a Lambda"`
+	LogLevel      string `env:"LOG_LEVEL" default:"info" help:"least severe level logged: debug, info, warn or error"`
+
+	// Secrets
+	SecretsBackend  string `env:"SECRETS_BACKEND" default:"secretsmanager" help:"where secrets are read: secretsmanager, env or file:///path/to/secrets.yaml"`
+	SecretsCacheTTL int    `env:"SECRETS_CACHE_TTL_SECONDS" default:"300" help:"seconds Secrets Manager values are reused (0 = always fetch)"`
+
+	// YouTube
+	YouTubeAPISecret string `env:"YOUTUBE_API_SECRET" default:"youtube-summary/youtube-api-key" help:"name of the secret holding the YouTube API key"`
+	YouTubeQuota     int64  `env:"YOUTUBE_DAILY_QUOTA_BUDGET" default:"10000" help:"YouTube API units to spend per day"`
+	AudienceRegion   string `env:"AUDIENCE_REGION" default:"JP" help:"region whose viewers decide if a video is blocked"`
+
+	// LLM
+	ModelID          string   `env:"BEDROCK_MODEL_ID" help:"Bedrock model or inference profile (empty = llm.DefaultModelID)"`
+	PriceTable       string   `env:"LLM_PRICE_TABLE" help:"JSON prices per model, layered over the built-in ones"`
+	MonthlyBudgetUSD float64  `env:"LLM_MONTHLY_BUDGET_USD" help:"monthly LLM spend after which summarization pauses (0 = none)"`
+	TagVocabulary    string   `env:"TAG_VOCABULARY" help:"JSON object of canonical tags and their aliases"`
+	SummaryLanguages []string `env:"SUMMARY_LANGUAGES" help:"languages summaries are translated into at batch time"`
+	TranslateMax     int      `env:"TRANSLATE_ON_REQUEST_MAX" default:"4" help:"summaries the API translates per request"`
+
+	// Batch
+	BatchJob               string `env:"BATCH_JOB" help:"job of a local run (empty = regular run)"`
+	BatchChannelID         string `env:"BATCH_CHANNEL_ID" help:"channel of a local run (empty = all)"`
+	TranscriptOffloadBytes int    `env:"TRANSCRIPT_OFFLOAD_BYTES" default:"102400" help:"transcripts larger than this go to the blob store"`
+	OffloadDetailSummary   bool   `env:"OFFLOAD_DETAIL_SUMMARY" help:"keep detail summaries in the blob store"`
+	CommentSummary         bool   `env:"COMMENT_SUMMARY" help:"summarize comments of new videos"`
+	CommentMaxThreads      int    `env:"COMMENT_SUMMARY_MAX_THREADS" default:"100" help:"top-level comment threads read per video, at most 100"`
+	CommentRefreshMaxAge   int    `env:"COMMENT_REFRESH_MAX_AGE_DAYS" default:"7" help:"age in days up to which comment summaries are refreshed"`
+	CommentRefreshInterval int    `env:"COMMENT_REFRESH_INTERVAL_HOURS" default:"24" help:"hours between refreshes of a comment summary"`
+	StatsRefreshMaxAge     int    `env:"STATS_REFRESH_MAX_AGE_DAYS" default:"7" help:"age in days up to which statistics are refreshed"`
+	ShortsMode             string `env:"SHORTS_MODE" default:"summarize" help:"summarize, skip, digest or brief"`
+	ShortsMaxSeconds       int64  `env:"SHORTS_MAX_SECONDS" default:"180" help:"longest video treated as a Short (Shorts run up to three minutes)"`
+	DigestTimezone         string `env:"DIGEST_TIMEZONE" default:"Asia/Tokyo" help:"time zone digest days start in"`
+	WebSubCallbackURL      string `env:"WEBSUB_CALLBACK_URL" help:"API callback WebSub pushes go to (empty = no push subscriptions)"`
+	WebSubRenewBeforeHours int    `env:"WEBSUB_RENEW_BEFORE_HOURS" default:"48" help:"hours before expiry a push subscription is renewed"`
+	WebSubSecret           string `env:"WEBSUB_SECRET" secret:"true" help:"HMAC secret of WebSub pushes"`
+	WebSubSecretName       string `env:"WEBSUB_SECRET_NAME" help:"name of the secret holding WEBSUB_SECRET"`
+	NotifyTargets          string `env:"NOTIFY_TARGETS" secret:"true" help:"JSON array of chat and webhook targets"`
+	NotifyTargetsSecret    string `env:"NOTIFY_TARGETS_SECRET" help:"name of the secret holding NOTIFY_TARGETS"`
+	SMTPHost               string `env:"SMTP_HOST" help:"SMTP server of email delivery (empty = no email)"`
+	SMTPPort               int    `env:"SMTP_PORT" default:"587" help:"SMTP port"`
+	SMTPUsername           string `env:"SMTP_USERNAME" help:"SMTP user (empty = no authentication)"`
+	SMTPPassword           string `env:"SMTP_PASSWORD" secret:"true" help:"SMTP password"`
+	SMTPPasswordSecret     string `env:"SMTP_PASSWORD_SECRET" help:"name of the secret holding the SMTP password"`
+	MailFrom               string `env:"MAIL_FROM" help:"sender address of emails"`
+	APIBaseURL             string `env:"API_BASE_URL" help:"base URL of the API, for unsubscribe links"`
+
+	// API
+	BatchFunctionName string   `env:"BATCH_FUNCTION_NAME" help:"batch Lambda the API starts (empty = wait for the schedule)"`
+	JWTSecret         string   `env:"JWT_SECRET" secret:"true" help:"HS256 secret of bearer tokens (empty = API keys only)"`
+	JWTSecretName     string   `env:"JWT_SECRET_NAME" help:"name of the secret holding JWT_SECRET"`
+	JWTIssuer         string   `env:"JWT_ISSUER" help:"required iss claim of bearer tokens"`
+	AnonymousScopes   []string `env:"AUTH_ANONYMOUS_SCOPES" default:"read" help:"scopes of requests without credentials (none = no scopes)"`
+	RateLimitMode     string   `env:"RATE_LIMIT_MODE" default:"dynamodb" help:"dynamodb, memory or off"`
+	RateLimits        string   `env:"RATE_LIMITS" help:"JSON object of {perMinute, burst} per route class"`
+}
+
+// setting is a field of Config with its names.
+type setting struct {
+	env    string
+	key    string // in files
+	flag   string
+	help   string
+	def    string
+	secret bool
+	field  reflect.Value
+}
+
+func (c *Config) settings() []setting {
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+	var out []setting
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		env := f.Tag.Get("env")
+		out = append(out, setting{
+			env:    env,
+			key:    strings.ToLower(env),
+			flag:   strings.ReplaceAll(strings.ToLower(env), "_", "-"),
+			help:   f.Tag.Get("help"),
+			def:    f.Tag.Get("default"),
+			secret: f.Tag.Get("secret") == "true",
+			field:  v.Field(i),
+		})
+	}
+	return out
+}
+
+// Load reads the configuration. args are command-line arguments without
+// the program name; the ones left after the flags are returned. The file
+// is given with -config or CONFIG_FILE; .toml files are TOML, anything
+// else YAML. The result is validated.
+func Load(args []string) (*Config, []string, error) {
+	c := &Config{}
+	for _, s := range c.settings() {
+		if s.def != "" {
+			if err := s.set(s.def); err != nil {
+				return nil, nil, fmt.Errorf("default of %s: %w", s.env, err)
+			}
+		}
+	}
+
+	fs, flagValues, configFile := c.flagSet()
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	path := *configFile
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+	if path != "" {
+		if err := c.loadFile(path); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for _, s := range c.settings() {
+		// Empty variables count as unset, as they always have here
+		if v := os.Getenv(s.env); v != "" {
+			if err := s.set(v); err != nil {
+				return nil, nil, fmt.Errorf("%s: %w", s.env, err)
+			}
+		}
+	}
+
+	var flagErr error
+	fs.Visit(func(f *flag.Flag) {
+		if s, ok := flagValues[f.Name]; ok && flagErr == nil {
+			if err := s.set(f.Value.String()); err != nil {
+				flagErr = fmt.Errorf("-%s: %w", f.Name, err)
+			}
+		}
+	})
+	if flagErr != nil {
+		return nil, nil, flagErr
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, nil, err
+	}
+	return c, fs.Args(), nil
+}
+
+// MustLoad loads the configuration from the process's arguments and
+// environment, exiting if it is invalid.
+func MustLoad() (*Config, []string) {
+	c, args, err := Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration: %v\n", err)
+		os.Exit(2)
+	}
+	return c, args
+}
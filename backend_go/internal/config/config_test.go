@@ -0,0 +1,227 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// clearEnv unsets every setting and CONFIG_FILE for the test. Empty
+// variables count as unset.
+func clearEnv(t *testing.T) {
+	t.Helper()
+	for _, s := range (&Config{}).settings() {
+		t.Setenv(s.env, "")
+	}
+	t.Setenv("CONFIG_FILE", "")
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	const file = `
+dynamodb_table: from-file
+youtube_daily_quota_budget: 5000
+summary_languages: [en, ko]
+`
+	tests := []struct {
+		name      string
+		file      bool
+		env       map[string]string
+		args      []string
+		table     string
+		quota     int64
+		languages []string
+	}{
+		{
+			name:  "defaults",
+			table: "youtube-summary-dev",
+			quota: 10000,
+		},
+		{
+			name:      "file over defaults",
+			file:      true,
+			table:     "from-file",
+			quota:     5000,
+			languages: []string{"en", "ko"},
+		},
+		{
+			name:      "env over file",
+			file:      true,
+			env:       map[string]string{"DYNAMODB_TABLE": "from-env", "SUMMARY_LANGUAGES": "zh"},
+			table:     "from-env",
+			quota:     5000,
+			languages: []string{"zh"},
+		},
+		{
+			name:      "empty env does not override",
+			file:      true,
+			env:       map[string]string{"DYNAMODB_TABLE": ""},
+			table:     "from-file",
+			quota:     5000,
+			languages: []string{"en", "ko"},
+		},
+		{
+			name:      "flags over env",
+			file:      true,
+			env:       map[string]string{"DYNAMODB_TABLE": "from-env", "YOUTUBE_DAILY_QUOTA_BUDGET": "7000"},
+			args:      []string{"-dynamodb-table", "from-flag"},
+			table:     "from-flag",
+			quota:     7000,
+			languages: []string{"en", "ko"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			if tt.file {
+				t.Setenv("CONFIG_FILE", writeFile(t, "config.yaml", file))
+			}
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			c, _, err := Load(tt.args)
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if c.Table != tt.table {
+				t.Errorf("Table = %q, want %q", c.Table, tt.table)
+			}
+			if c.YouTubeQuota != tt.quota {
+				t.Errorf("YouTubeQuota = %d, want %d", c.YouTubeQuota, tt.quota)
+			}
+			if !slices.Equal(c.SummaryLanguages, tt.languages) {
+				t.Errorf("SummaryLanguages = %v, want %v", c.SummaryLanguages, tt.languages)
+			}
+		})
+	}
+}
+
+func TestLoadConfigFlagAndTOML(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("CONFIG_FILE", writeFile(t, "ignored.yaml", "dynamodb_table: from-env-file\n"))
+	path := writeFile(t, "config.toml", `
+dynamodb_table = "from-toml"
+shorts_mode = "skip"
+
+[tag_vocabulary]
+AI = ["人工知能"]
+`)
+	c, args, err := Load([]string{"-config", path, "rest"})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if c.Table != "from-toml" || c.ShortsMode != "skip" {
+		t.Errorf("Table, ShortsMode = %q, %q; want from-toml, skip", c.Table, c.ShortsMode)
+	}
+	if c.TagVocabulary != `{"AI":["人工知能"]}` {
+		t.Errorf("TagVocabulary = %s, want the table as JSON", c.TagVocabulary)
+	}
+	if !slices.Equal(args, []string{"rest"}) {
+		t.Errorf("remaining args = %v, want [rest]", args)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		env  map[string]string
+		args []string
+		want []string // substrings of the error
+	}{
+		{
+			name: "invalid integer",
+			env:  map[string]string{"SMTP_PORT": "smtp"},
+			want: []string{"SMTP_PORT", "invalid integer"},
+		},
+		{
+			name: "invalid flag value",
+			args: []string{"-local-run", "maybe"},
+			want: []string{"-local-run", "invalid boolean"},
+		},
+		{
+			name: "unknown file setting",
+			file: "dynamo_table: typo\n",
+			want: []string{`unknown setting "dynamo_table"`},
+		},
+		{
+			name: "every problem is reported",
+			env: map[string]string{
+				"SHORTS_MODE":           "sometimes",
+				"RATE_LIMIT_MODE":       "redis",
+				"AUTH_ANONYMOUS_SCOPES": "read,write",
+				"API_BASE_URL":          "ftp://example.com",
+			},
+			want: []string{"SHORTS_MODE", "RATE_LIMIT_MODE", `unknown scope "write"`, "API_BASE_URL"},
+		},
+		{
+			name: "mail sender needed with SMTP",
+			env:  map[string]string{"SMTP_HOST": "localhost"},
+			want: []string{"MAIL_FROM"},
+		},
+		{
+			name: "push subscriptions need a secret",
+			env:  map[string]string{"WEBSUB_CALLBACK_URL": "https://api.example.com/api/websub/callback"},
+			want: []string{"WEBSUB_SECRET or WEBSUB_SECRET_NAME is required"},
+		},
+		{
+			name: "required setting emptied",
+			args: []string{"-dynamodb-table", ""},
+			want: []string{"DYNAMODB_TABLE is required"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			if tt.file != "" {
+				t.Setenv("CONFIG_FILE", writeFile(t, "config.yaml", tt.file))
+			}
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			_, _, err := Load(tt.args)
+			if err == nil {
+				t.Fatal("Load succeeded, want an error")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not mention %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestScopes(t *testing.T) {
+	tests := []struct {
+		env  string
+		want []string
+	}{
+		{env: "", want: []string{"read"}},
+		{env: "read,ask", want: []string{"read", "ask"}},
+		{env: "none", want: nil},
+	}
+	for _, tt := range tests {
+		clearEnv(t)
+		t.Setenv("AUTH_ANONYMOUS_SCOPES", tt.env)
+		c, _, err := Load(nil)
+		if err != nil {
+			t.Fatalf("Load: %v", err)
+		}
+		if got := c.Scopes(); !slices.Equal(got, tt.want) {
+			t.Errorf("Scopes() with %q = %v, want %v", tt.env, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// set parses value into the setting's field. Lists are comma-separated.
+func (s setting) set(value string) error {
+	switch s.field.Kind() {
+	case reflect.String:
+		s.field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", value)
+		}
+		s.field.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", value)
+		}
+		s.field.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", value)
+		}
+		s.field.SetFloat(f)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		s.field.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported setting type %s", s.field.Type())
+	}
+	return nil
+}
+
+// flagSet returns the flags of every setting, by flag name, and of the
+// config file. Flags are parsed as strings and applied with set, so that
+// they follow the same rules as variables.
+func (c *Config) flagSet() (*flag.FlagSet, map[string]setting, *string) {
+	fs := flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ContinueOnError)
+	byFlag := map[string]setting{}
+	for _, s := range c.settings() {
+		fs.String(s.flag, "", fmt.Sprintf("%s (%s)", s.help, s.env))
+		byFlag[s.flag] = s
+	}
+	configFile := fs.String("config", "", "YAML or TOML configuration file (CONFIG_FILE)")
+	return fs, byFlag, configFile
+}
+
+// loadFile applies the settings in a YAML or TOML file.
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	values := map[string]interface{}{}
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		err = toml.Unmarshal(data, &values)
+	} else {
+		err = yaml.Unmarshal(data, &values)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	byKey := map[string]setting{}
+	for _, s := range c.settings() {
+		byKey[s.key] = s
+	}
+	for key, v := range values {
+		s, ok := byKey[strings.ToLower(key)]
+		if !ok {
+			return fmt.Errorf("config file %s: unknown setting %q", path, key)
+		}
+		var value string
+		switch v := v.(type) {
+		case []interface{}:
+			items := make([]string, len(v))
+			for i, item := range v {
+				items[i] = fmt.Sprint(item)
+			}
+			value = strings.Join(items, ",")
+		case map[string]interface{}:
+			// JSON settings such as tag_vocabulary may be written as tables
+			value, err = toJSON(v)
+			if err != nil {
+				return fmt.Errorf("config file %s: %s: %w", path, key, err)
+			}
+		default:
+			value = fmt.Sprint(v)
+		}
+		if err := s.set(value); err != nil {
+			return fmt.Errorf("config file %s: %s: %w", path, key, err)
+		}
+	}
+	return nil
+}
+
+// Print writes the configuration as a YAML config file, with secrets
+// redacted.
+func (c *Config) Print(w io.Writer) error {
+	for _, s := range c.settings() {
+		value := s.field.Interface()
+		if s.secret && !s.field.IsZero() {
+			value = "REDACTED"
+		}
+		if list, ok := value.([]string); ok && list == nil {
+			value = []string{}
+		}
+		out, err := yaml.Marshal(map[string]interface{}{s.key: value})
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "# %s\n%s", s.help, flowLists(out)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flowLists puts a marshaled block list on one line.
+func flowLists(out []byte) string {
+	lines := strings.Split(strings.TrimRight(string(out), "\n"), "\n")
+	if len(lines) == 1 {
+		return lines[0] + "\n"
+	}
+	var items []string
+	for _, l := range lines[1:] {
+		items = append(items, strings.TrimPrefix(strings.TrimSpace(l), "- "))
+	}
+	return lines[0] + " [" + strings.Join(items, ", ") + "]\n"
+}
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ttakahashi/youtube-summary/internal/auth"
+	"github.com/ttakahashi/youtube-summary/internal/i18n"
+	"github.com/ttakahashi/youtube-summary/internal/llm"
+	"github.com/ttakahashi/youtube-summary/internal/logging"
+	"github.com/ttakahashi/youtube-summary/internal/notify"
+	"github.com/ttakahashi/youtube-summary/internal/ratelimit"
+	"github.com/ttakahashi/youtube-summary/internal/tags"
+)
+
+func toJSON(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	return string(data), err
+}
+
+// Validate checks every setting and reports all problems at once.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+	parses := func(name, value string, parse func(string) error) {
+		if value != "" {
+			if err := parse(value); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			}
+		}
+	}
+
+	check(c.Region != "", "AWS_REGION is required")
+	check(c.BedrockRegion != "", "BEDROCK_REGION is required")
+	check(c.Table != "", "DYNAMODB_TABLE is required")
+	check(c.YouTubeQuota > 0, "YOUTUBE_DAILY_QUOTA_BUDGET must be positive")
+	check(c.MonthlyBudgetUSD >= 0, "LLM_MONTHLY_BUDGET_USD must not be negative")
+	check(c.TranslateMax >= 0, "TRANSLATE_ON_REQUEST_MAX must not be negative")
+	check(c.TranscriptOffloadBytes > 0, "TRANSCRIPT_OFFLOAD_BYTES must be positive")
+	check(c.CommentMaxThreads > 0 && c.CommentMaxThreads <= 100, "COMMENT_SUMMARY_MAX_THREADS must be between 1 and 100")
+	check(c.CommentRefreshMaxAge > 0, "COMMENT_REFRESH_MAX_AGE_DAYS must be positive")
+	check(c.CommentRefreshInterval > 0, "COMMENT_REFRESH_INTERVAL_HOURS must be positive")
+	check(c.StatsRefreshMaxAge > 0, "STATS_REFRESH_MAX_AGE_DAYS must be positive")
+	check(c.ShortsMaxSeconds > 0, "SHORTS_MAX_SECONDS must be positive")
+	check(c.WebSubRenewBeforeHours > 0, "WEBSUB_RENEW_BEFORE_HOURS must be positive")
+	check(slices.Contains([]string{"summarize", "skip", "digest", "brief"}, c.ShortsMode), "SHORTS_MODE must be summarize, skip, digest or brief")
+	check(c.SecretsBackend == "secretsmanager" || c.SecretsBackend == "env" || strings.HasPrefix(c.SecretsBackend, "file://"), "SECRETS_BACKEND must be secretsmanager, env or a file:// URL")
+	check(c.SecretsCacheTTL >= 0, "SECRETS_CACHE_TTL_SECONDS must not be negative")
+	check(slices.Contains([]string{"memory", "dynamodb", "off"}, c.RateLimitMode), "RATE_LIMIT_MODE must be memory, dynamodb or off")
+	check(c.SMTPPort > 0 && c.SMTPPort < 65536, "SMTP_PORT must be a port number")
+
+	for _, lang := range c.SummaryLanguages {
+		check(i18n.Normalize(lang) != "", "SUMMARY_LANGUAGES: unsupported language %q", lang)
+	}
+	if !(len(c.AnonymousScopes) == 1 && c.AnonymousScopes[0] == "none") {
+		for _, scope := range c.AnonymousScopes {
+			check(slices.Contains(auth.Scopes, scope), "AUTH_ANONYMOUS_SCOPES: unknown scope %q", scope)
+		}
+	}
+	parses("LOG_LEVEL", c.LogLevel, func(s string) error { _, err := logging.ParseLevel(s); return err })
+	parses("DIGEST_TIMEZONE", c.DigestTimezone, func(s string) error { _, err := time.LoadLocation(s); return err })
+	parses("LLM_PRICE_TABLE", c.PriceTable, func(s string) error { _, err := llm.ParsePriceTable(s); return err })
+	parses("TAG_VOCABULARY", c.TagVocabulary, func(s string) error { _, err := tags.ParseVocabulary(s); return err })
+	parses("NOTIFY_TARGETS", c.NotifyTargets, func(s string) error { _, err := notify.ParseTargets(s); return err })
+	parses("RATE_LIMITS", c.RateLimits, func(s string) error { _, err := ratelimit.ParseLimits(s); return err })
+	for name, value := range map[string]string{
+		"WEBSUB_CALLBACK_URL": c.WebSubCallbackURL,
+		"API_BASE_URL":        c.APIBaseURL,
+	} {
+		parses(name, value, func(s string) error {
+			u, err := url.Parse(s)
+			if err == nil && (u.Scheme != "https" && u.Scheme != "http" || u.Host == "") {
+				err = errors.New("must be an http(s) URL")
+			}
+			return err
+		})
+	}
+	if c.SMTPHost != "" {
+		_, err := mail.ParseAddress(c.MailFrom)
+		check(err == nil, "MAIL_FROM must be an email address when SMTP_HOST is set")
+	}
+	if c.WebSubCallbackURL != "" {
+		check(c.WebSubSecret != "" || c.WebSubSecretName != "", "WEBSUB_SECRET or WEBSUB_SECRET_NAME is required when WEBSUB_CALLBACK_URL is set")
+	}
+	return errors.Join(errs...)
+}
+
+// Scopes returns the scopes of anonymous requests.
+func (c *Config) Scopes() []string {
+	if len(c.AnonymousScopes) == 1 && c.AnonymousScopes[0] == "none" {
+		return nil
+	}
+	return c.AnonymousScopes
+}
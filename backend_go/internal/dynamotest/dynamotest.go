@@ -0,0 +1,129 @@
+// Package dynamotest serves a fake DynamoDB endpoint over HTTP, so that code
+// taking a *dynamodb.Client can be tested without AWS.
+package dynamotest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+// Call is one request to the fake: the operation, such as "UpdateItem", and
+// its JSON input as decoded by encoding/json.
+type Call struct {
+	Operation string
+	Input     map[string]interface{}
+}
+
+// Handler answers a call with its JSON output, or fails it with an error
+// type such as "ConditionalCheckFailedException".
+type Handler func(call Call) (output interface{}, errType string)
+
+// Server is a fake DynamoDB that records the calls it answered.
+type Server struct {
+	mu    sync.Mutex
+	calls []Call
+}
+
+// Calls returns the calls answered so far.
+func (s *Server) Calls() []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Call(nil), s.calls...)
+}
+
+// TestCredentials are fixed credentials for clients of fake endpoints.
+var TestCredentials = aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+	return aws.Credentials{AccessKeyID: "test", SecretAccessKey: "test", Source: "dynamotest"}, nil
+})
+
+// NewClient returns a client of a fake DynamoDB answered by h, and the fake.
+// The server is closed when the test ends. Failed calls are not retried.
+func NewClient(t testing.TB, h Handler) (*dynamodb.Client, *Server) {
+	t.Helper()
+	s := &Server{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call := Call{Operation: strings.TrimPrefix(r.Header.Get("X-Amz-Target"), "DynamoDB_20120810.")}
+		if err := json.NewDecoder(r.Body).Decode(&call.Input); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.mu.Lock()
+		s.calls = append(s.calls, call)
+		s.mu.Unlock()
+
+		output, errType := h(call)
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		if errType != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"__type":  "com.amazonaws.dynamodb.v20120810#" + errType,
+				"message": errType,
+			})
+			return
+		}
+		if output == nil {
+			output = map[string]interface{}{}
+		}
+		json.NewEncoder(w).Encode(output)
+	}))
+	t.Cleanup(srv.Close)
+
+	client := dynamodb.New(dynamodb.Options{
+		Region:           "us-east-1",
+		BaseEndpoint:     aws.String(srv.URL),
+		Credentials:      TestCredentials,
+		RetryMaxAttempts: 1,
+	})
+	return client, s
+}
+
+// S is a string attribute value in DynamoDB JSON.
+func S(v string) map[string]interface{} {
+	return map[string]interface{}{"S": v}
+}
+
+// N is a number attribute value in DynamoDB JSON.
+func N(v string) map[string]interface{} {
+	return map[string]interface{}{"N": v}
+}
+
+// Value returns the string or number held by the attribute value av of a
+// call's input, or "" when it holds neither.
+func Value(av interface{}) string {
+	m, _ := av.(map[string]interface{})
+	for _, k := range []string{"S", "N"} {
+		if v, ok := m[k].(string); ok {
+			return v
+		}
+	}
+	return ""
+}
+
+// Values returns the ExpressionAttributeValues of a call's input by name,
+// as Value reads them.
+func (c Call) Values() map[string]string {
+	values := map[string]string{}
+	m, _ := c.Input["ExpressionAttributeValues"].(map[string]interface{})
+	for name, av := range m {
+		values[name] = Value(av)
+	}
+	return values
+}
+
+// Key returns the hashtag and processedAt of the item a call names, from
+// its Key or Item.
+func (c Call) Key() (hashtag, processedAt string) {
+	m, ok := c.Input["Key"].(map[string]interface{})
+	if !ok {
+		m, _ = c.Input["Item"].(map[string]interface{})
+	}
+	return Value(m["hashtag"]), Value(m["processedAt"])
+}
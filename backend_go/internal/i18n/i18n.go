@@ -0,0 +1,167 @@
+// Package i18n translates summaries into the languages readers ask for and
+// stores the translations next to the original.
+package i18n
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/ttakahashi/youtube-summary/internal/llm"
+)
+
+// Source is the language summaries are written in.
+const Source = "ja"
+
+// Languages are the languages summaries can be read in, by primary
+// language subtag.
+var Languages = map[string]string{
+	"ja": "Japanese",
+	"en": "English",
+	"zh": "Simplified Chinese",
+	"ko": "Korean",
+	"es": "Spanish",
+	"fr": "French",
+	"de": "German",
+}
+
+// Normalize returns the supported language of a language tag such as
+// "en-US", or "" if it is not supported.
+func Normalize(tag string) string {
+	lang, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+	lang, _, _ = strings.Cut(lang, "_")
+	if _, ok := Languages[lang]; ok {
+		return lang
+	}
+	return ""
+}
+
+// FromAcceptLanguage picks the most preferred supported language of an
+// Accept-Language header, or "" if there is none.
+func FromAcceptLanguage(header string) string {
+	type choice struct {
+		lang string
+		q    float64
+	}
+	var choices []choice
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		lang := Normalize(tag)
+		if lang == "" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		if q > 0 {
+			choices = append(choices, choice{lang, q})
+		}
+	}
+	if len(choices) == 0 {
+		return ""
+	}
+	sort.SliceStable(choices, func(i, j int) bool { return choices[i].q > choices[j].q })
+	return choices[0].lang
+}
+
+// AttributeName is the item attribute holding the translation into lang.
+func AttributeName(lang string) string {
+	return "translation_" + lang
+}
+
+// Translation is a summary translated into another language.
+type Translation struct {
+	ShortSummary  string `json:"short_summary"`
+	DetailSummary string `json:"detail_summary"`
+
+	// Set when the detail summary was offloaded to the blob store
+	DetailSummaryRef string `json:"-"`
+
+	TranslatedAt string    `json:"-"`
+	Usage        llm.Usage `json:"-"`
+	CostUSD      float64   `json:"-"`
+}
+
+// Translate translates a short and a detail summary into lang. The detail
+// summary keeps its Markdown and [m:ss] timestamps.
+func Translate(ctx context.Context, client *llm.Client, lang, shortSummary, detailSummary string) (*Translation, error) {
+	name, ok := Languages[lang]
+	if !ok || lang == Source {
+		return nil, fmt.Errorf("cannot translate into %q", lang)
+	}
+
+	prompt := fmt.Sprintf(`Translate the following two Japanese summaries of a YouTube video into %s.
+Keep the Markdown formatting and keep timestamps such as [12:34] exactly as they are.
+
+short_summary:
+%s
+
+detail_summary:
+%s
+
+Output only this JSON:
+{
+  "short_summary": "...",
+  "detail_summary": "..."
+}`, name, shortSummary, detailSummary)
+
+	resp, err := client.Invoke(ctx, prompt, 8192)
+	if err != nil {
+		return nil, err
+	}
+
+	responseText := llm.StripCodeFence(resp.Text)
+	var t Translation
+	if err := json.Unmarshal([]byte(responseText), &t); err != nil {
+		return nil, fmt.Errorf("failed to parse translation json: %w. Response: %s", err, responseText)
+	}
+	t.TranslatedAt = time.Now().UTC().Format(time.RFC3339)
+	t.Usage = resp.Usage
+	t.CostUSD = resp.CostUSD
+	return &t, nil
+}
+
+// Attribute converts t to the map stored under AttributeName.
+func (t *Translation) Attribute() types.AttributeValue {
+	m := map[string]types.AttributeValue{
+		"summary":      &types.AttributeValueMemberS{Value: t.ShortSummary},
+		"translatedAt": &types.AttributeValueMemberS{Value: t.TranslatedAt},
+	}
+	if t.DetailSummaryRef != "" {
+		m["detailSummaryRef"] = &types.AttributeValueMemberS{Value: t.DetailSummaryRef}
+	} else {
+		m["detailSummary"] = &types.AttributeValueMemberS{Value: t.DetailSummary}
+	}
+	return &types.AttributeValueMemberM{Value: m}
+}
+
+// FromItem returns the translation of item into lang, if it has one. An
+// offloaded detail summary is left to the caller to load.
+func FromItem(item map[string]types.AttributeValue, lang string) (*Translation, bool) {
+	m, ok := item[AttributeName(lang)].(*types.AttributeValueMemberM)
+	if !ok {
+		return nil, false
+	}
+	t := &Translation{}
+	if v, ok := m.Value["summary"].(*types.AttributeValueMemberS); ok {
+		t.ShortSummary = v.Value
+	}
+	if v, ok := m.Value["detailSummary"].(*types.AttributeValueMemberS); ok {
+		t.DetailSummary = v.Value
+	}
+	if v, ok := m.Value["detailSummaryRef"].(*types.AttributeValueMemberS); ok {
+		t.DetailSummaryRef = v.Value
+	}
+	if v, ok := m.Value["translatedAt"].(*types.AttributeValueMemberS); ok {
+		t.TranslatedAt = v.Value
+	}
+	return t, true
+}
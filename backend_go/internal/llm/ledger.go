@@ -0,0 +1,128 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ledgerPartition is the "hashtag" value under which daily costs are stored.
+// Each item is keyed by "<YYYY-MM-DD>#<channelID>" (UTC).
+const ledgerPartition = "costs"
+
+// DailyCost is the LLM spend of one channel on one day.
+type DailyCost struct {
+	Date         string  `json:"date"`
+	ChannelID    string  `json:"channelId"`
+	InputTokens  int64   `json:"inputTokens"`
+	OutputTokens int64   `json:"outputTokens"`
+	CostUSD      float64 `json:"costUsd"`
+	Calls        int64   `json:"calls"`
+}
+
+// Ledger keeps per-day, per-channel LLM usage totals in DynamoDB.
+type Ledger struct {
+	client *dynamodb.Client
+	table  string
+}
+
+// NewLedger returns a Ledger backed by table.
+func NewLedger(client *dynamodb.Client, table string) *Ledger {
+	return &Ledger{client: client, table: table}
+}
+
+// Record adds one invocation's usage to today's total for channelID.
+func (l *Ledger) Record(ctx context.Context, channelID string, u Usage, costUSD float64) error {
+	day := time.Now().UTC().Format("2006-01-02")
+	_, err := l.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(l.table),
+		Key: map[string]types.AttributeValue{
+			"hashtag":     &types.AttributeValueMemberS{Value: ledgerPartition},
+			"processedAt": &types.AttributeValueMemberS{Value: day + "#" + channelID},
+		},
+		UpdateExpression: aws.String("ADD inputTokens :in, outputTokens :out, costUsd :cost, calls :one"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":in":   &types.AttributeValueMemberN{Value: strconv.FormatInt(u.InputTokens, 10)},
+			":out":  &types.AttributeValueMemberN{Value: strconv.FormatInt(u.OutputTokens, 10)},
+			":cost": &types.AttributeValueMemberN{Value: strconv.FormatFloat(costUSD, 'f', -1, 64)},
+			":one":  &types.AttributeValueMemberN{Value: "1"},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record llm usage: %w", err)
+	}
+	return nil
+}
+
+// Range returns the daily totals for dates from..to inclusive (YYYY-MM-DD).
+func (l *Ledger) Range(ctx context.Context, from, to string) ([]DailyCost, error) {
+	costs := []DailyCost{}
+	var lastEvaluatedKey map[string]types.AttributeValue
+
+	for {
+		resp, err := l.client.Query(ctx, &dynamodb.QueryInput{
+			TableName:              aws.String(l.table),
+			KeyConditionExpression: aws.String("hashtag = :p AND processedAt BETWEEN :from AND :to"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":p":    &types.AttributeValueMemberS{Value: ledgerPartition},
+				":from": &types.AttributeValueMemberS{Value: from},
+				// "~" sorts after "#", so this includes every channel on the last day
+				":to": &types.AttributeValueMemberS{Value: to + "~"},
+			},
+			ExclusiveStartKey: lastEvaluatedKey,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to query llm usage: %w", err)
+		}
+
+		for _, item := range resp.Items {
+			sk, _ := item["processedAt"].(*types.AttributeValueMemberS)
+			if sk == nil {
+				continue
+			}
+			date, channelID, _ := strings.Cut(sk.Value, "#")
+			costs = append(costs, DailyCost{
+				Date:         date,
+				ChannelID:    channelID,
+				InputTokens:  int64(numberAttr(item, "inputTokens")),
+				OutputTokens: int64(numberAttr(item, "outputTokens")),
+				CostUSD:      numberAttr(item, "costUsd"),
+				Calls:        int64(numberAttr(item, "calls")),
+			})
+		}
+
+		if resp.LastEvaluatedKey == nil {
+			break
+		}
+		lastEvaluatedKey = resp.LastEvaluatedKey
+	}
+	return costs, nil
+}
+
+// MonthToDate returns the total spend of the current UTC month.
+func (l *Ledger) MonthToDate(ctx context.Context) (float64, error) {
+	now := time.Now().UTC()
+	costs, err := l.Range(ctx, now.Format("2006-01")+"-01", now.Format("2006-01-02"))
+	if err != nil {
+		return 0, err
+	}
+	var total float64
+	for _, c := range costs {
+		total += c.CostUSD
+	}
+	return total, nil
+}
+
+func numberAttr(item map[string]types.AttributeValue, name string) float64 {
+	if v, ok := item[name].(*types.AttributeValueMemberN); ok {
+		f, _ := strconv.ParseFloat(v.Value, 64)
+		return f
+	}
+	return 0
+}
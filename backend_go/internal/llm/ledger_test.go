@@ -0,0 +1,108 @@
+package llm
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ttakahashi/youtube-summary/internal/dynamotest"
+)
+
+func TestLedgerRecord(t *testing.T) {
+	client, srv := dynamotest.NewClient(t, func(call dynamotest.Call) (interface{}, string) {
+		return nil, ""
+	})
+	l := NewLedger(client, "table")
+	if err := l.Record(context.Background(), "UC123", Usage{InputTokens: 1200, OutputTokens: 300}, 0.0027); err != nil {
+		t.Fatal(err)
+	}
+
+	call := srv.Calls()[0]
+	hashtag, sk := call.Key()
+	wantSK := time.Now().UTC().Format("2006-01-02") + "#UC123"
+	if call.Operation != "UpdateItem" || hashtag != "costs" || sk != wantSK {
+		t.Errorf("recorded with %s %s/%s, want UpdateItem costs/%s", call.Operation, hashtag, sk, wantSK)
+	}
+	values := call.Values()
+	for name, want := range map[string]string{":in": "1200", ":out": "300", ":cost": "0.0027", ":one": "1"} {
+		if values[name] != want {
+			t.Errorf("%s = %q, want %q", name, values[name], want)
+		}
+	}
+}
+
+func TestLedgerRange(t *testing.T) {
+	pages := []map[string]interface{}{
+		{
+			"Items": []interface{}{
+				map[string]interface{}{
+					"processedAt":  dynamotest.S("2025-03-01#UC1"),
+					"inputTokens":  dynamotest.N("1000"),
+					"outputTokens": dynamotest.N("200"),
+					"costUsd":      dynamotest.N("0.002"),
+					"calls":        dynamotest.N("2"),
+				},
+			},
+			"LastEvaluatedKey": map[string]interface{}{"hashtag": dynamotest.S("costs"), "processedAt": dynamotest.S("2025-03-01#UC1")},
+		},
+		{
+			"Items": []interface{}{
+				map[string]interface{}{
+					"processedAt": dynamotest.S("2025-03-02#UC2"),
+					"costUsd":     dynamotest.N("0.5"),
+				},
+			},
+		},
+	}
+	client, srv := dynamotest.NewClient(t, func(call dynamotest.Call) (interface{}, string) {
+		page := pages[0]
+		pages = pages[1:]
+		return page, ""
+	})
+
+	costs, err := NewLedger(client, "table").Range(context.Background(), "2025-03-01", "2025-03-02")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []DailyCost{
+		{Date: "2025-03-01", ChannelID: "UC1", InputTokens: 1000, OutputTokens: 200, CostUSD: 0.002, Calls: 2},
+		{Date: "2025-03-02", ChannelID: "UC2", CostUSD: 0.5},
+	}
+	if len(costs) != len(want) {
+		t.Fatalf("Range = %+v, want %+v", costs, want)
+	}
+	for i := range want {
+		if costs[i] != want[i] {
+			t.Errorf("Range[%d] = %+v, want %+v", i, costs[i], want[i])
+		}
+	}
+
+	calls := srv.Calls()
+	if len(calls) != 2 {
+		t.Fatalf("%d queries, want one per page", len(calls))
+	}
+	if to := calls[0].Values()[":to"]; !strings.HasPrefix(to, "2025-03-02") || to <= "2025-03-02#UC2" {
+		t.Errorf(":to = %q, want it past every channel of the last day", to)
+	}
+}
+
+func TestLedgerMonthToDate(t *testing.T) {
+	client, srv := dynamotest.NewClient(t, func(call dynamotest.Call) (interface{}, string) {
+		return map[string]interface{}{"Items": []interface{}{
+			map[string]interface{}{"processedAt": dynamotest.S("x#UC1"), "costUsd": dynamotest.N("1.25")},
+			map[string]interface{}{"processedAt": dynamotest.S("x#UC2"), "costUsd": dynamotest.N("0.5")},
+		}}, ""
+	})
+	total, err := NewLedger(client, "table").MonthToDate(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(total-1.75) > 1e-9 {
+		t.Errorf("MonthToDate = %v, want 1.75", total)
+	}
+	if from := srv.Calls()[0].Values()[":from"]; from != time.Now().UTC().Format("2006-01")+"-01" {
+		t.Errorf(":from = %q, want the first of this month", from)
+	}
+}
@@ -0,0 +1,116 @@
+// Package llm invokes Claude on Bedrock and accounts for the tokens it uses.
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+)
+
+// DefaultModelID is the inference profile used when none is configured. The
+// bare profile ID resolves in whatever account and region the client uses.
+const DefaultModelID = "global.anthropic.claude-haiku-4-5-20251001-v1:0"
+
+// Usage is the token usage reported by a single model invocation.
+type Usage struct {
+	InputTokens  int64 `json:"input_tokens"`
+	OutputTokens int64 `json:"output_tokens"`
+}
+
+// Add accumulates u2 into u.
+func (u *Usage) Add(u2 Usage) {
+	u.InputTokens += u2.InputTokens
+	u.OutputTokens += u2.OutputTokens
+}
+
+// Response is the text of a model reply along with what it cost.
+type Response struct {
+	Text    string
+	Usage   Usage
+	CostUSD float64
+}
+
+// Client invokes a Claude model through the Bedrock Messages API.
+type Client struct {
+	bedrock *bedrockruntime.Client
+	modelID string
+	prices  PriceTable
+}
+
+// NewClient returns a Client for modelID, priced with prices.
+func NewClient(bedrock *bedrockruntime.Client, modelID string, prices PriceTable) *Client {
+	if modelID == "" {
+		modelID = DefaultModelID
+	}
+	if prices == nil {
+		prices = DefaultPrices
+	}
+	return &Client{bedrock: bedrock, modelID: modelID, prices: prices}
+}
+
+// ModelID returns the model the client invokes.
+func (c *Client) ModelID() string {
+	return c.modelID
+}
+
+// Invoke sends a single user prompt and returns the first content block.
+func (c *Client) Invoke(ctx context.Context, prompt string, maxTokens int) (*Response, error) {
+	// Claude Messages API request body
+	reqBody := map[string]interface{}{
+		"anthropic_version": "bedrock-2023-05-31",
+		"max_tokens":        maxTokens,
+		"messages": []map[string]string{
+			{"role": "user", "content": prompt},
+		},
+	}
+
+	reqJSON, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	resp, err := c.bedrock.InvokeModel(ctx, &bedrockruntime.InvokeModelInput{
+		ModelId:     aws.String(c.modelID),
+		ContentType: aws.String("application/json"),
+		Body:        reqJSON,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("bedrock invoke failed: %w", err)
+	}
+
+	// Parse response from Bedrock
+	var result struct {
+		Content []struct {
+			Text string `json:"text"`
+		} `json:"content"`
+		Usage Usage `json:"usage"`
+	}
+	if err := json.Unmarshal(resp.Body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse bedrock response: %w", err)
+	}
+
+	if len(result.Content) == 0 {
+		return nil, fmt.Errorf("no content in response")
+	}
+
+	return &Response{
+		Text:    result.Content[0].Text,
+		Usage:   result.Usage,
+		CostUSD: c.prices.Cost(c.modelID, result.Usage),
+	}, nil
+}
+
+// StripCodeFence removes the ```json fences Claude sometimes wraps JSON in.
+func StripCodeFence(text string) string {
+	text = strings.TrimSpace(text)
+	if strings.Contains(text, "```") {
+		text = strings.ReplaceAll(text, "```json", "")
+		text = strings.ReplaceAll(text, "```", "")
+		text = strings.TrimSpace(text)
+	}
+	return text
+}
@@ -0,0 +1,61 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/ttakahashi/youtube-summary/internal/dynamotest"
+)
+
+func TestClientInvoke(t *testing.T) {
+	var gotPath string
+	var gotBody map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&gotBody)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"content": [{"type": "text", "text": "こんにちは"}], "usage": {"input_tokens": 2000, "output_tokens": 1000}}`))
+	}))
+	defer srv.Close()
+
+	bedrock := bedrockruntime.New(bedrockruntime.Options{
+		Region:           "us-east-1",
+		BaseEndpoint:     aws.String(srv.URL),
+		Credentials:      dynamotest.TestCredentials,
+		RetryMaxAttempts: 1,
+	})
+	c := NewClient(bedrock, "", nil)
+	resp, err := c.Invoke(context.Background(), "hello", 256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(gotPath, DefaultModelID) {
+		t.Errorf("invoked %s, want the default model", gotPath)
+	}
+	if gotBody["max_tokens"] != 256.0 {
+		t.Errorf("max_tokens = %v, want 256", gotBody["max_tokens"])
+	}
+	if resp.Text != "こんにちは" || resp.Usage != (Usage{InputTokens: 2000, OutputTokens: 1000}) {
+		t.Errorf("Invoke = %+v", resp)
+	}
+	// Haiku 4.5: $1 in, $5 out per million tokens
+	if math.Abs(resp.CostUSD-0.007) > 1e-9 {
+		t.Errorf("CostUSD = %v, want 0.007", resp.CostUSD)
+	}
+}
+
+func TestUsageAdd(t *testing.T) {
+	u := Usage{InputTokens: 1, OutputTokens: 2}
+	u.Add(Usage{InputTokens: 10, OutputTokens: 20})
+	if u != (Usage{InputTokens: 11, OutputTokens: 22}) {
+		t.Errorf("Add = %+v", u)
+	}
+}
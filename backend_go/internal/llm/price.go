@@ -0,0 +1,55 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Price is the on-demand price of a model in USD per million tokens.
+type Price struct {
+	InputPerMTok  float64 `json:"input"`
+	OutputPerMTok float64 `json:"output"`
+}
+
+// PriceTable maps a model name fragment to its price. A model ID is priced
+// by the longest key it contains, so the same entry covers plain model IDs,
+// regional inference profiles and their ARNs.
+type PriceTable map[string]Price
+
+// DefaultPrices holds Bedrock list prices for the models we have used.
+var DefaultPrices = PriceTable{
+	"claude-haiku-4-5":  {InputPerMTok: 1.00, OutputPerMTok: 5.00},
+	"claude-sonnet-4-5": {InputPerMTok: 3.00, OutputPerMTok: 15.00},
+	"claude-3-5-haiku":  {InputPerMTok: 0.80, OutputPerMTok: 4.00},
+}
+
+// ParsePriceTable parses a JSON object such as
+// {"claude-haiku-4-5": {"input": 1.0, "output": 5.0}} and layers it over
+// DefaultPrices.
+func ParsePriceTable(s string) (PriceTable, error) {
+	var overrides PriceTable
+	if err := json.Unmarshal([]byte(s), &overrides); err != nil {
+		return nil, fmt.Errorf("invalid price table: %w", err)
+	}
+	table := PriceTable{}
+	for k, v := range DefaultPrices {
+		table[k] = v
+	}
+	for k, v := range overrides {
+		table[k] = v
+	}
+	return table, nil
+}
+
+// Cost estimates the USD cost of usage on modelID. Unknown models cost 0.
+func (t PriceTable) Cost(modelID string, u Usage) float64 {
+	var price Price
+	matched := ""
+	for k, v := range t {
+		if strings.Contains(modelID, k) && len(k) > len(matched) {
+			matched, price = k, v
+		}
+	}
+	return (float64(u.InputTokens)*price.InputPerMTok + float64(u.OutputTokens)*price.OutputPerMTok) / 1e6
+}
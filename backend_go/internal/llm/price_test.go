@@ -0,0 +1,85 @@
+package llm
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPriceTableCost(t *testing.T) {
+	table := PriceTable{
+		"claude-haiku-4-5": {InputPerMTok: 1, OutputPerMTok: 5},
+		"claude":           {InputPerMTok: 100, OutputPerMTok: 100},
+	}
+	tests := []struct {
+		modelID string
+		usage   Usage
+		want    float64
+	}{
+		{"anthropic.claude-haiku-4-5-20251001-v1:0", Usage{InputTokens: 1_000_000}, 1},
+		{"global.anthropic.claude-haiku-4-5-20251001-v1:0", Usage{InputTokens: 2000, OutputTokens: 1000}, 0.007},
+		{"arn:aws:bedrock:us-east-1:123456789012:inference-profile/us.anthropic.claude-haiku-4-5-20251001-v1:0", Usage{OutputTokens: 200_000}, 1},
+		{"anthropic.claude-opus-4", Usage{InputTokens: 10_000}, 1}, // only the shorter key matches
+		{"amazon.nova-pro-v1:0", Usage{InputTokens: 1_000_000}, 0},
+	}
+	for _, tt := range tests {
+		if got := table.Cost(tt.modelID, tt.usage); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Cost(%q, %+v) = %v, want %v", tt.modelID, tt.usage, got, tt.want)
+		}
+	}
+}
+
+func TestParsePriceTable(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		check   map[string]Price
+		wantErr bool
+	}{
+		{
+			name:  "layers over the defaults",
+			in:    `{"claude-haiku-4-5": {"input": 2, "output": 10}, "nova-pro": {"input": 0.8, "output": 3.2}}`,
+			check: map[string]Price{"claude-haiku-4-5": {2, 10}, "nova-pro": {0.8, 3.2}, "claude-sonnet-4-5": DefaultPrices["claude-sonnet-4-5"]},
+		},
+		{
+			name:    "not JSON",
+			in:      `haiku=1`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table, err := ParsePriceTable(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParsePriceTable(%q) succeeded, want an error", tt.in)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for k, want := range tt.check {
+				if table[k] != want {
+					t.Errorf("table[%q] = %+v, want %+v", k, table[k], want)
+				}
+			}
+		})
+	}
+	if DefaultPrices["claude-haiku-4-5"] != (Price{InputPerMTok: 1, OutputPerMTok: 5}) {
+		t.Error("ParsePriceTable changed DefaultPrices")
+	}
+}
+
+func TestStripCodeFence(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{`{"a": 1}`, `{"a": 1}`},
+		{"```json\n{\"a\": 1}\n```", `{"a": 1}`},
+		{"```\n{\"a\": 1}\n```\n", `{"a": 1}`},
+		{"  {\"a\": 1}  \n", `{"a": 1}`},
+	}
+	for _, tt := range tests {
+		if got := StripCodeFence(tt.in); got != tt.want {
+			t.Errorf("StripCodeFence(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
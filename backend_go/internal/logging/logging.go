@@ -0,0 +1,96 @@
+// Package logging sets up JSON logging with log/slog. Identifiers such as
+// the run and video being processed travel in the context and are added to
+// every record logged with it, so CloudWatch Logs Insights can filter on
+// them.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Keys of the correlation attributes.
+const (
+	KeyRunID     = "runId"     // one batch invocation
+	KeyRequestID = "requestId" // one API request, from API Gateway
+	KeyChannelID = "channelId"
+	KeyVideoID   = "videoId"
+	KeyStage     = "stage" // step of the pipeline, such as "transcript"
+)
+
+type ctxKey struct{}
+
+// With returns a copy of ctx whose records also carry args, given as
+// alternating keys and values like slog.Logger.With. A key set again
+// replaces the earlier value.
+func With(ctx context.Context, args ...any) context.Context {
+	prev, _ := ctx.Value(ctxKey{}).([]slog.Attr)
+	next := slog.Group("", args...).Value.Group()
+	attrs := make([]slog.Attr, 0, len(prev)+len(next))
+	for _, a := range prev {
+		replaced := false
+		for _, b := range next {
+			if a.Key == b.Key {
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			attrs = append(attrs, a)
+		}
+	}
+	return context.WithValue(ctx, ctxKey{}, append(attrs, next...))
+}
+
+// Stage returns a copy of ctx logging stage as the current step.
+func Stage(ctx context.Context, stage string) context.Context {
+	return With(ctx, KeyStage, stage)
+}
+
+// NewRunID returns a random ID for runs that get none from Lambda.
+func NewRunID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// ParseLevel parses debug, info, warn or error.
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(s))); err != nil {
+		return 0, fmt.Errorf("invalid log level %q", s)
+	}
+	return level, nil
+}
+
+// Setup makes a JSON logger writing to w at level the default. Lines still
+// written with the log package come out as JSON records at info level.
+func Setup(w io.Writer, level slog.Level) {
+	h := slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})
+	slog.SetDefault(slog.New(contextHandler{h}))
+}
+
+// contextHandler adds the attributes in the context to each record.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if attrs, ok := ctx.Value(ctxKey{}).([]slog.Attr); ok {
+		r.AddAttrs(attrs...)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
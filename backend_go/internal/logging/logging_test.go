@@ -0,0 +1,124 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"reflect"
+	"testing"
+)
+
+// record logs msg with ctx through a contextHandler and returns the JSON
+// record written.
+func record(t *testing.T, ctx context.Context, logger func(*slog.Logger) *slog.Logger) map[string]interface{} {
+	t.Helper()
+	var buf bytes.Buffer
+	l := slog.New(contextHandler{slog.NewJSONHandler(&buf, nil)})
+	if logger != nil {
+		l = logger(l)
+	}
+	l.InfoContext(ctx, "msg")
+	var rec map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("invalid record %s: %v", buf.Bytes(), err)
+	}
+	delete(rec, "time")
+	delete(rec, "level")
+	delete(rec, "msg")
+	return rec
+}
+
+func TestContextHandler(t *testing.T) {
+	ctx := With(context.Background(), KeyRunID, "run1", KeyChannelID, "UC1")
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		logger func(*slog.Logger) *slog.Logger
+		want   map[string]interface{}
+	}{
+		{
+			name: "no attributes",
+			ctx:  context.Background(),
+			want: map[string]interface{}{},
+		},
+		{
+			name: "context attributes",
+			ctx:  ctx,
+			want: map[string]interface{}{"runId": "run1", "channelId": "UC1"},
+		},
+		{
+			name: "stage and video added",
+			ctx:  Stage(With(ctx, KeyVideoID, "v1"), "transcript"),
+			want: map[string]interface{}{"runId": "run1", "channelId": "UC1", "videoId": "v1", "stage": "transcript"},
+		},
+		{
+			name: "key set again replaces",
+			ctx:  Stage(Stage(With(ctx, KeyChannelID, "UC2"), "transcript"), "summary"),
+			want: map[string]interface{}{"runId": "run1", "channelId": "UC2", "stage": "summary"},
+		},
+		{
+			name:   "logger attributes kept",
+			ctx:    ctx,
+			logger: func(l *slog.Logger) *slog.Logger { return l.With("component", "batch") },
+			want:   map[string]interface{}{"component": "batch", "runId": "run1", "channelId": "UC1"},
+		},
+		{
+			name:   "logger group",
+			ctx:    ctx,
+			logger: func(l *slog.Logger) *slog.Logger { return l.WithGroup("g") },
+			want:   map[string]interface{}{"g": map[string]interface{}{"runId": "run1", "channelId": "UC1"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := record(t, tt.ctx, tt.logger); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("record = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithDoesNotShareAttributes(t *testing.T) {
+	parent := With(context.Background(), KeyRunID, "run1")
+	a := With(parent, KeyVideoID, "a")
+	b := With(parent, KeyVideoID, "b")
+	if got := record(t, a, nil)[KeyVideoID]; got != "a" {
+		t.Errorf("videoId of the first child = %v, want a", got)
+	}
+	if got := record(t, b, nil)[KeyVideoID]; got != "b" {
+		t.Errorf("videoId of the second child = %v, want b", got)
+	}
+	if _, ok := record(t, parent, nil)[KeyVideoID]; ok {
+		t.Error("the parent context logs the children's videoId")
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    slog.Level
+		wantErr bool
+	}{
+		{s: "debug", want: slog.LevelDebug},
+		{s: " INFO ", want: slog.LevelInfo},
+		{s: "warn", want: slog.LevelWarn},
+		{s: "error", want: slog.LevelError},
+		{s: "verbose", wantErr: true},
+		{s: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseLevel(tt.s)
+		if tt.wantErr != (err != nil) || got != tt.want {
+			t.Errorf("ParseLevel(%q) = %v, %v", tt.s, got, err)
+		}
+	}
+}
+
+func TestNewRunID(t *testing.T) {
+	a, b := NewRunID(), NewRunID()
+	if len(a) != 16 || a == b {
+		t.Errorf("NewRunID = %q, %q; want distinct 16 hex digits", a, b)
+	}
+}
@@ -0,0 +1,336 @@
+package mail
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ttakahashi/youtube-summary/internal/store"
+)
+
+// parse reads a built message and returns its header and its parts by
+// content type, with their transfer encoding undone.
+func parse(t *testing.T, data []byte) (mail.Header, map[string]string) {
+	t.Helper()
+	m, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	mediaType, params, err := mime.ParseMediaType(m.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q, want multipart/alternative", m.Header.Get("Content-Type"))
+	}
+	parts := map[string]string{}
+	r := multipart.NewReader(m.Body, params["boundary"])
+	for {
+		p, err := r.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("NextPart: %v", err)
+		}
+		content, err := io.ReadAll(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts[p.Header.Get("Content-Type")] = string(content)
+	}
+	return m.Header, parts
+}
+
+func TestBuild(t *testing.T) {
+	s, err := NewSender(Config{Host: "smtp.example.com", From: "YouTube要約 <noreply@example.com>"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	long := strings.Repeat("とても長い要約の行です。", 20) // over the 76 characters of a quoted-printable line
+	msg := &Message{
+		To:             "user@example.com",
+		Subject:        "[チャンネル] 新しい動画",
+		Text:           "要約\n\n" + long + "\n",
+		HTML:           `<p class="summary">要約 &amp; <a href="https://example.com/?a=1&amp;b=2">link</a></p>`,
+		UnsubscribeURL: "https://api.example.com/api/unsubscribe?token=abc",
+	}
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	data, err := s.build(msg, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header, parts := parse(t, data)
+	raw := header.Get("Subject")
+	if !strings.HasPrefix(raw, "=?UTF-8?q?") {
+		t.Errorf("Subject = %q, want Q-encoded", raw)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(raw)
+	if err != nil || subject != msg.Subject {
+		t.Errorf("decoded Subject = %q, %v; want %q", subject, err, msg.Subject)
+	}
+	for name, want := range map[string]string{
+		"From":                  "YouTube要約 <noreply@example.com>",
+		"To":                    "user@example.com",
+		"Date":                  "Thu, 02 Jan 2025 03:04:05 +0000",
+		"MIME-Version":          "1.0",
+		"List-Unsubscribe":      "<https://api.example.com/api/unsubscribe?token=abc>",
+		"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+	} {
+		if got := header.Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	if id := header.Get("Message-ID"); !strings.HasPrefix(id, "<") || !strings.HasSuffix(id, "@smtp.example.com>") {
+		t.Errorf("Message-ID = %q", id)
+	}
+
+	if len(parts) != 2 {
+		t.Errorf("parts = %v, want text and HTML", parts)
+	}
+	// Quoted-printable text has canonical line breaks
+	if got, want := parts["text/plain; charset=UTF-8"], strings.ReplaceAll(msg.Text, "\n", "\r\n"); got != want {
+		t.Errorf("text part = %q, want %q", got, want)
+	}
+	if got := parts["text/html; charset=UTF-8"]; got != msg.HTML {
+		t.Errorf("HTML part = %q, want %q", got, msg.HTML)
+	}
+	_, body, _ := strings.Cut(string(data), "\r\n\r\n")
+	for _, line := range strings.Split(body, "\r\n") {
+		if len(line) > 76 {
+			t.Errorf("line of %d characters: %q", len(line), line)
+		}
+	}
+}
+
+func TestBuildWithoutUnsubscribe(t *testing.T) {
+	s, err := NewSender(Config{Host: "localhost", From: "noreply@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := s.build(&Message{To: "user@example.com", Subject: "ascii", Text: "a", HTML: "<p>a</p>"}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, _ := parse(t, data)
+	if header.Get("Subject") != "ascii" {
+		t.Errorf("Subject = %q, want it unencoded", header.Get("Subject"))
+	}
+	if _, ok := header["List-Unsubscribe"]; ok {
+		t.Error("List-Unsubscribe set without an unsubscribe URL")
+	}
+}
+
+func TestNewSender(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   Config
+		wantPort int
+		wantErr  bool
+	}{
+		{name: "default port", config: Config{Host: "smtp.example.com", From: "noreply@example.com"}, wantPort: 587},
+		{name: "port", config: Config{Host: "localhost", Port: 1025, From: "noreply@example.com"}, wantPort: 1025},
+		{name: "no host", config: Config{From: "noreply@example.com"}, wantErr: true},
+		{name: "bad sender", config: Config{Host: "localhost", From: "noreply"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewSender(tt.config)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("NewSender succeeded, want an error")
+				}
+				return
+			}
+			if err != nil || s.config.Port != tt.wantPort {
+				t.Errorf("NewSender = %+v, %v; want port %d", s, err, tt.wantPort)
+			}
+		})
+	}
+}
+
+// smtpSession is what the fake SMTP server was told.
+type smtpSession struct {
+	auth string
+	from string
+	to   []string
+	data string
+}
+
+// serveSMTP accepts one SMTP session on l, answering as a minimal server
+// that offers PLAIN authentication, and sends what it was told to done.
+func serveSMTP(t *testing.T, l net.Listener, done chan<- smtpSession) {
+	conn, err := l.Accept()
+	if err != nil {
+		t.Error(err)
+		close(done)
+		return
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(s string) { io.WriteString(conn, s+"\r\n") }
+
+	var s smtpSession
+	reply("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Errorf("SMTP session ended early: %v", err)
+			done <- s
+			return
+		}
+		cmd := strings.TrimRight(line, "\r\n")
+		switch verb, arg, _ := strings.Cut(cmd, " "); strings.ToUpper(verb) {
+		case "EHLO":
+			reply("250-localhost")
+			reply("250 AUTH PLAIN")
+		case "AUTH":
+			if b64, ok := strings.CutPrefix(arg, "PLAIN "); ok {
+				decoded, _ := base64.StdEncoding.DecodeString(b64)
+				s.auth = string(decoded)
+			}
+			reply("235 2.7.0 Authentication successful")
+		case "MAIL":
+			s.from = arg
+			reply("250 OK")
+		case "RCPT":
+			s.to = append(s.to, arg)
+			reply("250 OK")
+		case "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil || l == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(l, "."))
+			}
+			s.data = data.String()
+			reply("250 OK")
+		case "QUIT":
+			reply("221 Bye")
+			done <- s
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+func TestSend(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		wantAuth string
+	}{
+		{name: "unauthenticated"},
+		{name: "authenticated", username: "user", wantAuth: "\x00user\x00pass"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer l.Close()
+			done := make(chan smtpSession, 1)
+			go serveSMTP(t, l, done)
+
+			addr := l.Addr().(*net.TCPAddr)
+			s, err := NewSender(Config{Host: "127.0.0.1", Port: addr.Port, Username: tt.username, Password: "pass", From: "YouTube要約 <noreply@example.com>"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			msg := &Message{To: "user@example.com", Subject: "件名", Text: "本文", HTML: "<p>本文</p>"}
+			if err := s.Send(msg); err != nil {
+				t.Fatalf("Send: %v", err)
+			}
+
+			session := <-done
+			if session.auth != tt.wantAuth {
+				t.Errorf("auth = %q, want %q", session.auth, tt.wantAuth)
+			}
+			if session.from != "FROM:<noreply@example.com>" {
+				t.Errorf("MAIL %s, want the bare sender address", session.from)
+			}
+			if len(session.to) != 1 || session.to[0] != "TO:<user@example.com>" {
+				t.Errorf("RCPT %v, want user@example.com", session.to)
+			}
+			header, parts := parse(t, []byte(session.data))
+			if subject, _ := new(mime.WordDecoder).DecodeHeader(header.Get("Subject")); subject != "件名" {
+				t.Errorf("Subject = %q, want 件名", subject)
+			}
+			if parts["text/plain; charset=UTF-8"] != "本文" {
+				t.Errorf("parts = %v", parts)
+			}
+		})
+	}
+}
+
+func TestSendRefused(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	s, err := NewSender(Config{Host: "127.0.0.1", Port: port, From: "noreply@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Send(&Message{To: "user@example.com"}); err == nil || !strings.Contains(err.Error(), "user@example.com") {
+		t.Errorf("Send = %v, want an error naming the recipient", err)
+	}
+}
+
+func TestSummaryMessage(t *testing.T) {
+	s := &Summary{
+		VideoID:       "abcdefghijk",
+		Title:         `<script>alert("x")</script>`,
+		ChannelTitle:  "チャンネル",
+		URL:           "https://www.youtube.com/watch?v=abcdefghijk",
+		ShortSummary:  "短い要約",
+		DetailSummary: "一段落目\n\n二段落目",
+	}
+	msg, err := SummaryMessage("user@example.com", "https://api.example.com/api/unsubscribe?token=abc", s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `[チャンネル] <script>alert("x")</script>`; msg.Subject != want {
+		t.Errorf("Subject = %q, want %q", msg.Subject, want)
+	}
+	if strings.Contains(msg.HTML, "<script>") {
+		t.Error("HTML part does not escape the title")
+	}
+	if !strings.Contains(msg.HTML, "<p>一段落目</p>") || !strings.Contains(msg.HTML, "<p>二段落目</p>") {
+		t.Errorf("HTML part does not split paragraphs: %s", msg.HTML)
+	}
+	if !strings.Contains(msg.Text, `<script>alert("x")</script>`) || !strings.Contains(msg.Text, "短い要約") {
+		t.Errorf("text part = %s", msg.Text)
+	}
+}
+
+func TestUnsubscribeURL(t *testing.T) {
+	sub := &store.Subscriber{ChannelID: "UCchannel", Email: "a+b@example.com", Token: "tok"}
+	tests := []struct {
+		baseURL string
+		want    string
+	}{
+		{"", ""},
+		{"https://api.example.com/", "https://api.example.com/api/unsubscribe?channelId=UCchannel&email=a%2Bb%40example.com&token=tok"},
+		{"https://api.example.com", "https://api.example.com/api/unsubscribe?channelId=UCchannel&email=a%2Bb%40example.com&token=tok"},
+	}
+	for _, tt := range tests {
+		if got := UnsubscribeURL(tt.baseURL, sub); got != tt.want {
+			t.Errorf("UnsubscribeURL(%q) = %q, want %q", tt.baseURL, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package mail
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/ttakahashi/youtube-summary/internal/store"
+)
+
+// Summary is a newly summarized video.
+type Summary struct {
+	VideoID       string
+	Title         string
+	ChannelTitle  string
+	URL           string
+	ThumbnailURL  string
+	PublishedAt   string
+	ShortSummary  string
+	DetailSummary string
+}
+
+// render executes the HTML and text templates called name with data.
+func render(name string, data interface{}) (html, text string, err error) {
+	var h, t bytes.Buffer
+	if err := htmlTemplates.ExecuteTemplate(&h, name+".html", data); err != nil {
+		return "", "", fmt.Errorf("failed to render %s.html: %w", name, err)
+	}
+	if err := textTemplates.ExecuteTemplate(&t, name+".txt", data); err != nil {
+		return "", "", fmt.Errorf("failed to render %s.txt: %w", name, err)
+	}
+	return h.String(), t.String(), nil
+}
+
+// SummaryMessage renders the email announcing s to the subscriber at to.
+func SummaryMessage(to, unsubscribeURL string, s *Summary) (*Message, error) {
+	html, text, err := render("summary", map[string]interface{}{
+		"Summary":        s,
+		"UnsubscribeURL": unsubscribeURL,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Message{
+		To:             to,
+		Subject:        fmt.Sprintf("[%s] %s", s.ChannelTitle, s.Title),
+		Text:           text,
+		HTML:           html,
+		UnsubscribeURL: unsubscribeURL,
+	}, nil
+}
+
+// DigestMessage renders the email carrying d to the subscriber at to.
+func DigestMessage(to, unsubscribeURL string, d *store.Digest) (*Message, error) {
+	label := "デイリー"
+	if d.Period == store.DigestWeekly {
+		label = "ウィークリー"
+	}
+	html, text, err := render("digest", map[string]interface{}{
+		"Digest":         d,
+		"Label":          label,
+		"UnsubscribeURL": unsubscribeURL,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Message{
+		To:             to,
+		Subject:        fmt.Sprintf("%sダイジェスト %s: %s", label, d.Start, d.Headline),
+		Text:           text,
+		HTML:           html,
+		UnsubscribeURL: unsubscribeURL,
+	}, nil
+}
+
+// UnsubscribeURL returns the link that removes s, served by the API at
+// baseURL. It returns "" without a base URL.
+func UnsubscribeURL(baseURL string, s *store.Subscriber) string {
+	if baseURL == "" {
+		return ""
+	}
+	q := url.Values{"channelId": {s.ChannelID}, "email": {s.Email}, "token": {s.Token}}
+	return strings.TrimSuffix(baseURL, "/") + "/api/unsubscribe?" + q.Encode()
+}
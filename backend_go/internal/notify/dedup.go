@@ -0,0 +1,53 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// DynamoDeduper records deliveries in the "notified" partition of the table.
+type DynamoDeduper struct {
+	client *dynamodb.Client
+	table  string
+}
+
+// NewDynamoDeduper returns a deduper that stores its records in table.
+func NewDynamoDeduper(client *dynamodb.Client, table string) *DynamoDeduper {
+	return &DynamoDeduper{client: client, table: table}
+}
+
+func (d *DynamoDeduper) key(key string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"hashtag":     &types.AttributeValueMemberS{Value: "notified"},
+		"processedAt": &types.AttributeValueMemberS{Value: key},
+	}
+}
+
+func (d *DynamoDeduper) Seen(ctx context.Context, key string) (bool, error) {
+	resp, err := d.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(d.table),
+		Key:       d.key(key),
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to get notification record: %w", err)
+	}
+	return resp.Item != nil, nil
+}
+
+func (d *DynamoDeduper) Mark(ctx context.Context, key string) error {
+	item := d.key(key)
+	item["notifiedAt"] = &types.AttributeValueMemberS{Value: time.Now().UTC().Format(time.RFC3339)}
+	_, err := d.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(d.table),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save notification record: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,250 @@
+// Package notify tells chat channels and webhooks about new summaries.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// Target kinds.
+const (
+	KindSlack   = "slack"   // Slack incoming webhook
+	KindDiscord = "discord" // Discord webhook
+	KindWebhook = "webhook" // generic JSON webhook, signed with Secret
+)
+
+// Target is a destination for notifications.
+type Target struct {
+	Name     string   `json:"name"` // identifies the target in logs and deduplication
+	Kind     string   `json:"kind"`
+	URL      string   `json:"url"`
+	Secret   string   `json:"secret,omitempty"`   // HMAC key of generic webhooks
+	Channels []string `json:"channels,omitempty"` // YouTube channel IDs; empty means all
+}
+
+// wants reports whether t is routed events of channelID.
+func (t Target) wants(channelID string) bool {
+	return len(t.Channels) == 0 || slices.Contains(t.Channels, channelID)
+}
+
+// ParseTargets parses a JSON array of targets, as set in NOTIFY_TARGETS.
+func ParseTargets(s string) ([]Target, error) {
+	var targets []Target
+	if err := json.Unmarshal([]byte(s), &targets); err != nil {
+		return nil, fmt.Errorf("invalid notification targets: %w", err)
+	}
+	for i, t := range targets {
+		switch t.Kind {
+		case KindSlack, KindDiscord, KindWebhook:
+		default:
+			return nil, fmt.Errorf("target %d: unknown kind %q", i, t.Kind)
+		}
+		if t.URL == "" {
+			return nil, fmt.Errorf("target %d: url is required", i)
+		}
+		if t.Kind == KindWebhook && t.Secret == "" {
+			// Receivers could not tell our requests from forged ones
+			return nil, fmt.Errorf("target %d: secret is required for webhooks", i)
+		}
+		if t.Name == "" {
+			targets[i].Name = fmt.Sprintf("%s-%d", t.Kind, i)
+		}
+	}
+	return targets, nil
+}
+
+// Event is a new summary.
+type Event struct {
+	Type         string `json:"type"` // "summary.created"
+	VideoID      string `json:"videoId"`
+	ChannelID    string `json:"channelId"`
+	ChannelTitle string `json:"channelTitle"`
+	Title        string `json:"title"`
+	Summary      string `json:"summary"`
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnailUrl,omitempty"`
+	PublishedAt  string `json:"publishedAt"`
+}
+
+// EventSummaryCreated is sent once a video has been summarized.
+const EventSummaryCreated = "summary.created"
+
+// Deduper remembers which events were delivered to which target.
+type Deduper interface {
+	Seen(ctx context.Context, key string) (bool, error)
+	Mark(ctx context.Context, key string) error
+}
+
+// Notifier delivers events to the targets routed to their channel.
+type Notifier struct {
+	targets     []Target
+	dedup       Deduper
+	client      *http.Client
+	maxAttempts int
+	backoff     time.Duration // before the first retry; doubled for each further one
+}
+
+// New returns a notifier for targets. dedup may be nil to deliver every
+// event, even one delivered before.
+func New(targets []Target, dedup Deduper) *Notifier {
+	return &Notifier{
+		targets:     targets,
+		dedup:       dedup,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		maxAttempts: 4,
+		backoff:     time.Second,
+	}
+}
+
+// Notify delivers event to every target routed to its channel, retrying
+// failed deliveries. It returns the last delivery error, if any.
+func (n *Notifier) Notify(ctx context.Context, event Event) error {
+	var lastErr error
+	for _, t := range n.targets {
+		if !t.wants(event.ChannelID) {
+			continue
+		}
+		key := t.Name + "#" + event.Type + "#" + event.VideoID
+		if n.dedup != nil {
+			seen, err := n.dedup.Seen(ctx, key)
+			if err != nil {
+				slog.ErrorContext(ctx, "Error checking notification", "key", key, "error", err)
+			} else if seen {
+				continue
+			}
+		}
+
+		if err := n.deliver(ctx, t, event); err != nil {
+			lastErr = fmt.Errorf("failed to notify %s: %w", t.Name, err)
+			slog.ErrorContext(ctx, "Error notifying target", "target", t.Name, "error", err)
+			continue
+		}
+		if n.dedup != nil {
+			if err := n.dedup.Mark(ctx, key); err != nil {
+				slog.ErrorContext(ctx, "Error recording notification", "key", key, "error", err)
+			}
+		}
+	}
+	return lastErr
+}
+
+// deliver posts event to t, retrying with exponential backoff on network
+// errors, 429 and 5xx responses.
+func (n *Notifier) deliver(ctx context.Context, t Target, event Event) error {
+	body, err := payload(t, event)
+	if err != nil {
+		return err
+	}
+
+	backoff := n.backoff
+	var lastErr error
+	for attempt := 1; attempt <= n.maxAttempts; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.URL, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if t.Kind == KindWebhook {
+			sign(req, t.Secret, body, time.Now())
+		}
+
+		resp, err := n.client.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		switch {
+		case resp.StatusCode < 300:
+			return nil
+		case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+			lastErr = fmt.Errorf("status %d", resp.StatusCode)
+			if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && time.Duration(s)*time.Second > backoff {
+				backoff = time.Duration(s) * time.Second
+			}
+		default:
+			// Other client errors will not go away by retrying
+			return fmt.Errorf("status %d", resp.StatusCode)
+		}
+	}
+	return fmt.Errorf("giving up after %d attempts: %w", n.maxAttempts, lastErr)
+}
+
+// sign adds the signature headers of generic webhooks. Receivers verify
+// X-Signature-256 as "sha256=" + hex(HMAC-SHA256(secret, timestamp + "." +
+// body)) and reject stale X-Timestamp values.
+func sign(req *http.Request, secret string, body []byte, now time.Time) {
+	ts := strconv.FormatInt(now.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts + "."))
+	mac.Write(body)
+	req.Header.Set("X-Timestamp", ts)
+	req.Header.Set("X-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+}
+
+// payload renders event in the format t expects.
+func payload(t Target, event Event) ([]byte, error) {
+	switch t.Kind {
+	case KindSlack:
+		text := fmt.Sprintf("*<%s|%s>*\n%s\n%s", event.URL, event.Title, event.ChannelTitle, event.Summary)
+		section := map[string]interface{}{
+			"type": "section",
+			"text": map[string]string{"type": "mrkdwn", "text": truncate(text, 3000)},
+		}
+		if event.ThumbnailURL != "" {
+			section["accessory"] = map[string]string{
+				"type":      "image",
+				"image_url": event.ThumbnailURL,
+				"alt_text":  event.Title,
+			}
+		}
+		// text is the fallback shown in notifications
+		return json.Marshal(map[string]interface{}{
+			"text":   text,
+			"blocks": []interface{}{section},
+		})
+	case KindDiscord:
+		embed := map[string]interface{}{
+			"title":       event.Title,
+			"url":         event.URL,
+			"description": truncate(event.Summary, 4000),
+			"footer":      map[string]string{"text": event.ChannelTitle},
+		}
+		if event.ThumbnailURL != "" {
+			embed["thumbnail"] = map[string]string{"url": event.ThumbnailURL}
+		}
+		return json.Marshal(map[string]interface{}{"embeds": []interface{}{embed}})
+	default:
+		return json.Marshal(event)
+	}
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
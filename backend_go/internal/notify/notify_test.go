@@ -0,0 +1,310 @@
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ttakahashi/youtube-summary/internal/dynamotest"
+)
+
+var testEvent = Event{
+	Type:         EventSummaryCreated,
+	VideoID:      "abcdefghijk",
+	ChannelID:    "UCchannel",
+	ChannelTitle: "チャンネル",
+	Title:        "新しい動画",
+	Summary:      "要約",
+	URL:          "https://www.youtube.com/watch?v=abcdefghijk",
+	ThumbnailURL: "https://i.ytimg.com/vi/abcdefghijk/mqdefault.jpg",
+	PublishedAt:  "2025-01-01T00:00:00Z",
+}
+
+func TestParseTargets(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    []Target
+		wantErr string
+	}{
+		{
+			name: "named and default names",
+			s:    `[{"name": "team", "kind": "slack", "url": "https://hooks.slack.com/x"}, {"kind": "webhook", "url": "https://example.com/hook", "secret": "s", "channels": ["UCa"]}]`,
+			want: []Target{
+				{Name: "team", Kind: KindSlack, URL: "https://hooks.slack.com/x"},
+				{Name: "webhook-1", Kind: KindWebhook, URL: "https://example.com/hook", Secret: "s", Channels: []string{"UCa"}},
+			},
+		},
+		{name: "not json", s: `slack`, wantErr: "invalid notification targets"},
+		{name: "unknown kind", s: `[{"kind": "teams", "url": "https://example.com"}]`, wantErr: `unknown kind "teams"`},
+		{name: "no url", s: `[{"kind": "discord"}]`, wantErr: "url is required"},
+		{name: "unsigned webhook", s: `[{"kind": "webhook", "url": "https://example.com/hook"}]`, wantErr: "secret is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTargets(tt.s)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("ParseTargets error = %v, want one mentioning %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseTargets = %+v, %v; want %+v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSign(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	body := []byte(`{"type":"summary.created"}`)
+	sign(req, "s3cret", body, time.Unix(1700000000, 0))
+
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write([]byte("1700000000." + string(body)))
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if got := req.Header.Get("X-Timestamp"); got != "1700000000" {
+		t.Errorf("X-Timestamp = %q, want 1700000000", got)
+	}
+	if got := req.Header.Get("X-Signature-256"); got != want {
+		t.Errorf("X-Signature-256 = %q, want %q", got, want)
+	}
+}
+
+func TestPayload(t *testing.T) {
+	noThumbnail := testEvent
+	noThumbnail.ThumbnailURL = ""
+	long := testEvent
+	long.Summary = strings.Repeat("あ", 5000)
+	text := "*<https://www.youtube.com/watch?v=abcdefghijk|新しい動画>*\nチャンネル\n要約"
+
+	tests := []struct {
+		name   string
+		target Target
+		event  Event
+		want   string
+	}{
+		{
+			name:   "slack with thumbnail",
+			target: Target{Kind: KindSlack},
+			event:  testEvent,
+			want: `{"text": ` + quote(text) + `, "blocks": [{"type": "section", "text": {"type": "mrkdwn", "text": ` + quote(text) + `},
+				"accessory": {"type": "image", "image_url": "https://i.ytimg.com/vi/abcdefghijk/mqdefault.jpg", "alt_text": "新しい動画"}}]}`,
+		},
+		{
+			name:   "slack without thumbnail",
+			target: Target{Kind: KindSlack},
+			event:  noThumbnail,
+			want:   `{"text": ` + quote(text) + `, "blocks": [{"type": "section", "text": {"type": "mrkdwn", "text": ` + quote(text) + `}}]}`,
+		},
+		{
+			name:   "discord",
+			target: Target{Kind: KindDiscord},
+			event:  testEvent,
+			want: `{"embeds": [{"title": "新しい動画", "url": "https://www.youtube.com/watch?v=abcdefghijk", "description": "要約",
+				"footer": {"text": "チャンネル"}, "thumbnail": {"url": "https://i.ytimg.com/vi/abcdefghijk/mqdefault.jpg"}}]}`,
+		},
+		{
+			name:   "discord description truncated",
+			target: Target{Kind: KindDiscord},
+			event:  long,
+			want: `{"embeds": [{"title": "新しい動画", "url": "https://www.youtube.com/watch?v=abcdefghijk", "description": ` + quote(strings.Repeat("あ", 3999)+"…") + `,
+				"footer": {"text": "チャンネル"}, "thumbnail": {"url": "https://i.ytimg.com/vi/abcdefghijk/mqdefault.jpg"}}]}`,
+		},
+		{
+			name:   "webhook",
+			target: Target{Kind: KindWebhook, Secret: "s"},
+			event:  noThumbnail,
+			want: `{"type": "summary.created", "videoId": "abcdefghijk", "channelId": "UCchannel", "channelTitle": "チャンネル",
+				"title": "新しい動画", "summary": "要約", "url": "https://www.youtube.com/watch?v=abcdefghijk", "publishedAt": "2025-01-01T00:00:00Z"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := payload(tt.target, tt.event)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got, want interface{}
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(tt.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("payload = %s\nwant %s", data, tt.want)
+			}
+		})
+	}
+}
+
+func quote(s string) string {
+	data, _ := json.Marshal(s)
+	return string(data)
+}
+
+// receiver is a target server answering with statuses in turn, then 200.
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func newReceiver(t *testing.T, statuses ...int) (*receiver, string) {
+	r := &receiver{statuses: statuses}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.requests = append(r.requests, req)
+		r.bodies = append(r.bodies, body)
+		status := http.StatusOK
+		if len(r.statuses) > 0 {
+			status, r.statuses = r.statuses[0], r.statuses[1:]
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return r, srv.URL
+}
+
+func (r *receiver) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.requests)
+}
+
+func TestDeliverRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		wantErr      bool
+		wantAttempts int
+	}{
+		{name: "delivered", wantAttempts: 1},
+		{name: "server errors retried", statuses: []int{500, 503}, wantAttempts: 3},
+		{name: "rate limit retried", statuses: []int{429}, wantAttempts: 2},
+		{name: "gives up", statuses: []int{500, 500, 500, 500}, wantErr: true, wantAttempts: 4},
+		{name: "client errors not retried", statuses: []int{404}, wantErr: true, wantAttempts: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, url := newReceiver(t, tt.statuses...)
+			n := New(nil, nil)
+			n.backoff = time.Millisecond
+
+			err := n.deliver(context.Background(), Target{Name: "hook", Kind: KindWebhook, URL: url, Secret: "s3cret"}, testEvent)
+			if tt.wantErr != (err != nil) {
+				t.Errorf("deliver = %v, want error %v", err, tt.wantErr)
+			}
+			if r.count() != tt.wantAttempts {
+				t.Errorf("%d attempts, want %d", r.count(), tt.wantAttempts)
+			}
+			for i, req := range r.requests {
+				mac := hmac.New(sha256.New, []byte("s3cret"))
+				mac.Write([]byte(req.Header.Get("X-Timestamp") + "." + string(r.bodies[i])))
+				if req.Header.Get("X-Signature-256") != "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+					t.Errorf("attempt %d is not signed", i+1)
+				}
+			}
+		})
+	}
+}
+
+func TestDeliverCanceled(t *testing.T) {
+	r, url := newReceiver(t, 500, 500)
+	n := New(nil, nil)
+	n.backoff = time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := n.deliver(ctx, Target{Kind: KindSlack, URL: url}, testEvent); err == nil {
+		t.Error("deliver succeeded after the context ended")
+	}
+	if r.count() != 1 {
+		t.Errorf("%d attempts, want 1 before the context ended", r.count())
+	}
+}
+
+// memoryDeduper is a Deduper keeping its keys in memory.
+type memoryDeduper map[string]bool
+
+func (d memoryDeduper) Seen(ctx context.Context, key string) (bool, error) { return d[key], nil }
+func (d memoryDeduper) Mark(ctx context.Context, key string) error         { d[key] = true; return nil }
+
+func TestNotifyDedup(t *testing.T) {
+	all, allURL := newReceiver(t)
+	other, otherURL := newReceiver(t)
+	failing, failingURL := newReceiver(t, 400, 400)
+	dedup := memoryDeduper{}
+	n := New([]Target{
+		{Name: "all", Kind: KindSlack, URL: allURL},
+		{Name: "other", Kind: KindDiscord, URL: otherURL, Channels: []string{"UCother"}},
+		{Name: "failing", Kind: KindDiscord, URL: failingURL},
+	}, dedup)
+	n.backoff = time.Millisecond
+
+	if err := n.Notify(context.Background(), testEvent); err == nil || !strings.Contains(err.Error(), "failing") {
+		t.Errorf("Notify = %v, want the failing target's error", err)
+	}
+	want := memoryDeduper{"all#summary.created#abcdefghijk": true}
+	if !reflect.DeepEqual(dedup, want) {
+		t.Errorf("marked %v, want %v", dedup, want)
+	}
+
+	// Delivered events are not sent again; failed ones are retried
+	n.Notify(context.Background(), testEvent)
+	if all.count() != 1 || other.count() != 0 || failing.count() != 2 {
+		t.Errorf("deliveries = %d, %d, %d; want 1, 0, 2", all.count(), other.count(), failing.count())
+	}
+}
+
+func TestDynamoDeduper(t *testing.T) {
+	marked := map[string]bool{}
+	client, srv := dynamotest.NewClient(t, func(call dynamotest.Call) (interface{}, string) {
+		hashtag, key := call.Key()
+		if hashtag != "notified" {
+			t.Errorf("%s of partition %q, want notified", call.Operation, hashtag)
+		}
+		switch call.Operation {
+		case "GetItem":
+			if !marked[key] {
+				return nil, ""
+			}
+			return map[string]interface{}{"Item": map[string]interface{}{"hashtag": dynamotest.S(hashtag), "processedAt": dynamotest.S(key)}}, ""
+		case "PutItem":
+			marked[key] = true
+			return nil, ""
+		}
+		return nil, "ValidationException"
+	})
+	d := NewDynamoDeduper(client, "table")
+	ctx := context.Background()
+
+	const key = "all#summary.created#abcdefghijk"
+	if seen, err := d.Seen(ctx, key); err != nil || seen {
+		t.Errorf("Seen before Mark = %v, %v; want false", seen, err)
+	}
+	if err := d.Mark(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if seen, err := d.Seen(ctx, key); err != nil || !seen {
+		t.Errorf("Seen after Mark = %v, %v; want true", seen, err)
+	}
+	put := srv.Calls()[1]
+	if at := dynamotest.Value(put.Input["Item"].(map[string]interface{})["notifiedAt"]); at == "" {
+		t.Error("Mark did not record notifiedAt")
+	}
+}
@@ -0,0 +1,182 @@
+// Package quota meters YouTube Data API usage against a daily unit budget.
+//
+// Every call is charged before it is made. The running total for the day is
+// kept in DynamoDB so that local runs and Lambda invocations share it.
+package quota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+	_ "time/tzdata" // Quota days follow Pacific time; the Lambda image has no zoneinfo.
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// DefaultDailyBudget is the quota YouTube grants a project per day.
+const DefaultDailyBudget = 10000
+
+// partitionKey is the "hashtag" value under which daily totals are stored.
+const partitionKey = "quota#youtube"
+
+// ErrBudgetExceeded is returned when a call would push today's usage past the budget.
+var ErrBudgetExceeded = errors.New("youtube api daily quota budget exceeded")
+
+// Costs lists the unit cost of each YouTube Data API method we call.
+// See https://developers.google.com/youtube/v3/determine_quota_cost
+var Costs = map[string]int64{
+	"search.list":         100,
+	"videos.list":         1,
+	"channels.list":       1,
+	"playlistItems.list":  1,
+	"commentThreads.list": 1,
+}
+
+// YouTube resets quotas at midnight Pacific time.
+var resetLocation = mustLoadLocation("America/Los_Angeles")
+
+func mustLoadLocation(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		panic(err)
+	}
+	return loc
+}
+
+// Meter charges YouTube API calls against the daily budget.
+type Meter struct {
+	client *dynamodb.Client
+	table  string
+	budget int64
+
+	mu    sync.Mutex
+	day   string
+	used  int64 // units used today across all runs, as last seen in storage
+	spent int64 // units charged through this meter
+}
+
+// NewMeter returns a Meter for the given budget, loaded with today's usage.
+func NewMeter(ctx context.Context, client *dynamodb.Client, table string, budget int64) (*Meter, error) {
+	if budget <= 0 {
+		budget = DefaultDailyBudget
+	}
+	m := &Meter{client: client, table: table, budget: budget}
+	m.day = today()
+
+	resp, err := client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(table),
+		Key:       key(m.day),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load quota usage: %w", err)
+	}
+	if v, ok := resp.Item["units"].(*types.AttributeValueMemberN); ok {
+		m.used, _ = strconv.ParseInt(v.Value, 10, 64)
+	}
+	return m, nil
+}
+
+// Allows reports whether the given methods can all be called without
+// exceeding the budget. It is meant for refusing work up front; each call
+// must still be charged with Charge.
+func (m *Meter) Allows(methods ...string) bool {
+	var units int64
+	for _, method := range methods {
+		units += Costs[method]
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rollover()
+	return m.used+units <= m.budget
+}
+
+// Charge records one call to method. It returns ErrBudgetExceeded, without
+// recording anything, if the call would exceed the budget.
+func (m *Meter) Charge(ctx context.Context, method string) error {
+	cost, ok := Costs[method]
+	if !ok {
+		return fmt.Errorf("unknown youtube api method %q", method)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rollover()
+
+	// The condition makes the check atomic across concurrent runs.
+	resp, err := m.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(m.table),
+		Key:                 key(m.day),
+		UpdateExpression:    aws.String("ADD #units :cost"),
+		ConditionExpression: aws.String("attribute_not_exists(#units) OR #units <= :limit"),
+		ExpressionAttributeNames: map[string]string{
+			"#units": "units",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":cost":  &types.AttributeValueMemberN{Value: strconv.FormatInt(cost, 10)},
+			":limit": &types.AttributeValueMemberN{Value: strconv.FormatInt(m.budget-cost, 10)},
+		},
+		ReturnValues: types.ReturnValueUpdatedNew,
+	})
+	if err != nil {
+		var ccf *types.ConditionalCheckFailedException
+		if errors.As(err, &ccf) {
+			m.used = m.budget
+			return fmt.Errorf("%s costs %d units: %w", method, cost, ErrBudgetExceeded)
+		}
+		return fmt.Errorf("failed to record quota usage: %w", err)
+	}
+
+	if v, ok := resp.Attributes["units"].(*types.AttributeValueMemberN); ok {
+		m.used, _ = strconv.ParseInt(v.Value, 10, 64)
+	} else {
+		m.used += cost
+	}
+	m.spent += cost
+	return nil
+}
+
+// Spent returns the units charged through this meter.
+func (m *Meter) Spent() int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.spent
+}
+
+// Used returns today's total usage, including other runs.
+func (m *Meter) Used() int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rollover()
+	return m.used
+}
+
+// Budget returns the configured daily budget.
+func (m *Meter) Budget() int64 {
+	return m.budget
+}
+
+// rollover starts a fresh day when a run crosses the reset boundary.
+// The caller must hold m.mu.
+func (m *Meter) rollover() {
+	if d := today(); d != m.day {
+		m.day = d
+		m.used = 0
+	}
+}
+
+func today() string {
+	return time.Now().In(resetLocation).Format("2006-01-02")
+}
+
+func key(day string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"hashtag":     &types.AttributeValueMemberS{Value: partitionKey},
+		"processedAt": &types.AttributeValueMemberS{Value: day},
+	}
+}
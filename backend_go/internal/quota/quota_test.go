@@ -0,0 +1,160 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ttakahashi/youtube-summary/internal/dynamotest"
+)
+
+// fakeUsage answers the meter's calls from an in-memory total, applying the
+// condition of Charge as DynamoDB would.
+func fakeUsage(t *testing.T, units int64) (*Meter, *dynamotest.Server) {
+	t.Helper()
+	client, srv := dynamotest.NewClient(t, func(call dynamotest.Call) (interface{}, string) {
+		switch call.Operation {
+		case "GetItem":
+			if units == 0 {
+				return nil, ""
+			}
+			return map[string]interface{}{"Item": map[string]interface{}{"units": dynamotest.N(strconv.FormatInt(units, 10))}}, ""
+		case "UpdateItem":
+			values := call.Values()
+			cost, _ := strconv.ParseInt(values[":cost"], 10, 64)
+			limit, _ := strconv.ParseInt(values[":limit"], 10, 64)
+			if units > limit {
+				return nil, "ConditionalCheckFailedException"
+			}
+			units += cost
+			return map[string]interface{}{"Attributes": map[string]interface{}{"units": dynamotest.N(strconv.FormatInt(units, 10))}}, ""
+		}
+		t.Errorf("unexpected %s", call.Operation)
+		return nil, "ValidationException"
+	})
+	m, err := NewMeter(context.Background(), client, "table", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m, srv
+}
+
+func TestMeterCharge(t *testing.T) {
+	tests := []struct {
+		name      string
+		usedToday int64
+		methods   []string
+		wantErr   error
+		wantUsed  int64
+		wantSpent int64
+	}{
+		{
+			name:      "within budget",
+			methods:   []string{"videos.list", "commentThreads.list"},
+			wantUsed:  2,
+			wantSpent: 2,
+		},
+		{
+			name:      "up to the budget exactly",
+			methods:   []string{"search.list"},
+			wantUsed:  100,
+			wantSpent: 100,
+		},
+		{
+			name:      "other runs count",
+			usedToday: 50,
+			methods:   []string{"search.list"},
+			wantErr:   ErrBudgetExceeded,
+			wantUsed:  100, // the meter stops trusting its total once refused
+		},
+		{
+			name:      "unknown method",
+			usedToday: 10,
+			methods:   []string{"videos.delete"},
+			wantErr:   errors.New("unknown"),
+			wantUsed:  10,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _ := fakeUsage(t, tt.usedToday)
+			var err error
+			for _, method := range tt.methods {
+				if err = m.Charge(context.Background(), method); err != nil {
+					break
+				}
+			}
+			switch {
+			case tt.wantErr == nil && err != nil:
+				t.Fatalf("Charge: %v", err)
+			case tt.wantErr != nil && err == nil:
+				t.Fatalf("Charge succeeded, want %v", tt.wantErr)
+			case errors.Is(tt.wantErr, ErrBudgetExceeded) && !errors.Is(err, ErrBudgetExceeded):
+				t.Fatalf("Charge = %v, want %v", err, ErrBudgetExceeded)
+			}
+			if m.Used() != tt.wantUsed || m.Spent() != tt.wantSpent {
+				t.Errorf("used, spent = %d, %d; want %d, %d", m.Used(), m.Spent(), tt.wantUsed, tt.wantSpent)
+			}
+		})
+	}
+}
+
+func TestMeterAllows(t *testing.T) {
+	tests := []struct {
+		used    int64
+		methods []string
+		want    bool
+	}{
+		{used: 0, methods: []string{"search.list"}, want: true},
+		{used: 0, methods: []string{"search.list", "videos.list"}, want: false},
+		{used: 99, methods: []string{"videos.list"}, want: true},
+		{used: 100, methods: []string{"videos.list"}, want: false},
+		{used: 100, methods: nil, want: true},
+	}
+	for _, tt := range tests {
+		m := &Meter{budget: 100, day: today(), used: tt.used}
+		if got := m.Allows(tt.methods...); got != tt.want {
+			t.Errorf("Allows(%v) with %d used = %v, want %v", tt.methods, tt.used, got, tt.want)
+		}
+	}
+}
+
+func TestToday(t *testing.T) {
+	la, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Now().In(la).Format("2006-01-02"); today() != want {
+		t.Errorf("today() = %s, want the Pacific date %s", today(), want)
+	}
+}
+
+func TestMeterRollover(t *testing.T) {
+	// A meter loaded yesterday starts from zero once the Pacific day changes
+	m := &Meter{budget: 100, day: "2000-01-01", used: 100}
+	if !m.Allows("search.list") {
+		t.Error("Allows after the day changed = false, want true")
+	}
+	if m.Used() != 0 || m.day != today() {
+		t.Errorf("used, day = %d, %s; want 0, %s", m.Used(), m.day, today())
+	}
+}
+
+func TestNewMeterDefaultBudget(t *testing.T) {
+	client, srv := dynamotest.NewClient(t, func(call dynamotest.Call) (interface{}, string) {
+		return nil, ""
+	})
+	m, err := NewMeter(context.Background(), client, "table", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Budget() != DefaultDailyBudget {
+		t.Errorf("Budget() = %d, want %d", m.Budget(), DefaultDailyBudget)
+	}
+	if _, day := srv.Calls()[0].Key(); day != today() {
+		t.Errorf("loaded usage of %s, want %s", day, today())
+	}
+}
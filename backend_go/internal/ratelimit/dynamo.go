@@ -0,0 +1,87 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// DynamoLimiter keeps buckets in the table so that limits hold across
+// Lambda instances. Buckets are under "ratelimit", sorted by key, and
+// expire through the table's TTL attribute "ttl" once they would be full.
+type DynamoLimiter struct {
+	client *dynamodb.Client
+	table  string
+}
+
+// NewDynamoLimiter returns a limiter backed by table.
+func NewDynamoLimiter(client *dynamodb.Client, table string) *DynamoLimiter {
+	return &DynamoLimiter{client: client, table: table}
+}
+
+// Concurrent requests of one client may race; the loser retries with the
+// winner's state.
+const maxAttempts = 3
+
+func (d *DynamoLimiter) Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	itemKey := map[string]types.AttributeValue{
+		"hashtag":     &types.AttributeValueMemberS{Value: "ratelimit"},
+		"processedAt": &types.AttributeValueMemberS{Value: key},
+	}
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		resp, err := d.client.GetItem(ctx, &dynamodb.GetItemInput{
+			TableName:      aws.String(d.table),
+			Key:            itemKey,
+			ConsistentRead: aws.Bool(true),
+		})
+		if err != nil {
+			return Result{}, fmt.Errorf("failed to read rate limit bucket: %w", err)
+		}
+		var old bucket
+		var oldAt string
+		if v, ok := resp.Item["tokens"].(*types.AttributeValueMemberN); ok {
+			old.tokens, _ = strconv.ParseFloat(v.Value, 64)
+		}
+		if v, ok := resp.Item["updatedAt"].(*types.AttributeValueMemberN); ok {
+			oldAt = v.Value
+			ms, _ := strconv.ParseInt(v.Value, 10, 64)
+			old.at = time.UnixMilli(ms)
+		}
+
+		b, r := old.take(limit, now)
+		input := &dynamodb.UpdateItemInput{
+			TableName:        aws.String(d.table),
+			Key:              itemKey,
+			UpdateExpression: aws.String("SET tokens = :t, updatedAt = :now, #ttl = :ttl"),
+			ExpressionAttributeNames: map[string]string{
+				"#ttl": "ttl",
+			},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":t":   &types.AttributeValueMemberN{Value: strconv.FormatFloat(b.tokens, 'f', 4, 64)},
+				":now": &types.AttributeValueMemberN{Value: strconv.FormatInt(b.at.UnixMilli(), 10)},
+				":ttl": &types.AttributeValueMemberN{Value: strconv.FormatInt(now.Add(r.Reset+time.Minute).Unix(), 10)},
+			},
+		}
+		if oldAt == "" {
+			input.ConditionExpression = aws.String("attribute_not_exists(updatedAt)")
+		} else {
+			input.ConditionExpression = aws.String("updatedAt = :old")
+			input.ExpressionAttributeValues[":old"] = &types.AttributeValueMemberN{Value: oldAt}
+		}
+		_, err = d.client.UpdateItem(ctx, input)
+		if err == nil {
+			return r, nil
+		}
+		var ccf *types.ConditionalCheckFailedException
+		if !errors.As(err, &ccf) {
+			return Result{}, fmt.Errorf("failed to update rate limit bucket: %w", err)
+		}
+	}
+	return Result{}, fmt.Errorf("rate limit bucket %s is contended", key)
+}
@@ -0,0 +1,148 @@
+package ratelimit
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ttakahashi/youtube-summary/internal/dynamotest"
+)
+
+// fakeBucket is a bucket item served by a fake DynamoDB that applies the
+// limiter's conditions. Before each of the first races updates, another
+// instance writes the bucket.
+type fakeBucket struct {
+	tokens    string
+	updatedAt string // empty while the item does not exist
+	ttl       string
+	races     int
+	racedAt   time.Time
+	failWith  string
+}
+
+func (f *fakeBucket) limiter(t *testing.T) (*DynamoLimiter, *dynamotest.Server) {
+	client, srv := dynamotest.NewClient(t, func(call dynamotest.Call) (interface{}, string) {
+		if hashtag, key := call.Key(); hashtag != "ratelimit" || key != "ip:192.0.2.1" {
+			t.Errorf("%s of %s/%s, want ratelimit/ip:192.0.2.1", call.Operation, hashtag, key)
+		}
+		switch call.Operation {
+		case "GetItem":
+			if call.Input["ConsistentRead"] != true {
+				t.Error("bucket read without ConsistentRead")
+			}
+			if f.updatedAt == "" {
+				return nil, ""
+			}
+			return map[string]interface{}{"Item": map[string]interface{}{
+				"tokens":    dynamotest.N(f.tokens),
+				"updatedAt": dynamotest.N(f.updatedAt),
+			}}, ""
+		case "UpdateItem":
+			if f.failWith != "" {
+				return nil, f.failWith
+			}
+			if f.races > 0 {
+				f.races--
+				f.tokens, f.updatedAt = "0.0000", strconv.FormatInt(f.racedAt.UnixMilli(), 10)
+				f.racedAt = f.racedAt.Add(time.Millisecond)
+			}
+			values := call.Values()
+			switch cond := call.Input["ConditionExpression"]; cond {
+			case "attribute_not_exists(updatedAt)":
+				if f.updatedAt != "" {
+					return nil, "ConditionalCheckFailedException"
+				}
+			case "updatedAt = :old":
+				if values[":old"] != f.updatedAt {
+					return nil, "ConditionalCheckFailedException"
+				}
+			default:
+				t.Errorf("unexpected condition %v", cond)
+			}
+			f.tokens, f.updatedAt, f.ttl = values[":t"], values[":now"], values[":ttl"]
+			return nil, ""
+		}
+		t.Errorf("unexpected %s", call.Operation)
+		return nil, "ValidationException"
+	})
+	return NewDynamoLimiter(client, "table"), srv
+}
+
+func TestDynamoLimiterTake(t *testing.T) {
+	limit := Limit{PerMinute: 60, Burst: 2}
+	now := time.Unix(1700000000, 0)
+	f := &fakeBucket{}
+	d, _ := f.limiter(t)
+
+	steps := []struct {
+		at         time.Duration
+		wantAllow  bool
+		wantTokens string
+	}{
+		{at: 0, wantAllow: true, wantTokens: "1.0000"},
+		{at: 0, wantAllow: true, wantTokens: "0.0000"},
+		{at: 500 * time.Millisecond, wantAllow: false, wantTokens: "0.5000"},
+		{at: 1500 * time.Millisecond, wantAllow: true, wantTokens: "0.5000"},
+	}
+	for i, s := range steps {
+		r, err := d.Take(context.Background(), "ip:192.0.2.1", limit, now.Add(s.at))
+		if err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+		if r.Allowed != s.wantAllow || f.tokens != s.wantTokens {
+			t.Errorf("step %d: allowed %v with %s tokens stored, want %v with %s", i, r.Allowed, f.tokens, s.wantAllow, s.wantTokens)
+		}
+		if want := strconv.FormatInt(now.Add(s.at).UnixMilli(), 10); f.updatedAt != want {
+			t.Errorf("step %d: updatedAt = %s, want %s", i, f.updatedAt, want)
+		}
+	}
+	// The item expires a minute after the bucket would be full again
+	if want := strconv.FormatInt(now.Add(1500*time.Millisecond+2*time.Second+time.Minute).Unix(), 10); f.ttl != want {
+		t.Errorf("ttl = %s, want %s", f.ttl, want)
+	}
+}
+
+func TestDynamoLimiterRaces(t *testing.T) {
+	limit := Limit{PerMinute: 60, Burst: 5}
+	now := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name      string
+		exists    bool
+		races     int
+		failWith  string
+		wantAllow bool
+		wantCalls int
+		wantErr   string
+	}{
+		{name: "no race", races: 0, wantAllow: true, wantCalls: 2},
+		{name: "created by another instance", races: 1, wantAllow: false, wantCalls: 4},
+		{name: "updated by another instance", exists: true, races: 1, wantAllow: false, wantCalls: 4},
+		{name: "contended", exists: true, races: maxAttempts, wantCalls: 2 * maxAttempts, wantErr: "contended"},
+		{name: "other errors not retried", exists: true, failWith: "ProvisionedThroughputExceededException", wantCalls: 2, wantErr: "failed to update"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The racing instance took the last token at the same time
+			f := &fakeBucket{races: tt.races, racedAt: now, failWith: tt.failWith}
+			if tt.exists {
+				f.tokens, f.updatedAt = "3.0000", strconv.FormatInt(now.Add(-time.Second).UnixMilli(), 10)
+			}
+			d, srv := f.limiter(t)
+
+			r, err := d.Take(context.Background(), "ip:192.0.2.1", limit, now)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Take = %+v, %v; want an error mentioning %q", r, err, tt.wantErr)
+				}
+			} else if err != nil || r.Allowed != tt.wantAllow {
+				t.Errorf("Take = %+v, %v; want allowed %v", r, err, tt.wantAllow)
+			}
+			if calls := len(srv.Calls()); calls != tt.wantCalls {
+				t.Errorf("%d calls, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+// Package ratelimit limits how often each client may call the API, with a
+// token bucket per client and route class.
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limit is a token bucket: it holds up to Burst requests and refills at
+// PerMinute requests per minute.
+type Limit struct {
+	PerMinute float64 `json:"perMinute"`
+	Burst     int     `json:"burst"`
+}
+
+// DefaultLimits are the limits per route class. Classes that cost LLM
+// calls are limited the most.
+var DefaultLimits = map[string]Limit{
+	"read":  {PerMinute: 120, Burst: 60},
+	"ask":   {PerMinute: 6, Burst: 3},
+	"jobs":  {PerMinute: 10, Burst: 5},
+	"admin": {PerMinute: 60, Burst: 30},
+}
+
+// ParseLimits parses a JSON object of limits per class, as set in
+// RATE_LIMITS, layered over DefaultLimits.
+func ParseLimits(s string) (map[string]Limit, error) {
+	var overrides map[string]Limit
+	if err := json.Unmarshal([]byte(s), &overrides); err != nil {
+		return nil, fmt.Errorf("invalid rate limits: %w", err)
+	}
+	limits := map[string]Limit{}
+	for class, l := range DefaultLimits {
+		limits[class] = l
+	}
+	for class, l := range overrides {
+		if l.PerMinute <= 0 || l.Burst <= 0 {
+			return nil, fmt.Errorf("rate limit of %q needs a positive perMinute and burst", class)
+		}
+		limits[class] = l
+	}
+	return limits, nil
+}
+
+// Result is the outcome of taking a token.
+type Result struct {
+	Allowed    bool
+	Limit      Limit
+	Remaining  int
+	Reset      time.Duration // until the bucket is full again
+	RetryAfter time.Duration // until a token is available, when not Allowed
+}
+
+// Limiter takes tokens from buckets named by key.
+type Limiter interface {
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+}
+
+// bucket is the state of a token bucket at a point in time.
+type bucket struct {
+	tokens float64
+	at     time.Time
+}
+
+// take refills b up to now and takes a token if there is one.
+func (b bucket) take(limit Limit, now time.Time) (bucket, Result) {
+	rate := limit.PerMinute / 60 // tokens per second
+	burst := float64(limit.Burst)
+	if b.at.IsZero() {
+		b = bucket{tokens: burst, at: now}
+	}
+	if elapsed := now.Sub(b.at).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(burst, b.tokens+elapsed*rate)
+	}
+	b.at = now
+
+	r := Result{Limit: limit}
+	if b.tokens >= 1 {
+		b.tokens--
+		r.Allowed = true
+	} else {
+		r.RetryAfter = seconds((1 - b.tokens) / rate)
+	}
+	r.Remaining = int(b.tokens)
+	r.Reset = seconds((burst - b.tokens) / rate)
+	return b, r
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Ceil(s)) * time.Second
+}
+
+// MemoryLimiter keeps buckets in memory. Each Lambda instance has its own,
+// so the limits are per instance.
+type MemoryLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]bucket
+}
+
+// NewMemoryLimiter returns an empty in-memory limiter.
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{buckets: map[string]bucket{}}
+}
+
+func (m *MemoryLimiter) Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, r := m.buckets[key].take(limit, now)
+	m.buckets[key] = b
+	if len(m.buckets) > 10000 {
+		// Buckets idle this long are full again; dropping them changes nothing
+		for k, b := range m.buckets {
+			if now.Sub(b.at) > time.Hour {
+				delete(m.buckets, k)
+			}
+		}
+	}
+	return r, nil
+}
@@ -0,0 +1,149 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryLimiterTake(t *testing.T) {
+	limit := Limit{PerMinute: 60, Burst: 3} // one token a second
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	type take struct {
+		after      time.Duration // since start
+		allowed    bool
+		remaining  int
+		retryAfter time.Duration
+		reset      time.Duration
+	}
+	tests := []struct {
+		name  string
+		takes []take
+	}{
+		{
+			name: "burst then limited",
+			takes: []take{
+				{after: 0, allowed: true, remaining: 2, reset: time.Second},
+				{after: 0, allowed: true, remaining: 1, reset: 2 * time.Second},
+				{after: 0, allowed: true, remaining: 0, reset: 3 * time.Second},
+				{after: 0, allowed: false, remaining: 0, retryAfter: time.Second, reset: 3 * time.Second},
+			},
+		},
+		{
+			name: "refills at the rate",
+			takes: []take{
+				{after: 0, allowed: true, remaining: 2, reset: time.Second},
+				{after: 0, allowed: true, remaining: 1, reset: 2 * time.Second},
+				{after: 0, allowed: true, remaining: 0, reset: 3 * time.Second},
+				{after: time.Second, allowed: true, remaining: 0, reset: 3 * time.Second},
+				{after: 1500 * time.Millisecond, allowed: false, remaining: 0, retryAfter: time.Second, reset: 3 * time.Second},
+				{after: 2 * time.Second, allowed: true, remaining: 0, reset: 3 * time.Second},
+			},
+		},
+		{
+			name: "refill stops at the burst",
+			takes: []take{
+				{after: 0, allowed: true, remaining: 2, reset: time.Second},
+				{after: time.Hour, allowed: true, remaining: 2, reset: time.Second},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMemoryLimiter()
+			for i, want := range tt.takes {
+				got, err := m.Take(context.Background(), "client", limit, start.Add(want.after))
+				if err != nil {
+					t.Fatalf("take %d: %v", i, err)
+				}
+				if got.Allowed != want.allowed || got.Remaining != want.remaining || got.RetryAfter != want.retryAfter || got.Reset != want.reset {
+					t.Errorf("take %d = allowed %v, remaining %d, retry after %v, reset %v; want %v, %d, %v, %v",
+						i, got.Allowed, got.Remaining, got.RetryAfter, got.Reset, want.allowed, want.remaining, want.retryAfter, want.reset)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryLimiterKeysAreSeparate(t *testing.T) {
+	m := NewMemoryLimiter()
+	limit := Limit{PerMinute: 1, Burst: 1}
+	now := time.Now()
+	for _, key := range []string{"a", "b"} {
+		r, err := m.Take(context.Background(), key, limit, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !r.Allowed {
+			t.Errorf("first take of %q was not allowed", key)
+		}
+	}
+	if r, _ := m.Take(context.Background(), "a", limit, now); r.Allowed {
+		t.Error("second take of \"a\" was allowed")
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    map[string]Limit
+		wantErr bool
+	}{
+		{
+			name: "empty object keeps the defaults",
+			in:   `{}`,
+			want: DefaultLimits,
+		},
+		{
+			name: "overrides one class",
+			in:   `{"ask": {"perMinute": 2, "burst": 1}}`,
+			want: map[string]Limit{
+				"read":  DefaultLimits["read"],
+				"ask":   {PerMinute: 2, Burst: 1},
+				"jobs":  DefaultLimits["jobs"],
+				"admin": DefaultLimits["admin"],
+			},
+		},
+		{
+			name:    "zero burst",
+			in:      `{"read": {"perMinute": 10, "burst": 0}}`,
+			wantErr: true,
+		},
+		{
+			name:    "negative rate",
+			in:      `{"read": {"perMinute": -1, "burst": 5}}`,
+			wantErr: true,
+		},
+		{
+			name:    "not JSON",
+			in:      `read=10`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLimits(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseLimits(%q) = %v, want an error", tt.in, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseLimits(%q): %v", tt.in, err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ParseLimits(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+			for class, l := range tt.want {
+				if got[class] != l {
+					t.Errorf("ParseLimits(%q)[%q] = %v, want %v", tt.in, class, got[class], l)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvProvider reads secrets from environment variables. The variable of a
+// secret is its name in upper case with every other character replaced by
+// an underscore: "youtube-summary/youtube-api-key" is read from
+// YOUTUBE_SUMMARY_YOUTUBE_API_KEY.
+type EnvProvider struct{}
+
+// EnvName returns the variable EnvProvider reads the secret name from.
+func EnvName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		}
+		return '_'
+	}, name)
+}
+
+func (EnvProvider) Get(ctx context.Context, name string) (string, error) {
+	v := os.Getenv(EnvName(name))
+	if v == "" {
+		return "", fmt.Errorf("%s (%s): %w", name, EnvName(name), ErrNotFound)
+	}
+	return v, nil
+}
+
+// FileProvider reads secrets from a YAML or JSON file mapping names to
+// values, for local runs:
+//
+//	youtube-summary/youtube-api-key: AIza...
+//	youtube-summary/smtp-password: ...
+type FileProvider struct {
+	values map[string]string
+}
+
+// NewFileProvider loads the secrets in path.
+func NewFileProvider(path string) (*FileProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secrets file: %w", err)
+	}
+	values := map[string]string{}
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("invalid secrets file %s: %w", path, err)
+	}
+	return &FileProvider{values: values}, nil
+}
+
+func (p *FileProvider) Get(ctx context.Context, name string) (string, error) {
+	v, ok := p.values[name]
+	if !ok {
+		return "", fmt.Errorf("%s: %w", name, ErrNotFound)
+	}
+	return v, nil
+}
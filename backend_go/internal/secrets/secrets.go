@@ -0,0 +1,45 @@
+// Package secrets reads credentials such as the YouTube API key from AWS
+// Secrets Manager, environment variables or a local file, so that the
+// service also runs without AWS.
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned for secrets the backend does not hold.
+var ErrNotFound = errors.New("secret not found")
+
+// Provider returns secrets by name, such as "youtube-summary/youtube-api-key".
+type Provider interface {
+	Get(ctx context.Context, name string) (string, error)
+}
+
+// Open returns the provider described by backend:
+//
+//	secretsmanager                 AWS Secrets Manager in region
+//	env                            environment variables, see EnvProvider
+//	file:///etc/youtube-summary/secrets.yaml
+//
+// Secrets Manager values are cached for ttl; zero disables the cache.
+func Open(ctx context.Context, backend, region string, ttl time.Duration) (Provider, error) {
+	switch {
+	case backend == "" || backend == "secretsmanager":
+		return NewSecretsManager(ctx, region, ttl)
+	case backend == "env":
+		return EnvProvider{}, nil
+	case strings.HasPrefix(backend, "file://"):
+		u, err := url.Parse(backend)
+		if err != nil {
+			return nil, fmt.Errorf("invalid secrets backend %q: %w", backend, err)
+		}
+		return NewFileProvider(u.Path)
+	default:
+		return nil, fmt.Errorf("unsupported secrets backend %q", backend)
+	}
+}
@@ -0,0 +1,182 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/ttakahashi/youtube-summary/internal/dynamotest"
+)
+
+func TestEnvName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"youtube-summary/youtube-api-key", "YOUTUBE_SUMMARY_YOUTUBE_API_KEY"},
+		{"SMTP_PASSWORD", "SMTP_PASSWORD"},
+		{"jwt.secret2", "JWT_SECRET2"},
+		{"ユーザー", "____"},
+	}
+	for _, tt := range tests {
+		if got := EnvName(tt.name); got != tt.want {
+			t.Errorf("EnvName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEnvProvider(t *testing.T) {
+	t.Setenv("YOUTUBE_SUMMARY_YOUTUBE_API_KEY", "AIza-test")
+	got, err := EnvProvider{}.Get(context.Background(), "youtube-summary/youtube-api-key")
+	if err != nil || got != "AIza-test" {
+		t.Errorf("Get = %q, %v; want AIza-test", got, err)
+	}
+	if _, err := (EnvProvider{}).Get(context.Background(), "youtube-summary/missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get of an unset variable = %v, want ErrNotFound", err)
+	}
+}
+
+func TestOpenFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "secrets.yaml")
+	data := "youtube-summary/youtube-api-key: AIza-file\n\"youtube-summary/smtp-password\": \"p@ss: word\"\n"
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := Open(context.Background(), "file://"+path, "us-east-1", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		want    string
+		wantErr error
+	}{
+		{name: "youtube-summary/youtube-api-key", want: "AIza-file"},
+		{name: "youtube-summary/smtp-password", want: "p@ss: word"},
+		{name: "youtube-summary/jwt-secret", wantErr: ErrNotFound},
+	}
+	for _, tt := range tests {
+		got, err := p.Get(context.Background(), tt.name)
+		if got != tt.want || !errors.Is(err, tt.wantErr) {
+			t.Errorf("Get(%q) = %q, %v; want %q, %v", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestOpen(t *testing.T) {
+	bad := filepath.Join(t.TempDir(), "bad.yaml")
+	if err := os.WriteFile(bad, []byte("- not\n- a map\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		backend string
+		wantErr bool
+	}{
+		{backend: "env"},
+		{backend: "file:///nonexistent/secrets.yaml", wantErr: true},
+		{backend: "file://" + bad, wantErr: true},
+		{backend: "vault://secret", wantErr: true},
+	}
+	for _, tt := range tests {
+		p, err := Open(context.Background(), tt.backend, "us-east-1", 0)
+		if tt.wantErr != (err != nil) || !tt.wantErr && p == nil {
+			t.Errorf("Open(%q) = %v, %v", tt.backend, p, err)
+		}
+	}
+}
+
+// fakeSecretsManager returns a provider backed by a fake Secrets Manager
+// holding values, and a count of the calls it answered.
+func fakeSecretsManager(t *testing.T, ttl time.Duration, values map[string]string) (*SecretsManager, *int) {
+	t.Helper()
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		var input struct{ SecretId string }
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		v, ok := values[input.SecretId]
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"__type": "ResourceNotFoundException", "message": "not found"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"Name": input.SecretId, "SecretString": v})
+	}))
+	t.Cleanup(srv.Close)
+
+	client := secretsmanager.New(secretsmanager.Options{
+		Region:           "us-east-1",
+		BaseEndpoint:     aws.String(srv.URL),
+		Credentials:      dynamotest.TestCredentials,
+		RetryMaxAttempts: 1,
+	})
+	return &SecretsManager{client: client, ttl: ttl, cache: map[string]cachedSecret{}}, &calls
+}
+
+func TestSecretsManagerCache(t *testing.T) {
+	ctx := context.Background()
+	values := map[string]string{"youtube-summary/youtube-api-key": "first"}
+
+	tests := []struct {
+		name      string
+		ttl       time.Duration
+		expire    bool // whether the cached value expires before the second Get
+		wantValue string
+		wantCalls int
+	}{
+		{name: "cached", ttl: time.Hour, wantValue: "first", wantCalls: 1},
+		{name: "expired", ttl: time.Hour, expire: true, wantValue: "second", wantCalls: 2},
+		{name: "cache disabled", ttl: 0, wantValue: "second", wantCalls: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values["youtube-summary/youtube-api-key"] = "first"
+			p, calls := fakeSecretsManager(t, tt.ttl, values)
+			if got, err := p.Get(ctx, "youtube-summary/youtube-api-key"); err != nil || got != "first" {
+				t.Fatalf("Get = %q, %v; want first", got, err)
+			}
+
+			values["youtube-summary/youtube-api-key"] = "second"
+			if tt.expire {
+				p.mu.Lock()
+				c := p.cache["youtube-summary/youtube-api-key"]
+				c.expires = time.Now().Add(-time.Second)
+				p.cache["youtube-summary/youtube-api-key"] = c
+				p.mu.Unlock()
+			}
+			got, err := p.Get(ctx, "youtube-summary/youtube-api-key")
+			if err != nil || got != tt.wantValue {
+				t.Errorf("second Get = %q, %v; want %q", got, err, tt.wantValue)
+			}
+			if *calls != tt.wantCalls {
+				t.Errorf("%d calls, want %d", *calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestSecretsManagerNotFound(t *testing.T) {
+	p, calls := fakeSecretsManager(t, time.Hour, nil)
+	for i := 0; i < 2; i++ {
+		if _, err := p.Get(context.Background(), "youtube-summary/missing"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get of a missing secret = %v, want ErrNotFound", err)
+		}
+	}
+	if *calls != 2 {
+		t.Errorf("%d calls, want 2: misses must not be cached", *calls)
+	}
+}
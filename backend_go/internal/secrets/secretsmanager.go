@@ -0,0 +1,74 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+)
+
+// SecretsManager reads secrets from AWS Secrets Manager. Values are kept
+// for a TTL, so a provider held in a package variable serves warm Lambda
+// invocations without calling the service again.
+type SecretsManager struct {
+	client *secretsmanager.Client
+	ttl    time.Duration
+
+	mu    sync.Mutex
+	cache map[string]cachedSecret
+}
+
+type cachedSecret struct {
+	value   string
+	expires time.Time
+}
+
+// NewSecretsManager returns a provider for the secrets in region.
+func NewSecretsManager(ctx context.Context, region string, ttl time.Duration) (*SecretsManager, error) {
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	if err != nil {
+		return nil, fmt.Errorf("unable to load SDK config, %w", err)
+	}
+	return &SecretsManager{
+		client: secretsmanager.NewFromConfig(cfg),
+		ttl:    ttl,
+		cache:  map[string]cachedSecret{},
+	}, nil
+}
+
+func (p *SecretsManager) Get(ctx context.Context, name string) (string, error) {
+	now := time.Now()
+	p.mu.Lock()
+	c, ok := p.cache[name]
+	p.mu.Unlock()
+	if ok && now.Before(c.expires) {
+		return c.value, nil
+	}
+
+	result, err := p.client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(name),
+	})
+	var notFound *types.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		return "", fmt.Errorf("%s: %w", name, ErrNotFound)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get secret %s: %w", name, err)
+	}
+	if result.SecretString == nil {
+		return "", fmt.Errorf("secret %s has no string value", name)
+	}
+
+	if p.ttl > 0 {
+		p.mu.Lock()
+		p.cache[name] = cachedSecret{value: *result.SecretString, expires: now.Add(p.ttl)}
+		p.mu.Unlock()
+	}
+	return *result.SecretString, nil
+}
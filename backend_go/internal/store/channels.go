@@ -0,0 +1,147 @@
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Channels the batch processes are kept under this partition, one item per
+// channel, so that they can be changed without a redeploy.
+const ChannelsPartition = "channels"
+
+// Channel is a channel the batch processes. The optional settings override
+// the batch's environment for this channel only.
+type Channel struct {
+	ID     string `json:"id"`
+	Handle string `json:"handle,omitempty"`
+	Title  string `json:"title"`
+	Paused bool   `json:"paused"`
+
+	ShortsMode       string   `json:"shortsMode,omitempty"`
+	SummaryLanguages []string `json:"summaryLanguages,omitempty"`
+	CommentSummary   *bool    `json:"commentSummary,omitempty"`
+
+	CreatedAt string `json:"createdAt"`
+	UpdatedAt string `json:"updatedAt"`
+}
+
+func channelKey(id string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"hashtag":     &types.AttributeValueMemberS{Value: ChannelsPartition},
+		"processedAt": &types.AttributeValueMemberS{Value: id},
+	}
+}
+
+// SaveChannel stores c, replacing an earlier configuration of the channel.
+func SaveChannel(ctx context.Context, client *dynamodb.Client, table string, c *Channel) error {
+	item := channelKey(c.ID)
+	item["title"] = &types.AttributeValueMemberS{Value: c.Title}
+	item["paused"] = &types.AttributeValueMemberBOOL{Value: c.Paused}
+	item["createdAt"] = &types.AttributeValueMemberS{Value: c.CreatedAt}
+	item["updatedAt"] = &types.AttributeValueMemberS{Value: c.UpdatedAt}
+	if c.Handle != "" {
+		item["handle"] = &types.AttributeValueMemberS{Value: c.Handle}
+	}
+	if c.ShortsMode != "" {
+		item["shortsMode"] = &types.AttributeValueMemberS{Value: c.ShortsMode}
+	}
+	if len(c.SummaryLanguages) > 0 {
+		item["summaryLanguages"] = &types.AttributeValueMemberSS{Value: c.SummaryLanguages}
+	}
+	if c.CommentSummary != nil {
+		item["commentSummary"] = &types.AttributeValueMemberBOOL{Value: *c.CommentSummary}
+	}
+	_, err := client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(table),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save channel: %w", err)
+	}
+	return nil
+}
+
+func channelFromItem(item map[string]types.AttributeValue) *Channel {
+	c := &Channel{}
+	for name, field := range map[string]*string{
+		"processedAt": &c.ID,
+		"handle":      &c.Handle,
+		"title":       &c.Title,
+		"shortsMode":  &c.ShortsMode,
+		"createdAt":   &c.CreatedAt,
+		"updatedAt":   &c.UpdatedAt,
+	} {
+		if v, ok := item[name].(*types.AttributeValueMemberS); ok {
+			*field = v.Value
+		}
+	}
+	if v, ok := item["paused"].(*types.AttributeValueMemberBOOL); ok {
+		c.Paused = v.Value
+	}
+	if v, ok := item["summaryLanguages"].(*types.AttributeValueMemberSS); ok {
+		c.SummaryLanguages = v.Value
+	}
+	if v, ok := item["commentSummary"].(*types.AttributeValueMemberBOOL); ok {
+		enabled := v.Value
+		c.CommentSummary = &enabled
+	}
+	return c
+}
+
+// GetChannel returns the channel with id, or nil if it is not configured.
+func GetChannel(ctx context.Context, client *dynamodb.Client, table, id string) (*Channel, error) {
+	resp, err := client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(table),
+		Key:       channelKey(id),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get channel: %w", err)
+	}
+	if resp.Item == nil {
+		return nil, nil
+	}
+	return channelFromItem(resp.Item), nil
+}
+
+// ListChannels returns every configured channel, paused or not.
+func ListChannels(ctx context.Context, client *dynamodb.Client, table string) ([]*Channel, error) {
+	channels := []*Channel{}
+	var lastEvaluatedKey map[string]types.AttributeValue
+	for {
+		resp, err := client.Query(ctx, &dynamodb.QueryInput{
+			TableName:              aws.String(table),
+			KeyConditionExpression: aws.String("hashtag = :p"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":p": &types.AttributeValueMemberS{Value: ChannelsPartition},
+			},
+			ExclusiveStartKey: lastEvaluatedKey,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list channels: %w", err)
+		}
+		for _, item := range resp.Items {
+			channels = append(channels, channelFromItem(item))
+		}
+		if resp.LastEvaluatedKey == nil {
+			return channels, nil
+		}
+		lastEvaluatedKey = resp.LastEvaluatedKey
+	}
+}
+
+// DeleteChannel removes the configuration of channel id. Its summaries are
+// kept.
+func DeleteChannel(ctx context.Context, client *dynamodb.Client, table, id string) error {
+	_, err := client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(table),
+		Key:       channelKey(id),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete channel: %w", err)
+	}
+	return nil
+}
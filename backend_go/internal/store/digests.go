@@ -0,0 +1,175 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Digest periods.
+const (
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// DigestAllChannels is the channel ID of digests that cover every channel.
+const DigestAllChannels = "all"
+
+// digestSentTTL is how long a digest is remembered as mailed, longer than
+// any job is retried.
+const digestSentTTL = 30 * 24 * time.Hour
+
+// Highlight is a video a digest picks out.
+type Highlight struct {
+	VideoID string `json:"videoId"`
+	Title   string `json:"title"`
+	Reason  string `json:"reason"`
+}
+
+// Digest summarizes the videos of a channel, or of all channels, published
+// during one day or week. Start and End are dates (YYYY-MM-DD), End
+// exclusive.
+type Digest struct {
+	Period     string      `json:"period"`
+	Start      string      `json:"start"`
+	End        string      `json:"end"`
+	ChannelID  string      `json:"channelId"`
+	Headline   string      `json:"headline"`
+	Summary    string      `json:"summary"`
+	Highlights []Highlight `json:"highlights"`
+	VideoIDs   []string    `json:"videoIds"`
+	CostUSD    float64     `json:"costUsd"`
+	CreatedAt  string      `json:"createdAt"`
+}
+
+// Digests of a period are kept under "digest#<period>", sorted by
+// "<start>#<channelID>" so that all digests of a day or week are adjacent.
+func digestPartition(period string) string {
+	return "digest#" + period
+}
+
+// SaveDigest stores d, replacing an earlier digest of the same period,
+// start and channel.
+func SaveDigest(ctx context.Context, client *dynamodb.Client, table string, d *Digest) error {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	_, err = client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(table),
+		Item: map[string]types.AttributeValue{
+			"hashtag":     &types.AttributeValueMemberS{Value: digestPartition(d.Period)},
+			"processedAt": &types.AttributeValueMemberS{Value: d.Start + "#" + d.ChannelID},
+			"channelId":   &types.AttributeValueMemberS{Value: d.ChannelID},
+			"digest":      &types.AttributeValueMemberS{Value: string(data)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save digest: %w", err)
+	}
+	return nil
+}
+
+// ListDigests returns the digests of period, newest first. An empty
+// channelID returns the digests of every channel. start, if set, limits
+// the result to digests of the period starting that day; limit, if
+// positive, to that many digests.
+func ListDigests(ctx context.Context, client *dynamodb.Client, table, period, channelID, start string, limit int) ([]*Digest, error) {
+	digests := []*Digest{}
+	var lastEvaluatedKey map[string]types.AttributeValue
+
+	for {
+		input := &dynamodb.QueryInput{
+			TableName:              aws.String(table),
+			KeyConditionExpression: aws.String("hashtag = :p"),
+			ScanIndexForward:       aws.Bool(false),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":p": &types.AttributeValueMemberS{Value: digestPartition(period)},
+			},
+			ExclusiveStartKey: lastEvaluatedKey,
+		}
+		if start != "" {
+			input.KeyConditionExpression = aws.String("hashtag = :p AND begins_with(processedAt, :start)")
+			input.ExpressionAttributeValues[":start"] = &types.AttributeValueMemberS{Value: start + "#"}
+		}
+		if channelID != "" {
+			input.FilterExpression = aws.String("channelId = :c")
+			input.ExpressionAttributeValues[":c"] = &types.AttributeValueMemberS{Value: channelID}
+		}
+
+		resp, err := client.Query(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list digests: %w", err)
+		}
+		for _, item := range resp.Items {
+			v, ok := item["digest"].(*types.AttributeValueMemberS)
+			if !ok {
+				continue
+			}
+			var d Digest
+			if err := json.Unmarshal([]byte(v.Value), &d); err != nil {
+				return nil, fmt.Errorf("invalid digest: %w", err)
+			}
+			digests = append(digests, &d)
+			if limit > 0 && len(digests) >= limit {
+				return digests, nil
+			}
+		}
+
+		if resp.LastEvaluatedKey == nil {
+			return digests, nil
+		}
+		lastEvaluatedKey = resp.LastEvaluatedKey
+	}
+}
+
+// digestSentKey is the marker of d having been mailed to email, kept under
+// "digestsent#<period>" and sorted by "<start>#<channelID>#<address>".
+func digestSentKey(d *Digest, email string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"hashtag":     &types.AttributeValueMemberS{Value: "digestsent#" + d.Period},
+		"processedAt": &types.AttributeValueMemberS{Value: d.Start + "#" + d.ChannelID + "#" + strings.ToLower(email)},
+	}
+}
+
+// MarkDigestSent records that d is being mailed to email and reports whether
+// it was not recorded before, so that retries and repeated jobs send a
+// digest to each subscriber once. Markers expire after digestSentTTL.
+func MarkDigestSent(ctx context.Context, client *dynamodb.Client, table string, d *Digest, email string) (bool, error) {
+	item := digestSentKey(d, email)
+	item["ttl"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(time.Now().Add(digestSentTTL).Unix(), 10)}
+	_, err := client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(table),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(processedAt)"),
+	})
+	if err != nil {
+		var ccf *types.ConditionalCheckFailedException
+		if errors.As(err, &ccf) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to mark digest sent: %w", err)
+	}
+	return true, nil
+}
+
+// UnmarkDigestSent removes the marker MarkDigestSent wrote, so that a digest
+// that failed to send is tried again.
+func UnmarkDigestSent(ctx context.Context, client *dynamodb.Client, table string, d *Digest, email string) error {
+	_, err := client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(table),
+		Key:       digestSentKey(d, email),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to unmark digest sent: %w", err)
+	}
+	return nil
+}
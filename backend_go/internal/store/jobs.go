@@ -0,0 +1,180 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Jobs are kept under this partition, one item per video. The job ID is the
+// video ID, so submitting the same video twice returns the same job.
+const JobsPartition = "jobs"
+
+// Job status values.
+const (
+	JobQueued = "queued" // waiting for the next batch run
+	JobDone   = "done"   // the video has a summary
+	JobFailed = "failed" // given up; submitting the video again retries it
+)
+
+// Job is a request to summarize a single video.
+type Job struct {
+	ID        string `json:"id"`
+	VideoID   string `json:"videoId"`
+	Source    string `json:"source,omitempty"` // the URL or ID as submitted
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	Attempts  int    `json:"attempts"`
+	ChannelID string `json:"channelId,omitempty"`
+	CreatedAt string `json:"createdAt"`
+	UpdatedAt string `json:"updatedAt"`
+}
+
+func (j *Job) item() map[string]types.AttributeValue {
+	item := map[string]types.AttributeValue{
+		"hashtag":          &types.AttributeValueMemberS{Value: JobsPartition},
+		"processedAt":      &types.AttributeValueMemberS{Value: j.ID},
+		"requestedVideoId": &types.AttributeValueMemberS{Value: j.VideoID},
+		"status":           &types.AttributeValueMemberS{Value: j.Status},
+		"attempts":         &types.AttributeValueMemberN{Value: strconv.Itoa(j.Attempts)},
+		"createdAt":        &types.AttributeValueMemberS{Value: j.CreatedAt},
+		"updatedAt":        &types.AttributeValueMemberS{Value: j.UpdatedAt},
+	}
+	for name, value := range map[string]string{
+		"source":    j.Source,
+		"error":     j.Error,
+		"channelId": j.ChannelID,
+	} {
+		if value != "" {
+			item[name] = &types.AttributeValueMemberS{Value: value}
+		}
+	}
+	return item
+}
+
+func jobFromItem(item map[string]types.AttributeValue) *Job {
+	j := &Job{}
+	for name, field := range map[string]*string{
+		"processedAt":      &j.ID,
+		"requestedVideoId": &j.VideoID,
+		"source":           &j.Source,
+		"status":           &j.Status,
+		"error":            &j.Error,
+		"channelId":        &j.ChannelID,
+		"createdAt":        &j.CreatedAt,
+		"updatedAt":        &j.UpdatedAt,
+	} {
+		if v, ok := item[name].(*types.AttributeValueMemberS); ok {
+			*field = v.Value
+		}
+	}
+	if v, ok := item["attempts"].(*types.AttributeValueMemberN); ok {
+		j.Attempts, _ = strconv.Atoi(v.Value)
+	}
+	return j
+}
+
+// CreateJob queues videoID unless it already has a queued or finished job,
+// in which case that job is returned with created false. A failed job is
+// replaced by a new one. channelID is the channel the video is expected
+// from, or empty when any channel will do.
+func CreateJob(ctx context.Context, client *dynamodb.Client, table, videoID, channelID, source, status string) (job *Job, created bool, err error) {
+	now := time.Now().UTC().Format(time.RFC3339)
+	job = &Job{ID: videoID, VideoID: videoID, ChannelID: channelID, Source: source, Status: status, CreatedAt: now, UpdatedAt: now}
+
+	_, err = client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(table),
+		Item:                job.item(),
+		ConditionExpression: aws.String("attribute_not_exists(processedAt) OR #s = :failed"),
+		ExpressionAttributeNames: map[string]string{
+			"#s": "status",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":failed": &types.AttributeValueMemberS{Value: JobFailed},
+		},
+	})
+	if err == nil {
+		return job, true, nil
+	}
+	var ccf *types.ConditionalCheckFailedException
+	if !errors.As(err, &ccf) {
+		return nil, false, fmt.Errorf("failed to create job: %w", err)
+	}
+
+	existing, err := GetJob(ctx, client, table, videoID)
+	if err != nil {
+		return nil, false, err
+	}
+	if existing == nil {
+		return nil, false, fmt.Errorf("job %s vanished while being created", videoID)
+	}
+	return existing, false, nil
+}
+
+// GetJob returns the job with id, or nil if there is none.
+func GetJob(ctx context.Context, client *dynamodb.Client, table, id string) (*Job, error) {
+	resp, err := client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(table),
+		Key: map[string]types.AttributeValue{
+			"hashtag":     &types.AttributeValueMemberS{Value: JobsPartition},
+			"processedAt": &types.AttributeValueMemberS{Value: id},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job: %w", err)
+	}
+	if resp.Item == nil {
+		return nil, nil
+	}
+	return jobFromItem(resp.Item), nil
+}
+
+// ListJobs returns the jobs with status.
+func ListJobs(ctx context.Context, client *dynamodb.Client, table, status string) ([]*Job, error) {
+	var jobs []*Job
+	var lastEvaluatedKey map[string]types.AttributeValue
+	for {
+		resp, err := client.Query(ctx, &dynamodb.QueryInput{
+			TableName:              aws.String(table),
+			KeyConditionExpression: aws.String("hashtag = :p"),
+			FilterExpression:       aws.String("#s = :s"),
+			ExpressionAttributeNames: map[string]string{
+				"#s": "status",
+			},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":p": &types.AttributeValueMemberS{Value: JobsPartition},
+				":s": &types.AttributeValueMemberS{Value: status},
+			},
+			ExclusiveStartKey: lastEvaluatedKey,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list jobs: %w", err)
+		}
+		for _, item := range resp.Items {
+			jobs = append(jobs, jobFromItem(item))
+		}
+		if resp.LastEvaluatedKey == nil {
+			return jobs, nil
+		}
+		lastEvaluatedKey = resp.LastEvaluatedKey
+	}
+}
+
+// SaveJob writes job back with the current time as its update time.
+func SaveJob(ctx context.Context, client *dynamodb.Client, table string, job *Job) error {
+	job.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	_, err := client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(table),
+		Item:      job.item(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save job %s: %w", job.ID, err)
+	}
+	return nil
+}
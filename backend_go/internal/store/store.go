@@ -0,0 +1,130 @@
+// Package store holds the DynamoDB item access shared by the batch and the API.
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/ttakahashi/youtube-summary/internal/blob"
+	"github.com/ttakahashi/youtube-summary/internal/transcript"
+)
+
+// GetVideoItem returns the full item for videoID, or nil if it is not stored.
+func GetVideoItem(ctx context.Context, client *dynamodb.Client, table, videoID string) (map[string]types.AttributeValue, error) {
+	// Query GSI to get Primary Key
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(table),
+		IndexName:              aws.String("videoId-index"),
+		KeyConditionExpression: aws.String("videoId = :vid"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":vid": &types.AttributeValueMemberS{Value: videoID},
+		},
+		Limit: aws.Int32(1),
+	}
+
+	resp, err := client.Query(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query index: %w", err)
+	}
+
+	if len(resp.Items) == 0 {
+		return nil, nil // Not found
+	}
+
+	// Found in index. Get full item from base table.
+	item := resp.Items[0]
+	hashtag := item["hashtag"].(*types.AttributeValueMemberS).Value
+	processedAt := item["processedAt"].(*types.AttributeValueMemberS).Value
+
+	getItemInput := &dynamodb.GetItemInput{
+		TableName: aws.String(table),
+		Key: map[string]types.AttributeValue{
+			"hashtag":     &types.AttributeValueMemberS{Value: hashtag},
+			"processedAt": &types.AttributeValueMemberS{Value: processedAt},
+		},
+	}
+
+	getResult, err := client.GetItem(ctx, getItemInput)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get item: %w", err)
+	}
+
+	return getResult.Item, nil
+}
+
+// HasSummary reports whether a video item has been summarized. Shorts that
+// were skipped or digested count as summarized.
+func HasSummary(item map[string]types.AttributeValue) bool {
+	for _, name := range []string{"detailSummary", "detailSummaryRef", "shortsHandling"} {
+		if _, ok := item[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// TranscriptBlobKey is where an offloaded transcript of videoID is stored.
+func TranscriptBlobKey(videoID string) string {
+	return "transcripts/" + videoID + ".json.gz"
+}
+
+// DetailSummaryBlobKey is where an offloaded detail summary of videoID is stored.
+func DetailSummaryBlobKey(videoID string) string {
+	return "summaries/" + videoID + "/detail.md.gz"
+}
+
+// TranslatedDetailSummaryBlobKey is where an offloaded translation of the
+// detail summary of videoID into lang is stored.
+func TranslatedDetailSummaryBlobKey(videoID, lang string) string {
+	return "summaries/" + videoID + "/detail." + lang + ".md.gz"
+}
+
+// TranscriptSegments reads the stored transcript of an item, loading it from
+// blobs when it was offloaded. Items written before segments were stored hold
+// the formatter's JSON in "transcript".
+func TranscriptSegments(ctx context.Context, blobs blob.Store, item map[string]types.AttributeValue) ([]transcript.Segment, error) {
+	if v, ok := item["transcriptRef"].(*types.AttributeValueMemberS); ok {
+		if blobs == nil {
+			return nil, fmt.Errorf("transcript is offloaded to %s but no blob store is configured", v.Value)
+		}
+		data, err := blob.GetCompressed(ctx, blobs, v.Value)
+		if err != nil {
+			return nil, err
+		}
+		return transcript.Decode(string(data))
+	}
+	if v, ok := item["transcriptSegments"].(*types.AttributeValueMemberS); ok {
+		return transcript.Decode(v.Value)
+	}
+	if v, ok := item["transcript"].(*types.AttributeValueMemberS); ok && v.Value != "" {
+		segments, err := transcript.ParseLegacy(v.Value)
+		if err != nil {
+			// Keep the text usable even if it has no timing
+			return []transcript.Segment{{Text: v.Value}}, nil
+		}
+		return segments, nil
+	}
+	return nil, nil
+}
+
+// DetailSummary returns the detail summary of an item, loading it from blobs
+// when it was offloaded.
+func DetailSummary(ctx context.Context, blobs blob.Store, item map[string]types.AttributeValue) (string, error) {
+	if v, ok := item["detailSummaryRef"].(*types.AttributeValueMemberS); ok {
+		if blobs == nil {
+			return "", fmt.Errorf("detail summary is offloaded to %s but no blob store is configured", v.Value)
+		}
+		data, err := blob.GetCompressed(ctx, blobs, v.Value)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	}
+	if v, ok := item["detailSummary"].(*types.AttributeValueMemberS); ok {
+		return v.Value, nil
+	}
+	return "", nil
+}
@@ -0,0 +1,115 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// DeliverSummaries subscribers get an email for every new summary; the
+// others get the daily or weekly digest (DigestDaily, DigestWeekly).
+const DeliverSummaries = "summaries"
+
+// Subscriber is an email address subscribed to a channel, or to
+// DigestAllChannels. Token authorizes unsubscribing.
+type Subscriber struct {
+	ChannelID string `json:"channelId"`
+	Email     string `json:"email"`
+	Delivery  string `json:"delivery"`
+	Token     string `json:"-"`
+	CreatedAt string `json:"createdAt"`
+}
+
+// Subscribers of a channel are kept under "subscribers#<channelID>", sorted
+// by lower-cased address.
+func subscriberKey(channelID, email string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"hashtag":     &types.AttributeValueMemberS{Value: "subscribers#" + channelID},
+		"processedAt": &types.AttributeValueMemberS{Value: strings.ToLower(email)},
+	}
+}
+
+// SaveSubscriber adds s, or replaces the subscription of the same address
+// to the same channel.
+func SaveSubscriber(ctx context.Context, client *dynamodb.Client, table string, s *Subscriber) error {
+	item := subscriberKey(s.ChannelID, s.Email)
+	item["email"] = &types.AttributeValueMemberS{Value: s.Email}
+	item["delivery"] = &types.AttributeValueMemberS{Value: s.Delivery}
+	item["token"] = &types.AttributeValueMemberS{Value: s.Token}
+	item["createdAt"] = &types.AttributeValueMemberS{Value: s.CreatedAt}
+	_, err := client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(table),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save subscriber: %w", err)
+	}
+	return nil
+}
+
+// ListSubscribers returns the subscribers of channelID who chose delivery.
+func ListSubscribers(ctx context.Context, client *dynamodb.Client, table, channelID, delivery string) ([]*Subscriber, error) {
+	var subscribers []*Subscriber
+	var lastEvaluatedKey map[string]types.AttributeValue
+	for {
+		resp, err := client.Query(ctx, &dynamodb.QueryInput{
+			TableName:              aws.String(table),
+			KeyConditionExpression: aws.String("hashtag = :p"),
+			FilterExpression:       aws.String("delivery = :d"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":p": &types.AttributeValueMemberS{Value: "subscribers#" + channelID},
+				":d": &types.AttributeValueMemberS{Value: delivery},
+			},
+			ExclusiveStartKey: lastEvaluatedKey,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list subscribers: %w", err)
+		}
+		for _, item := range resp.Items {
+			s := &Subscriber{ChannelID: channelID, Delivery: delivery}
+			if v, ok := item["email"].(*types.AttributeValueMemberS); ok {
+				s.Email = v.Value
+			}
+			if v, ok := item["token"].(*types.AttributeValueMemberS); ok {
+				s.Token = v.Value
+			}
+			if v, ok := item["createdAt"].(*types.AttributeValueMemberS); ok {
+				s.CreatedAt = v.Value
+			}
+			subscribers = append(subscribers, s)
+		}
+		if resp.LastEvaluatedKey == nil {
+			return subscribers, nil
+		}
+		lastEvaluatedKey = resp.LastEvaluatedKey
+	}
+}
+
+// RemoveSubscriber deletes the subscription of email to channelID if token
+// matches it, and reports whether it did.
+func RemoveSubscriber(ctx context.Context, client *dynamodb.Client, table, channelID, email, token string) (bool, error) {
+	_, err := client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName:           aws.String(table),
+		Key:                 subscriberKey(channelID, email),
+		ConditionExpression: aws.String("#t = :t"),
+		ExpressionAttributeNames: map[string]string{
+			"#t": "token",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":t": &types.AttributeValueMemberS{Value: token},
+		},
+	})
+	if err != nil {
+		var ccf *types.ConditionalCheckFailedException
+		if errors.As(err, &ccf) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to remove subscriber: %w", err)
+	}
+	return true, nil
+}
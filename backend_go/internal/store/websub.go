@@ -0,0 +1,88 @@
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// WebSub subscriptions are kept under this partition, one item per channel.
+const WebSubPartition = "websub"
+
+// WebSub subscription status values.
+const (
+	WebSubPending      = "pending"      // requested, not yet verified by the hub
+	WebSubActive       = "active"       // verified; expires at ExpiresAt
+	WebSubUnsubscribed = "unsubscribed" // removed, or denied by the hub
+)
+
+// WebSubSubscription is the push subscription to a channel's uploads feed.
+type WebSubSubscription struct {
+	ChannelID   string `json:"channelId"`
+	Topic       string `json:"topic"`
+	Status      string `json:"status"`
+	RequestedAt string `json:"requestedAt,omitempty"`
+	VerifiedAt  string `json:"verifiedAt,omitempty"`
+	ExpiresAt   string `json:"expiresAt,omitempty"`
+}
+
+// SaveWebSubSubscription stores s, replacing the channel's earlier state.
+func SaveWebSubSubscription(ctx context.Context, client *dynamodb.Client, table string, s *WebSubSubscription) error {
+	item := map[string]types.AttributeValue{
+		"hashtag":     &types.AttributeValueMemberS{Value: WebSubPartition},
+		"processedAt": &types.AttributeValueMemberS{Value: s.ChannelID},
+		"topic":       &types.AttributeValueMemberS{Value: s.Topic},
+		"status":      &types.AttributeValueMemberS{Value: s.Status},
+	}
+	for name, value := range map[string]string{
+		"requestedAt": s.RequestedAt,
+		"verifiedAt":  s.VerifiedAt,
+		"expiresAt":   s.ExpiresAt,
+	} {
+		if value != "" {
+			item[name] = &types.AttributeValueMemberS{Value: value}
+		}
+	}
+	_, err := client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(table),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save WebSub subscription: %w", err)
+	}
+	return nil
+}
+
+// GetWebSubSubscription returns the subscription to channelID, or nil if
+// none was ever requested.
+func GetWebSubSubscription(ctx context.Context, client *dynamodb.Client, table, channelID string) (*WebSubSubscription, error) {
+	resp, err := client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(table),
+		Key: map[string]types.AttributeValue{
+			"hashtag":     &types.AttributeValueMemberS{Value: WebSubPartition},
+			"processedAt": &types.AttributeValueMemberS{Value: channelID},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get WebSub subscription: %w", err)
+	}
+	if resp.Item == nil {
+		return nil, nil
+	}
+	s := &WebSubSubscription{ChannelID: channelID}
+	for name, field := range map[string]*string{
+		"topic":       &s.Topic,
+		"status":      &s.Status,
+		"requestedAt": &s.RequestedAt,
+		"verifiedAt":  &s.VerifiedAt,
+		"expiresAt":   &s.ExpiresAt,
+	} {
+		if v, ok := resp.Item[name].(*types.AttributeValueMemberS); ok {
+			*field = v.Value
+		}
+	}
+	return s, nil
+}
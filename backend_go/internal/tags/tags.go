@@ -0,0 +1,158 @@
+// Package tags normalizes the keywords, named entities and topic categories
+// extracted from summaries so that the archive can be browsed by topic.
+package tags
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Categories are the topic categories a video can be filed under.
+var Categories = []string{
+	"technology",
+	"business",
+	"economy",
+	"politics",
+	"society",
+	"science",
+	"education",
+	"entertainment",
+	"gaming",
+	"lifestyle",
+	"sports",
+	"other",
+}
+
+// Category returns c if it is one of Categories, and "other" otherwise.
+func Category(c string) string {
+	c = strings.ToLower(strings.TrimSpace(c))
+	if slices.Contains(Categories, c) {
+		return c
+	}
+	return "other"
+}
+
+// Entities are the named entities mentioned in a video.
+type Entities struct {
+	People        []string `json:"people"`
+	Organizations []string `json:"organizations"`
+	Products      []string `json:"products"`
+}
+
+// Vocabulary maps the spellings a tag is written in to its canonical form.
+type Vocabulary struct {
+	canonical map[string]string // Key(alias) -> canonical tag
+}
+
+// DefaultVocabulary covers spellings that commonly differ between videos.
+var DefaultVocabulary = MustVocabulary(map[string][]string{
+	"AI":       {"人工知能", "artificial intelligence"},
+	"生成AI":     {"generative ai", "gen ai", "ジェネレーティブAI"},
+	"ChatGPT":  {"chat gpt", "チャットGPT"},
+	"OpenAI":   {"open ai", "オープンAI"},
+	"AWS":      {"amazon web services"},
+	"半導体":      {"semiconductor", "semiconductors"},
+	"株式投資":     {"株", "株式", "stock investment"},
+	"日本銀行":     {"日銀", "bank of japan", "boj"},
+	"アメリカ":     {"米国", "usa", "united states"},
+	"中国":       {"china", "中華人民共和国"},
+	"イーロン・マスク": {"elon musk", "イーロンマスク"},
+})
+
+// NewVocabulary builds a vocabulary from canonical tags and their aliases.
+// Every canonical tag is also an alias of itself.
+func NewVocabulary(aliases map[string][]string) (*Vocabulary, error) {
+	v := &Vocabulary{canonical: map[string]string{}}
+	for tag, list := range aliases {
+		for _, alias := range append([]string{tag}, list...) {
+			k := Key(alias)
+			if k == "" {
+				continue
+			}
+			if prev, ok := v.canonical[k]; ok && prev != tag {
+				return nil, fmt.Errorf("alias %q belongs to both %q and %q", alias, prev, tag)
+			}
+			v.canonical[k] = tag
+		}
+	}
+	return v, nil
+}
+
+// MustVocabulary is NewVocabulary for vocabularies known to be valid.
+func MustVocabulary(aliases map[string][]string) *Vocabulary {
+	v, err := NewVocabulary(aliases)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// ParseVocabulary parses a JSON object such as {"AI": ["人工知能"]} and
+// layers it over DefaultVocabulary.
+func ParseVocabulary(s string) (*Vocabulary, error) {
+	var aliases map[string][]string
+	if err := json.Unmarshal([]byte(s), &aliases); err != nil {
+		return nil, fmt.Errorf("invalid tag vocabulary: %w", err)
+	}
+	v := &Vocabulary{canonical: map[string]string{}}
+	for k, tag := range DefaultVocabulary.canonical {
+		v.canonical[k] = tag
+	}
+	overrides, err := NewVocabulary(aliases)
+	if err != nil {
+		return nil, err
+	}
+	for k, tag := range overrides.canonical {
+		v.canonical[k] = tag
+	}
+	return v, nil
+}
+
+// Key is the form tags are compared in: NFKC-normalized, lower case, with
+// a leading '#' dropped and runs of spaces collapsed.
+func Key(tag string) string {
+	return strings.ToLower(clean(tag))
+}
+
+func clean(tag string) string {
+	tag = norm.NFKC.String(tag)
+	tag = strings.TrimPrefix(strings.TrimSpace(tag), "#")
+	return strings.Join(strings.Fields(tag), " ")
+}
+
+// Normalize returns the canonical form of tag, or the cleaned-up tag itself
+// if the vocabulary does not know it.
+func (v *Vocabulary) Normalize(tag string) string {
+	if canonical, ok := v.canonical[Key(tag)]; ok {
+		return canonical
+	}
+	return clean(tag)
+}
+
+// NormalizeAll normalizes tags, dropping empty ones and duplicates.
+func (v *Vocabulary) NormalizeAll(tags []string) []string {
+	result := []string{}
+	seen := map[string]bool{}
+	for _, t := range tags {
+		n := v.Normalize(t)
+		if n == "" || seen[Key(n)] {
+			continue
+		}
+		seen[Key(n)] = true
+		result = append(result, n)
+	}
+	return result
+}
+
+// NormalizeEntities normalizes each list of e.
+func (v *Vocabulary) NormalizeEntities(e Entities) Entities {
+	return Entities{
+		People:        v.NormalizeAll(e.People),
+		Organizations: v.NormalizeAll(e.Organizations),
+		Products:      v.NormalizeAll(e.Products),
+	}
+}
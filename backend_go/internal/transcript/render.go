@@ -0,0 +1,81 @@
+package transcript
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// HasTiming reports whether the segments carry start times, which subtitle
+// formats need. Transcripts recovered from untimed text do not.
+func HasTiming(segments []Segment) bool {
+	for _, s := range segments {
+		if s.Start > 0 || s.Duration > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// SRT renders segments as SubRip subtitles.
+func SRT(segments []Segment) string {
+	var b strings.Builder
+	for i, s := range segments {
+		fmt.Fprintf(&b, "%d\n%s --> %s\n%s\n\n", i+1, cueTime(s.Start, ","), cueTime(s.End(), ","), cueText(s.Text))
+	}
+	return b.String()
+}
+
+// VTT renders segments as WebVTT subtitles.
+func VTT(segments []Segment) string {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n\n")
+	for _, s := range segments {
+		fmt.Fprintf(&b, "%s --> %s\n%s\n\n", cueTime(s.Start, "."), cueTime(s.End(), "."), cueText(s.Text))
+	}
+	return b.String()
+}
+
+// Markdown renders the transcript as a document with a heading and one
+// paragraph per block of about interval seconds, each led by a timestamp
+// link into the video.
+func Markdown(segments []Segment, title, videoID string, interval float64) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", title)
+	fmt.Fprintf(&b, "https://www.youtube.com/watch?v=%s\n", videoID)
+
+	if !HasTiming(segments) {
+		b.WriteString("\n")
+		b.WriteString(PlainText(segments))
+		b.WriteString("\n")
+		return b.String()
+	}
+
+	blockStart := -interval
+	for _, s := range segments {
+		if s.Start-blockStart >= interval {
+			blockStart = s.Start
+			fmt.Fprintf(&b, "\n\n[%s](%s)", FormatTimestamp(s.Start), WatchURL(videoID, s.Start))
+		}
+		b.WriteByte(' ')
+		b.WriteString(s.Text)
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// cueText keeps a caption inside its cue: a blank line would end the cue
+// and "-->" would be read as a timing line.
+func cueText(text string) string {
+	text = strings.ReplaceAll(text, "-->", "->")
+	for strings.Contains(text, "\n\n") {
+		text = strings.ReplaceAll(text, "\n\n", "\n")
+	}
+	return strings.TrimSpace(text)
+}
+
+// cueTime formats seconds as hh:mm:ss followed by sep and milliseconds.
+func cueTime(seconds float64, sep string) string {
+	ms := int64(math.Round(math.Max(seconds, 0) * 1000))
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", ms/3600000, ms/60000%60, ms/1000%60, sep, ms%1000)
+}
@@ -0,0 +1,163 @@
+// Package transcript stores timed caption segments and renders them as text.
+package transcript
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Segment is one caption line with its timing in seconds.
+type Segment struct {
+	Start    float64 `json:"start"`
+	Duration float64 `json:"duration"`
+	Text     string  `json:"text"`
+}
+
+// End returns the time the segment stops being shown.
+func (s Segment) End() float64 {
+	return s.Start + s.Duration
+}
+
+// Encode packs segments into the compact form stored on items: a JSON array
+// of [startMillis, durationMillis, text] triples.
+func Encode(segments []Segment) (string, error) {
+	packed := make([][3]interface{}, len(segments))
+	for i, s := range segments {
+		packed[i] = [3]interface{}{toMillis(s.Start), toMillis(s.Duration), s.Text}
+	}
+	b, err := json.Marshal(packed)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode segments: %w", err)
+	}
+	return string(b), nil
+}
+
+// Decode unpacks segments produced by Encode.
+func Decode(data string) ([]Segment, error) {
+	var raw [][3]json.RawMessage
+	if err := json.Unmarshal([]byte(data), &raw); err != nil {
+		return nil, fmt.Errorf("failed to decode segments: %w", err)
+	}
+
+	segments := make([]Segment, len(raw))
+	for i, r := range raw {
+		var start, duration int64
+		var text string
+		if err := errors.Join(
+			json.Unmarshal(r[0], &start),
+			json.Unmarshal(r[1], &duration),
+			json.Unmarshal(r[2], &text),
+		); err != nil {
+			return nil, fmt.Errorf("failed to decode segment %d: %w", i, err)
+		}
+		segments[i] = Segment{
+			Start:    float64(start) / 1000,
+			Duration: float64(duration) / 1000,
+			Text:     text,
+		}
+	}
+	return segments, nil
+}
+
+// ParseLegacy reads the pretty-printed JSON that older runs stored in the
+// "transcript" attribute (the youtube-transcript-api-go JSON formatter output).
+func ParseLegacy(data string) ([]Segment, error) {
+	var tracks []struct {
+		Transcripts []Segment `json:"transcripts"`
+	}
+	if err := json.Unmarshal([]byte(data), &tracks); err != nil {
+		return nil, fmt.Errorf("failed to parse legacy transcript: %w", err)
+	}
+	if len(tracks) == 0 {
+		return nil, fmt.Errorf("failed to parse legacy transcript: no tracks")
+	}
+	return tracks[0].Transcripts, nil
+}
+
+// PlainText joins the segment texts, one per line.
+func PlainText(segments []Segment) string {
+	var b strings.Builder
+	for i, s := range segments {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString(s.Text)
+	}
+	return b.String()
+}
+
+// Timestamped groups segments into blocks of roughly interval seconds, each
+// prefixed with its start time, e.g. "[12:34] ...". It is the form given to
+// the LLM so that summaries can cite times.
+func Timestamped(segments []Segment, interval float64) string {
+	var b strings.Builder
+	blockStart := -interval
+	for _, s := range segments {
+		if s.Start-blockStart >= interval {
+			if b.Len() > 0 {
+				b.WriteByte('\n')
+			}
+			blockStart = s.Start
+			fmt.Fprintf(&b, "[%s]", FormatTimestamp(s.Start))
+		}
+		b.WriteByte(' ')
+		b.WriteString(s.Text)
+	}
+	return b.String()
+}
+
+// Chunk is a run of consecutive segments, the unit questions are answered from.
+type Chunk struct {
+	Start float64
+	End   float64
+	Text  string
+}
+
+// Chunks groups segments into chunks of roughly interval seconds.
+func Chunks(segments []Segment, interval float64) []Chunk {
+	var chunks []Chunk
+	var b strings.Builder
+	var current *Chunk
+	for _, s := range segments {
+		if current == nil || s.Start-current.Start >= interval {
+			if current != nil {
+				current.Text = b.String()
+				chunks = append(chunks, *current)
+				b.Reset()
+			}
+			current = &Chunk{Start: s.Start}
+		}
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(s.Text)
+		current.End = max(current.End, s.End())
+	}
+	if current != nil {
+		current.Text = b.String()
+		chunks = append(chunks, *current)
+	}
+	return chunks
+}
+
+// FormatTimestamp formats seconds as m:ss, or h:mm:ss from one hour on.
+func FormatTimestamp(seconds float64) string {
+	total := int(math.Max(seconds, 0))
+	h, m, s := total/3600, total/60%60, total%60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%d:%02d", m, s)
+}
+
+// WatchURL returns a link that starts playback of videoID at seconds.
+func WatchURL(videoID string, seconds float64) string {
+	return fmt.Sprintf("https://www.youtube.com/watch?v=%s&t=%ds", videoID, int(math.Max(seconds, 0)))
+}
+
+func toMillis(seconds float64) int64 {
+	return int64(math.Round(seconds * 1000))
+}
@@ -0,0 +1,119 @@
+package transcript
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	tests := []struct {
+		name     string
+		segments []Segment
+		want     []Segment // when millisecond rounding changes the input
+	}{
+		{
+			name:     "empty",
+			segments: []Segment{},
+		},
+		{
+			name: "timed lines",
+			segments: []Segment{
+				{Start: 0, Duration: 2.5, Text: "こんにちは"},
+				{Start: 2.5, Duration: 3.04, Text: `"quoted", [bracketed] and \ escaped`},
+				{Start: 3725.125, Duration: 1, Text: ""},
+			},
+		},
+		{
+			name:     "rounded to milliseconds",
+			segments: []Segment{{Start: 1.23456, Duration: 0.0004, Text: "a"}},
+			want:     []Segment{{Start: 1.235, Duration: 0, Text: "a"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := Encode(tt.segments)
+			if err != nil {
+				t.Fatalf("Encode: %v", err)
+			}
+			got, err := Decode(data)
+			if err != nil {
+				t.Fatalf("Decode(%s): %v", data, err)
+			}
+			want := tt.want
+			if want == nil {
+				want = tt.segments
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Decode(Encode(...)) = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestEncodeFormat(t *testing.T) {
+	data, err := Encode([]Segment{{Start: 1.5, Duration: 2, Text: "a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `[[1500,2000,"a"]]`; data != want {
+		t.Errorf("Encode = %s, want %s", data, want)
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	for _, data := range []string{
+		``,
+		`{}`,
+		`[[1500,2000]]`,
+		`[["1500",2000,"a"]]`,
+		`[[1500,2000,3]]`,
+	} {
+		if got, err := Decode(data); err == nil {
+			t.Errorf("Decode(%q) = %v, want an error", data, got)
+		}
+	}
+}
+
+func TestParseLegacy(t *testing.T) {
+	data := `[
+  {
+    "language_code": "ja",
+    "transcripts": [
+      {"text": "一行目", "start": 0.5, "duration": 1.2},
+      {"text": "二行目", "start": 1.7, "duration": 2}
+    ]
+  }
+]`
+	got, err := ParseLegacy(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Segment{{Start: 0.5, Duration: 1.2, Text: "一行目"}, {Start: 1.7, Duration: 2, Text: "二行目"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseLegacy = %v, want %v", got, want)
+	}
+
+	if _, err := ParseLegacy(`[]`); err == nil {
+		t.Error("ParseLegacy without tracks succeeded")
+	}
+}
+
+func TestFormatTimestamp(t *testing.T) {
+	tests := []struct {
+		seconds float64
+		want    string
+	}{
+		{0, "0:00"},
+		{-3, "0:00"},
+		{59.9, "0:59"},
+		{754, "12:34"},
+		{3600, "1:00:00"},
+		{3725, "1:02:05"},
+	}
+	for _, tt := range tests {
+		if got := FormatTimestamp(tt.seconds); got != tt.want {
+			t.Errorf("FormatTimestamp(%v) = %q, want %q", tt.seconds, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+// Package websub subscribes to YouTube channel feeds through the WebSub
+// (PubSubHubbub) hub and reads the notifications it pushes.
+package websub
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Hub is the hub YouTube publishes channel feeds through.
+const Hub = "https://pubsubhubbub.appspot.com/subscribe"
+
+// DefaultLease is the subscription lifetime requested from the hub. The hub
+// may grant a shorter one, reported when it verifies the subscription.
+const DefaultLease = 10 * 24 * time.Hour
+
+// Topic returns the feed URL of channelID's uploads.
+func Topic(channelID string) string {
+	return "https://www.youtube.com/xml/feeds/videos.xml?channel_id=" + url.QueryEscape(channelID)
+}
+
+// ChannelOfTopic returns the channel ID of a topic made by Topic, or "".
+func ChannelOfTopic(topic string) string {
+	u, err := url.Parse(topic)
+	if err != nil || u.Host != "www.youtube.com" || u.Path != "/xml/feeds/videos.xml" {
+		return ""
+	}
+	return u.Query().Get("channel_id")
+}
+
+// Subscribe asks the hub to push topic to callback for lease. The hub then
+// verifies the request by calling callback with a challenge; the
+// subscription is active once that succeeds. Pushed content is signed with
+// secret.
+func Subscribe(ctx context.Context, client *http.Client, callback, topic, secret string, lease time.Duration) error {
+	return request(ctx, client, "subscribe", callback, topic, secret, lease)
+}
+
+// Unsubscribe asks the hub to stop pushing topic to callback.
+func Unsubscribe(ctx context.Context, client *http.Client, callback, topic string) error {
+	return request(ctx, client, "unsubscribe", callback, topic, "", 0)
+}
+
+func request(ctx context.Context, client *http.Client, mode, callback, topic, secret string, lease time.Duration) error {
+	form := url.Values{
+		"hub.mode":     {mode},
+		"hub.topic":    {topic},
+		"hub.callback": {callback},
+		"hub.verify":   {"async"},
+	}
+	if secret != "" {
+		form.Set("hub.secret", secret)
+	}
+	if lease > 0 {
+		form.Set("hub.lease_seconds", strconv.Itoa(int(lease.Seconds())))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, Hub, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to %s to %s: %w", mode, topic, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("failed to %s to %s: status %d: %s", mode, topic, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// ValidSignature reports whether header, the X-Hub-Signature of a push, is
+// the HMAC of body with secret. The hub signs with SHA-1.
+func ValidSignature(header, secret string, body []byte) bool {
+	algo, sig, ok := strings.Cut(header, "=")
+	if !ok || algo != "sha1" {
+		return false
+	}
+	got, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// Entry is a video in a pushed feed.
+type Entry struct {
+	VideoID   string
+	ChannelID string
+	Title     string
+	Published string
+	Updated   string
+}
+
+// Feed is a pushed notification. YouTube pushes an entry when a video is
+// uploaded or its title or description changes, and a deleted entry when
+// it is removed.
+type Feed struct {
+	Entries []Entry
+	Deleted []string // video IDs
+}
+
+// ParseFeed parses the Atom document of a push.
+func ParseFeed(data []byte) (*Feed, error) {
+	var doc struct {
+		Entries []struct {
+			VideoID   string `xml:"http://www.youtube.com/xml/schemas/2015 videoId"`
+			ChannelID string `xml:"http://www.youtube.com/xml/schemas/2015 channelId"`
+			Title     string `xml:"http://www.w3.org/2005/Atom title"`
+			Published string `xml:"http://www.w3.org/2005/Atom published"`
+			Updated   string `xml:"http://www.w3.org/2005/Atom updated"`
+		} `xml:"http://www.w3.org/2005/Atom entry"`
+		Deleted []struct {
+			Ref string `xml:"ref,attr"` // yt:video:<id>
+		} `xml:"http://purl.org/atompub/tombstones/1.0 deleted-entry"`
+	}
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid feed: %w", err)
+	}
+	feed := &Feed{}
+	for _, e := range doc.Entries {
+		if e.VideoID == "" {
+			continue
+		}
+		feed.Entries = append(feed.Entries, Entry{
+			VideoID:   e.VideoID,
+			ChannelID: e.ChannelID,
+			Title:     e.Title,
+			Published: e.Published,
+			Updated:   e.Updated,
+		})
+	}
+	for _, d := range doc.Deleted {
+		if id := strings.TrimPrefix(d.Ref, "yt:video:"); id != d.Ref {
+			feed.Deleted = append(feed.Deleted, id)
+		}
+	}
+	return feed, nil
+}
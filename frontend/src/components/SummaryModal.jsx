@@ -2,6 +2,24 @@ import { X } from 'lucide-react';
 import ReactMarkdown from 'react-markdown';
 import remarkBreaks from 'remark-breaks';
 
+// Turn [12:34] / [1:02:03] citations into links that start the video there
+const linkTimestamps = (text, videoId) =>
+  text.replace(/\[((?:\d+:)?\d{1,2}:\d{2})\](?!\()/g, (match, ts) => {
+    const seconds = ts.split(':').reduce((acc, part) => acc * 60 + Number(part), 0);
+    return `[${ts}](https://www.youtube.com/watch?v=${videoId}&t=${seconds}s)`;
+  });
+
+const markdownComponents = {
+  a: ({ node, ...props }) => (
+    <a
+      {...props}
+      target="_blank"
+      rel="noopener noreferrer"
+      className="text-primary-400 hover:underline"
+    />
+  ),
+};
+
 function SummaryModal({ summary, isOpen, onClose }) {
   if (!isOpen) return null;
 
@@ -40,14 +58,41 @@ function SummaryModal({ summary, isOpen, onClose }) {
             </p>
           </div>
 
+          {/* Chapters */}
+          {summary.chapters?.length > 0 && (
+            <div className="mt-6">
+              <h3 className="text-sm font-semibold text-slate-400 mb-2">チャプター</h3>
+              <ol className="space-y-3">
+                {summary.chapters.map((chapter) => (
+                  <li key={chapter.start} className="text-sm">
+                    <a
+                      href={chapter.url}
+                      target="_blank"
+                      rel="noopener noreferrer"
+                      className="font-mono text-primary-400 hover:underline mr-2"
+                    >
+                      {chapter.timestamp}
+                    </a>
+                    <span className="font-medium text-slate-200">{chapter.title}</span>
+                    {chapter.summary && (
+                      <p className="mt-1 text-slate-400">{chapter.summary}</p>
+                    )}
+                  </li>
+                ))}
+              </ol>
+            </div>
+          )}
+
           <div className="my-8 border-t border-slate-700/50" />
 
           {/* Detailed Summary */}
           <div className="prose prose-invert max-w-none">
             <h3 className="text-lg font-semibold text-accent-300 mb-4">詳細要約</h3>
             <div className="prose prose-invert prose-sm max-w-none text-slate-300">
-              <ReactMarkdown remarkPlugins={[remarkBreaks]}>
-                {summary.detailSummary || "詳細な要約は現在作成中です。"}
+              <ReactMarkdown remarkPlugins={[remarkBreaks]} components={markdownComponents}>
+                {summary.detailSummary
+                  ? linkTimestamps(summary.detailSummary, summary.videoId)
+                  : "詳細な要約は現在作成中です。"}
               </ReactMarkdown>
             </div>
           </div>
@@ -4,10 +4,11 @@ resource "aws_apigatewayv2_api" "api" {
   protocol_type = "HTTP"
 
   cors_configuration {
-    allow_headers = ["Content-Type", "Authorization"]
-    allow_methods = ["GET", "OPTIONS"]
-    allow_origins = ["https://${local.domain}", "http://localhost:5173"]
-    max_age       = 3600
+    allow_headers  = ["Content-Type", "Authorization", "X-API-Key"]
+    allow_methods  = ["GET", "POST", "PATCH", "DELETE", "OPTIONS"]
+    allow_origins  = ["https://${local.domain}", "http://localhost:5173"]
+    expose_headers = ["RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy", "Retry-After"]
+    max_age        = 3600
   }
 
   tags = {
@@ -54,6 +55,97 @@ resource "aws_apigatewayv2_route" "get_summaries" {
   target    = "integrations/${aws_apigatewayv2_integration.api.id}"
 }
 
+resource "aws_apigatewayv2_route" "get_transcript" {
+  api_id    = aws_apigatewayv2_api.api.id
+  route_key = "GET /api/summaries/{videoId}/transcript"
+  target    = "integrations/${aws_apigatewayv2_integration.api.id}"
+}
+
+resource "aws_apigatewayv2_route" "ask_video" {
+  api_id    = aws_apigatewayv2_api.api.id
+  route_key = "POST /api/summaries/{videoId}/ask"
+  target    = "integrations/${aws_apigatewayv2_integration.api.id}"
+}
+
+resource "aws_apigatewayv2_route" "get_video_stats" {
+  api_id    = aws_apigatewayv2_api.api.id
+  route_key = "GET /api/summaries/{videoId}/stats"
+  target    = "integrations/${aws_apigatewayv2_integration.api.id}"
+}
+
+resource "aws_apigatewayv2_route" "create_job" {
+  api_id    = aws_apigatewayv2_api.api.id
+  route_key = "POST /api/jobs"
+  target    = "integrations/${aws_apigatewayv2_integration.api.id}"
+}
+
+resource "aws_apigatewayv2_route" "get_job" {
+  api_id    = aws_apigatewayv2_api.api.id
+  route_key = "GET /api/jobs/{id}"
+  target    = "integrations/${aws_apigatewayv2_integration.api.id}"
+}
+
+resource "aws_apigatewayv2_route" "get_digests" {
+  api_id    = aws_apigatewayv2_api.api.id
+  route_key = "GET /api/digests"
+  target    = "integrations/${aws_apigatewayv2_integration.api.id}"
+}
+
+resource "aws_apigatewayv2_route" "get_digest" {
+  api_id    = aws_apigatewayv2_api.api.id
+  route_key = "GET /api/digests/{period}"
+  target    = "integrations/${aws_apigatewayv2_integration.api.id}"
+}
+
+# Channel management; the API checks the admin token and routes further
+resource "aws_apigatewayv2_route" "admin" {
+  api_id    = aws_apigatewayv2_api.api.id
+  route_key = "ANY /api/admin/{proxy+}"
+  target    = "integrations/${aws_apigatewayv2_integration.api.id}"
+}
+
+resource "aws_apigatewayv2_route" "websub_verify" {
+  api_id    = aws_apigatewayv2_api.api.id
+  route_key = "GET /api/websub/callback"
+  target    = "integrations/${aws_apigatewayv2_integration.api.id}"
+}
+
+resource "aws_apigatewayv2_route" "websub_notify" {
+  api_id    = aws_apigatewayv2_api.api.id
+  route_key = "POST /api/websub/callback"
+  target    = "integrations/${aws_apigatewayv2_integration.api.id}"
+}
+
+resource "aws_apigatewayv2_route" "create_subscription" {
+  api_id    = aws_apigatewayv2_api.api.id
+  route_key = "POST /api/subscriptions"
+  target    = "integrations/${aws_apigatewayv2_integration.api.id}"
+}
+
+resource "aws_apigatewayv2_route" "unsubscribe_get" {
+  api_id    = aws_apigatewayv2_api.api.id
+  route_key = "GET /api/unsubscribe"
+  target    = "integrations/${aws_apigatewayv2_integration.api.id}"
+}
+
+resource "aws_apigatewayv2_route" "unsubscribe_post" {
+  api_id    = aws_apigatewayv2_api.api.id
+  route_key = "POST /api/unsubscribe"
+  target    = "integrations/${aws_apigatewayv2_integration.api.id}"
+}
+
+resource "aws_apigatewayv2_route" "get_tags" {
+  api_id    = aws_apigatewayv2_api.api.id
+  route_key = "GET /api/tags"
+  target    = "integrations/${aws_apigatewayv2_integration.api.id}"
+}
+
+resource "aws_apigatewayv2_route" "get_cost_stats" {
+  api_id    = aws_apigatewayv2_api.api.id
+  route_key = "GET /api/stats/costs"
+  target    = "integrations/${aws_apigatewayv2_integration.api.id}"
+}
+
 
 
 # Lambda Permission for API Gateway
@@ -0,0 +1,17 @@
+# S3 Bucket for transcripts and summaries offloaded from DynamoDB
+resource "aws_s3_bucket" "blobs" {
+  bucket = "youtube-summary-blobs-${local.env}"
+
+  tags = {
+    Name = "youtube-summary-blobs-${local.env}"
+  }
+}
+
+resource "aws_s3_bucket_public_access_block" "blobs" {
+  bucket = aws_s3_bucket.blobs.id
+
+  block_public_acls       = true
+  block_public_policy     = true
+  ignore_public_acls      = true
+  restrict_public_buckets = true
+}
@@ -26,6 +26,13 @@ resource "aws_dynamodb_table" "summaries" {
     projection_type = "KEYS_ONLY"
   }
 
+  # Short-lived items such as rate limit buckets and digest sent markers set
+  # "ttl" (epoch seconds)
+  ttl {
+    attribute_name = "ttl"
+    enabled        = true
+  }
+
   point_in_time_recovery {
     enabled = local.env == "prd" ? true : false
   }
@@ -38,13 +38,47 @@ resource "aws_iam_role_policy" "api_lambda" {
       {
         Effect = "Allow"
         Action = [
+          "dynamodb:GetItem",
           "dynamodb:Query",
-          "dynamodb:Scan"
+          "dynamodb:Scan",
+          "dynamodb:UpdateItem",
+          "dynamodb:PutItem",
+          "dynamodb:DeleteItem"
         ]
         Resource = [
           aws_dynamodb_table.summaries.arn,
           "${aws_dynamodb_table.summaries.arn}/index/*"
         ]
+      },
+      {
+        Effect   = "Allow"
+        Action   = ["s3:GetObject"]
+        Resource = "${aws_s3_bucket.blobs.arn}/*"
+      },
+      {
+        # Summaries are translated on request
+        Effect = "Allow"
+        Action = ["bedrock:InvokeModel"]
+        Resource = [
+          "arn:aws:bedrock:*::foundation-model/*",
+          "arn:aws:bedrock:*:*:inference-profile/*"
+        ]
+      },
+      {
+        # The admin API resolves channel handles with the YouTube API key;
+        # WebSub pushes and bearer tokens are checked with their secrets
+        Effect = "Allow"
+        Action = ["secretsmanager:GetSecretValue"]
+        Resource = concat(
+          [data.aws_secretsmanager_secret.youtube_api_key.arn],
+          [for name in compact([var.websub_secret_name, var.jwt_secret_name]) : "arn:aws:secretsmanager:*:*:secret:${name}-*"]
+        )
+      },
+      {
+        # Pushed uploads and admin requests start the batch right away
+        Effect   = "Allow"
+        Action   = ["lambda:InvokeFunction"]
+        Resource = "arn:aws:lambda:*:*:function:${var.batch_function_name != "" ? var.batch_function_name : "none"}"
       }
     ]
   })
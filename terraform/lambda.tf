@@ -17,6 +17,17 @@ resource "aws_lambda_function" "api" {
       ENVIRONMENT    = local.env
       DYNAMODB_TABLE = aws_dynamodb_table.summaries.name
       CHANNEL_ID     = var.channel_id
+      BLOB_STORE     = "s3://${aws_s3_bucket.blobs.id}"
+
+      LLM_MONTHLY_BUDGET_USD = var.llm_monthly_budget_usd
+      TAG_VOCABULARY         = var.tag_vocabulary
+      BATCH_FUNCTION_NAME    = var.batch_function_name
+      WEBSUB_SECRET_NAME     = var.websub_secret_name
+      JWT_SECRET_NAME        = var.jwt_secret_name
+      AUTH_ANONYMOUS_SCOPES  = var.auth_anonymous_scopes
+      RATE_LIMIT_MODE        = var.rate_limit_mode
+      RATE_LIMITS            = var.rate_limits
+      LOG_LEVEL              = var.log_level
     }
   }
 
@@ -25,6 +25,11 @@ output "s3_bucket_name" {
 
 
 
+output "blob_bucket_name" {
+  description = "S3 bucket name for offloaded transcripts and summaries"
+  value       = aws_s3_bucket.blobs.id
+}
+
 output "api_lambda_function_name" {
   description = "API Lambda function name"
   value       = aws_lambda_function.api.function_name
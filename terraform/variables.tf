@@ -16,6 +16,54 @@ variable "min_like_count" {
   default     = 0
 }
 
+variable "llm_monthly_budget_usd" {
+  description = "Monthly LLM spend ceiling in USD after which summarization pauses (0 = no ceiling)"
+  type        = number
+  default     = 0
+}
+
+variable "tag_vocabulary" {
+  description = "JSON object mapping canonical tags to their aliases, layered over the built-in vocabulary"
+  type        = string
+  default     = ""
+}
+
+variable "batch_function_name" {
+  description = "Name of the batch Lambda the API starts for pushed uploads (empty = wait for the schedule)"
+  type        = string
+  default     = ""
+}
+
+variable "websub_secret_name" {
+  description = "Name of the Secrets Manager secret holding the HMAC secret of WebSub pushes; the batch must use the same one (required to accept pushes)"
+  type        = string
+  default     = ""
+}
+
+variable "jwt_secret_name" {
+  description = "Name of the Secrets Manager secret holding the HS256 secret of JWT bearer tokens (empty = API keys only)"
+  type        = string
+  default     = ""
+}
+
+variable "auth_anonymous_scopes" {
+  description = "Comma-separated scopes granted to requests without an API key or token (none = credentials required)"
+  type        = string
+  default     = "read"
+}
+
+variable "rate_limit_mode" {
+  description = "Where rate limit buckets are kept: memory (per Lambda instance), dynamodb (shared) or off"
+  type        = string
+  default     = "dynamodb"
+}
+
+variable "rate_limits" {
+  description = "JSON object of {perMinute, burst} per route class (read, ask, jobs, admin), layered over the defaults"
+  type        = string
+  default     = ""
+}
+
 variable "batch_schedule" {
   description = "Cron expression for batch processing (every 3 hours)"
   type        = string
@@ -34,4 +82,8 @@ variable "lambda_timeout" {
   default     = 300
 }
 
-
+variable "log_level" {
+  description = "Least severe level the API logs: debug, info, warn or error"
+  type        = string
+  default     = "info"
+}